@@ -11,6 +11,7 @@ import (
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"go.acuvity.ai/minibridge/pkgs/backend"
+	"go.acuvity.ai/minibridge/pkgs/backend/client"
 	"go.acuvity.ai/minibridge/pkgs/frontend"
 	"go.acuvity.ai/minibridge/pkgs/memconn"
 	"golang.org/x/sync/errgroup"
@@ -26,11 +27,14 @@ func init() {
 	fAIO.String("endpoint-mcp", "/mcp", "when using HTTP, sets the endpoint to send messages (proto 2025-03-26).")
 	fAIO.String("endpoint-messages", "/message", "when using HTTP, sets the endpoint to post messages (proto 2024-11-05).")
 	fAIO.String("endpoint-sse", "/sse", "when using HTTP, sets the endpoint to connect to the event stream (proto 2024-11-05).")
+	fAIO.Bool("shared-server", false, "if set, all agents share a single MCP server instance instead of one per connection.")
+	fAIO.StringSlice("mcp-egress-allow", nil, "if set, run an egress proxy for the MCP server only allowing these domains (example.com, *.example.com), IPs or CIDRs. the proxy is advisory: the MCP server can ignore it unless its network is isolated.")
 
 	AIO.Flags().AddFlagSet(fAIO)
 	AIO.Flags().AddFlagSet(fPolicer)
 	AIO.Flags().AddFlagSet(fTLSServer)
 	AIO.Flags().AddFlagSet(fHealth)
+	AIO.Flags().AddFlagSet(fApprovals)
 	AIO.Flags().AddFlagSet(fProfiler)
 	AIO.Flags().AddFlagSet(fCORS)
 	AIO.Flags().AddFlagSet(fAgentAuth)
@@ -61,7 +65,14 @@ var AIO = &cobra.Command{
 			return fmt.Errorf("unable to build auth: %w", err)
 		}
 
-		policer, penforce, err := makePolicer()
+		mm := startHealthServer(ctx)
+
+		am, err := startApprovalsServer(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to start approvals server: %w", err)
+		}
+
+		policer, penforce, err := makePolicer(cmd.Context(), mm)
 		if err != nil {
 			return fmt.Errorf("unable to make policer: %w", err)
 		}
@@ -78,12 +89,26 @@ var AIO = &cobra.Command{
 
 		corsPolicy := makeCORSPolicy()
 
-		mcpClient, err := makeMCPClient(args, true)
+		mcpClient, err := makeMCPClient(args, true, mm)
 		if err != nil {
 			return fmt.Errorf("unable to create MCP client: %w", err)
 		}
+		defer closeMCPClient(mcpClient)
 
-		mm := startHealthServer(ctx)
+		sharedServer := viper.GetBool("shared-server")
+		if _, ok := mcpClient.(client.RemoteClient); ok && sharedServer {
+			return fmt.Errorf("cannot use --shared-server with a remote MCP server")
+		}
+
+		egressAllowlist, err := makeEgressAllowlist(mcpClient)
+		if err != nil {
+			return err
+		}
+
+		toolTags, err := makeToolTags()
+		if err != nil {
+			return err
+		}
 
 		listener := memconn.NewListener()
 		defer func() { _ = listener.Close() }()
@@ -105,6 +130,10 @@ var AIO = &cobra.Command{
 				backend.OptSBOM(sbom),
 				backend.OptMetricsManager(mm),
 				backend.OptTracer(tracer),
+				backend.OptSharedServer(sharedServer),
+				backend.OptEgressAllowlist(egressAllowlist),
+				backend.OptToolTags(toolTags),
+				backend.OptApprovals(am),
 			)
 
 			return mbackend.Start(ctx)
@@ -8,6 +8,7 @@ import (
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"go.acuvity.ai/minibridge/pkgs/backend"
+	"go.acuvity.ai/minibridge/pkgs/backend/client"
 )
 
 var fBackend = pflag.NewFlagSet("backend", pflag.ExitOnError)
@@ -17,11 +18,14 @@ func init() {
 	initSharedFlagSet()
 
 	fBackend.StringP("listen", "l", ":8000", "listen address of the bridge for incoming websocket connections.")
+	fBackend.Bool("shared-server", false, "if set, all agents share a single MCP server instance instead of one per connection.")
+	fBackend.StringSlice("mcp-egress-allow", nil, "if set, run an egress proxy for the MCP server only allowing these domains (example.com, *.example.com), IPs or CIDRs. the proxy is advisory: the MCP server can ignore it unless its network is isolated.")
 
 	Backend.Flags().AddFlagSet(fBackend)
 	Backend.Flags().AddFlagSet(fPolicer)
 	Backend.Flags().AddFlagSet(fTLSServer)
 	Backend.Flags().AddFlagSet(fHealth)
+	Backend.Flags().AddFlagSet(fApprovals)
 	Backend.Flags().AddFlagSet(fProfiler)
 	Backend.Flags().AddFlagSet(fCORS)
 	Backend.Flags().AddFlagSet(fSBOM)
@@ -50,7 +54,14 @@ var Backend = &cobra.Command{
 			return err
 		}
 
-		policer, penforce, err := makePolicer()
+		mm := startHealthServer(cmd.Context())
+
+		am, err := startApprovalsServer(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("unable to start approvals server: %w", err)
+		}
+
+		policer, penforce, err := makePolicer(cmd.Context(), mm)
 		if err != nil {
 			return fmt.Errorf("unable to make policer: %w", err)
 		}
@@ -67,12 +78,26 @@ var Backend = &cobra.Command{
 
 		corsPolicy := makeCORSPolicy()
 
-		mcpClient, err := makeMCPClient(args, true)
+		mcpClient, err := makeMCPClient(args, true, mm)
 		if err != nil {
 			return fmt.Errorf("unable to create MCP client: %w", err)
 		}
+		defer closeMCPClient(mcpClient)
 
-		mm := startHealthServer(cmd.Context())
+		sharedServer := viper.GetBool("shared-server")
+		if _, ok := mcpClient.(client.RemoteClient); ok && sharedServer {
+			return fmt.Errorf("cannot use --shared-server with a remote MCP server")
+		}
+
+		egressAllowlist, err := makeEgressAllowlist(mcpClient)
+		if err != nil {
+			return err
+		}
+
+		toolTags, err := makeToolTags()
+		if err != nil {
+			return err
+		}
 
 		slog.Info("Minibridge backend configured",
 			"server-tls", backendTLSConfig != nil,
@@ -88,6 +113,10 @@ var Backend = &cobra.Command{
 			backend.OptSBOM(sbom),
 			backend.OptMetricsManager(mm),
 			backend.OptTracer(tracer),
+			backend.OptSharedServer(sharedServer),
+			backend.OptEgressAllowlist(egressAllowlist),
+			backend.OptToolTags(toolTags),
+			backend.OptApprovals(am),
 		)
 
 		return proxy.Start(cmd.Context())
@@ -1,7 +1,11 @@
 package cmd
 
 import (
+	"strings"
+	"time"
+
 	"github.com/spf13/pflag"
+	"go.acuvity.ai/minibridge/pkgs/policer"
 )
 
 var (
@@ -9,6 +13,7 @@ var (
 	fTLSServer = pflag.NewFlagSet("tlsserver", pflag.ExitOnError)
 	fProfiler  = pflag.NewFlagSet("profile", pflag.ExitOnError)
 	fHealth    = pflag.NewFlagSet("health", pflag.ExitOnError)
+	fApprovals = pflag.NewFlagSet("approvals", pflag.ExitOnError)
 	fPolicer   = pflag.NewFlagSet("policer", pflag.ExitOnError)
 	fCORS      = pflag.NewFlagSet("cors", pflag.ExitOnError)
 	fAgentAuth = pflag.NewFlagSet("agentauth", pflag.ExitOnError)
@@ -39,10 +44,33 @@ func initSharedFlagSet() {
 
 	fHealth.String("health-listen", "", "if set, start health server on that address.")
 
-	fPolicer.StringP("policer-type", "P", "", "type of policer to use. 'rego' or 'http'.")
+	fApprovals.String("approvals-listen", "", "if set, start the approvals API on that address. tool calls pending approval are denied if not set.")
+	fApprovals.Duration("approvals-timeout", 5*time.Minute, "duration after which a tool call pending approval is denied.")
+	fApprovals.String("approvals-token", "", "token the clients of the approvals API must send using Bearer scheme. required when --approvals-listen is not a loopback address.")
+
+	fPolicer.StringP("policer-type", "P", "", "type of policer to use. 'rego', 'rules', 'secrets', 'pii', 'injection' or 'http'. a comma separated list, like 'rego,http', runs them in order.")
 	fPolicer.Bool("policer-enforce", true, "enforce policy or only log verdict.")
-	fPolicer.String("policer-rego-policy", "", "path to a rego policy file for the rego policer.")
+	fPolicer.String("policer-chain-mode", "first-deny", "when using several policers, first-deny stops at the first denial, all-must-allow runs them all and reports all the denials.")
+	fPolicer.StringSlice("policer-log-only", nil, "when using several policers, types of the policers whose denials are only logged.")
+	fPolicer.Duration("policer-cache-ttl", 0, "if set, cache the policer decisions of identical calls for that duration.")
+	fPolicer.Int64("policer-cache-size", 1024, "maximum number of cached policer decisions.")
+	fPolicer.String("policer-rego-policy", "", "path to a rego policy file, a directory or an OPA bundle for the rego policer.")
+	fPolicer.Bool("policer-rego-watch", true, "reload the rego policies when they change. a policy that fails to compile is ignored.")
+	fPolicer.String("policer-rules-file", "", "path to a YAML or JSON rules file for the rules policer.")
+	fPolicer.String("policer-secrets-mode", "redact", "what the secrets policer does with the secrets found. 'redact' replaces them, 'block' denies the message.")
+	fPolicer.StringSlice("policer-secrets-detectors", nil, "secrets detectors to use, among "+strings.Join(policer.SecretsDetectors(), ", ")+". all are used if not set.")
+	fPolicer.StringSlice("policer-pii-entities", nil, "PII entities to process, among "+strings.Join(policer.PIIEntities(), ", ")+". all are processed if not set.")
+	fPolicer.String("policer-pii-mode", "mask", "how the PII policer processes the entities. 'mask', 'hash', or 'tokenize' to replace them by tokens that are restored when sent back by the agent in the same session.")
+	fPolicer.StringToString("policer-pii-entity-modes", nil, "modes of given PII entities, overriding --policer-pii-mode, like email=tokenize,credit-card=mask.")
+	fPolicer.String("policer-pii-hash-key", "", "key used to hash and tokenize the PII entities. a random key is used if not set.")
+	fPolicer.String("policer-injection-mode", "block", "what the injection policer does with the prompt injections found in the tool descriptions and results. 'block' denies the message, 'strip' removes them.")
 	fPolicer.String("policer-http-url", "", "URL of the HTTP policer to POST agent policing requests.")
+	fPolicer.Duration("policer-http-timeout", 10*time.Second, "maximum duration of a single call to the HTTP policer. 0 means no timeout.")
+	fPolicer.Int("policer-http-retries", 2, "number of retries when the HTTP policer is unreachable or returns a server error.")
+	fPolicer.Duration("policer-http-retry-backoff", 100*time.Millisecond, "initial backoff between retries, doubled and jittered at each retry.")
+	fPolicer.Int("policer-http-breaker-threshold", 5, "number of consecutive failures after which the HTTP policer is not called for the breaker cooldown. 0 disables the circuit breaker.")
+	fPolicer.Duration("policer-http-breaker-cooldown", 30*time.Second, "duration during which the HTTP policer is not called once the circuit breaker is open.")
+	fPolicer.String("policer-http-fail-mode", "closed", "what to do when the HTTP policer cannot be reached or keeps failing. 'closed' denies the request, 'open' allows it.")
 	fPolicer.String("policer-http-bearer-token", "", "token to use to authenticate against the HTTP policer using Bearer scheme.")
 	fPolicer.String("policer-http-basic-user", "", "user to use to authenticate against the HTTP policer using Basic scheme.")
 	fPolicer.String("policer-http-basic-pass", "", "password to use to authenticate against the HTTP policer using Basic scheme.")
@@ -62,6 +90,24 @@ func initSharedFlagSet() {
 	fMCP.Int("mcp-gid", -1, "if greater than -1, use as GID to run the MCP server command.")
 	fMCP.IntSlice("mcp-groups", nil, "additional GIDs to to run the MCP server command.")
 	fMCP.Bool("mcp-use-tempdir", false, "if set, create a new temp execution dir for each MCP server instance.")
-	fMCP.String("mcp-tls-ca", "", "when using SSE, path to a CA to valide MCP server server certificates.")
+	fMCP.Bool("mcp-sandbox", false, "if set, run the MCP server in new linux namespaces with a read-only root and a private /tmp.")
+	fMCP.String("mcp-seccomp", "", "if set, filter the syscalls of the MCP server using a seccomp profile: default, no-network, no-exec-after-start or a path to a JSON profile.")
+	fMCP.Bool("mcp-landlock", false, "if set, restrict the MCP server filesystem access using landlock: read-only access to --mcp-landlock-ro and read-write access to its working dir and --mcp-landlock-rw.")
+	fMCP.StringSlice("mcp-landlock-ro", []string{"/bin", "/sbin", "/usr", "/lib", "/lib64", "/etc", "/opt", "/proc", "/dev"}, "paths the MCP server can read when using --mcp-landlock.")
+	fMCP.StringSlice("mcp-landlock-rw", []string{"/dev/null", "/dev/tty"}, "paths the MCP server can write when using --mcp-landlock, in addition to its working dir.")
+	fMCP.String("mcp-memory-limit", "", "if set, maximum memory the MCP server can use, like 512M or 2G. requires cgroup v2.")
+	fMCP.Float64("mcp-cpu-limit", 0, "if greater than 0, maximum number of CPUs the MCP server can use, like 0.5. requires cgroup v2.")
+	fMCP.Int64("mcp-pids-limit", 0, "if greater than 0, maximum number of processes and threads the MCP server can use. requires cgroup v2.")
+	fMCP.StringSlice("mcp-io-limit", nil, "io limits of the MCP server for a block device, in the form <device>:rbps=10M,wbps=10M,riops=100,wiops=100. requires cgroup v2.")
+	fMCP.String("mcp-cgroup-parent", "", "cgroup, relative to the cgroup v2 root, in which the MCP server cgroups are created. defaults to the minibridge cgroup, which must then only contain minibridge. use a delegated cgroup otherwise.")
+	fMCP.StringArray("mcp-env", nil, "environment variables passed to the MCP server, as KEY=VALUE. values can reference ${VAR}, ${file:/path} or ${keyring:service/user}. only the names are logged.")
+	fMCP.Bool("mcp-clean-env", false, "if set, the MCP server does not inherit the environment of minibridge, except for the variables in --mcp-env-allow.")
+	fMCP.StringSlice("mcp-env-allow", []string{"PATH", "HOME", "USER", "LANG", "LC_*", "TZ", "TMPDIR", "TERM"}, "variables inherited by the MCP server when using --mcp-clean-env. a trailing * matches a prefix.")
+	fMCP.Int("mcp-max-restarts", 0, "if greater than 0, restart the MCP server when it exits unexpectedly, up to this number of consecutive times, without disconnecting the agents.")
+	fMCP.Int("mcp-pool-size", 0, "if greater than 0, keep this number of idle MCP server instances started and ready to be handed to new agents. cannot be used with --mcp-egress-allow.")
+	fMCP.Int("mcp-pool-max", 0, "if greater than 0, maximum number of MCP server instances alive at once when using --mcp-pool-size. new agents are refused once reached.")
+	fMCP.StringSlice("mcp-tool-tag", nil, "tag of the MCP server tools matching a pattern, like 'read_*=sensitive' or 'post_*=external'. once a sensitive tool returned content, the external tools are refused for the rest of the session.")
+	fMCP.String("mcp-transport", "sse", "when using a remote MCP server, the transport to use: sse (2024-11-05) or http (streamable, 2025-03-26).")
+	fMCP.String("mcp-tls-ca", "", "when using a remote MCP server, path to a CA to valide MCP server server certificates.")
 	fMCP.Bool("mcp-tls-insecure-skip-verify", false, "skip MCP server certificates validation. INSECURE.")
 }
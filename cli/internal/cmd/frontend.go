@@ -56,7 +56,7 @@ var Frontend = &cobra.Command{
 		if !strings.HasPrefix(backendURL, "wss://") && !strings.HasPrefix(backendURL, "ws://") {
 			return fmt.Errorf("--backend must use wss:// or ws:// scheme")
 		}
-		if !strings.HasSuffix(backendURL, "/ws") {
+		if !strings.HasSuffix(backendURL, "/ws") && !strings.Contains(backendURL, "/ws/") {
 			backendURL = backendURL + "/ws"
 		}
 
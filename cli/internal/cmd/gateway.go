@@ -0,0 +1,367 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"go.acuvity.ai/minibridge/pkgs/backend"
+	"go.acuvity.ai/minibridge/pkgs/backend/client"
+	"go.acuvity.ai/minibridge/pkgs/metrics"
+)
+
+var fGateway = pflag.NewFlagSet("gateway", pflag.ExitOnError)
+
+func init() {
+
+	initSharedFlagSet()
+
+	fGateway.String("gateway-config", "", "path to the YAML file describing the MCP servers exposed by the gateway.")
+
+	Gateway.Flags().AddFlagSet(fGateway)
+	Gateway.Flags().AddFlagSet(fBackend)
+	Gateway.Flags().AddFlagSet(fPolicer)
+	Gateway.Flags().AddFlagSet(fTLSServer)
+	Gateway.Flags().AddFlagSet(fHealth)
+	Gateway.Flags().AddFlagSet(fApprovals)
+	Gateway.Flags().AddFlagSet(fProfiler)
+	Gateway.Flags().AddFlagSet(fCORS)
+	Gateway.Flags().AddFlagSet(fSBOM)
+	Gateway.Flags().AddFlagSet(fMCP)
+}
+
+// gatewayServerConfig is the configuration of
+// a server exposed by the gateway.
+type gatewayServerConfig struct {
+	Name      string         `mapstructure:"name"`
+	Command   []string       `mapstructure:"command"`
+	URL       string         `mapstructure:"url"`
+	Upstreams []string       `mapstructure:"upstreams"`
+	Flags     map[string]any `mapstructure:"flags"`
+}
+
+// Gateway is the cobra command to run the gateway.
+var Gateway = &cobra.Command{
+	Use:   "gateway [flags]",
+	Short: "Start a minibridge backend exposing several MCP servers",
+	Long: `Start a minibridge backend exposing several MCP servers.
+
+The servers are described in the file given by --gateway-config:
+
+  servers:
+    - name: fetch
+      command: [uvx, mcp-server-fetch]
+      flags:
+        mcp-sandbox: true
+        policer-type: rego
+        policer-rego-policy: fetch.rego
+    - name: remote
+      url: https://mcp.example.com/mcp
+    - name: toolbox
+      upstreams: [fetch, remote]
+
+Each server is reachable at /ws/{name}. The flags of a server override
+the flags given to the command, which apply to all servers.
+
+A server with upstreams aggregates the other servers as a single one,
+exposing their tools, prompts and resources prefixed by their names.`,
+	SilenceUsage:     true,
+	SilenceErrors:    true,
+	TraverseChildren: true,
+	Args:             cobra.NoArgs,
+
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		listen := viper.GetString("listen")
+		configPath := viper.GetString("gateway-config")
+
+		if listen == "" {
+			return fmt.Errorf("--listen must be set")
+		}
+
+		if configPath == "" {
+			return fmt.Errorf("--gateway-config must be set")
+		}
+
+		configs, err := readGatewayConfig(configPath)
+		if err != nil {
+			return err
+		}
+
+		backendTLSConfig, err := tlsConfigFromFlags(fTLSServer)
+		if err != nil {
+			return err
+		}
+
+		tracer, err := makeTracer(cmd.Context(), "backend")
+		if err != nil {
+			return fmt.Errorf("unable to configure tracer: %w", err)
+		}
+
+		corsPolicy := makeCORSPolicy()
+
+		mm := startHealthServer(cmd.Context())
+
+		am, err := startApprovalsServer(cmd.Context())
+		if err != nil {
+			return fmt.Errorf("unable to start approvals server: %w", err)
+		}
+
+		// Aggregates are configured after their upstreams,
+		// as they use the clients of the other servers.
+		configs, err = sortGatewayConfigs(configs)
+		if err != nil {
+			return err
+		}
+
+		servers := make([]backend.GatewayServer, 0, len(configs))
+		clients := make(map[string]client.Client, len(configs))
+		for _, c := range configs {
+
+			srv, err := makeGatewayServer(cmd.Context(), c, clients, mm)
+			if err != nil {
+				return fmt.Errorf("unable to configure server '%s': %w", c.Name, err)
+			}
+
+			defer closeMCPClient(srv.Client)
+
+			servers = append(servers, srv)
+			clients[c.Name] = srv.Client
+		}
+
+		slog.Info("Minibridge gateway configured",
+			"server-tls", backendTLSConfig != nil,
+			"server-mtls", mtlsMode(backendTLSConfig),
+			"listen", listen,
+			"servers", len(servers),
+		)
+
+		proxy, err := backend.NewGateway(listen, backendTLSConfig, servers,
+			backend.OptDumpStderrOnError(viper.GetString("log-format") != "json"),
+			backend.OptCORSPolicy(corsPolicy),
+			backend.OptMetricsManager(mm),
+			backend.OptTracer(tracer),
+			backend.OptApprovals(am),
+		)
+		if err != nil {
+			return fmt.Errorf("unable to create gateway: %w", err)
+		}
+
+		return proxy.Start(cmd.Context())
+	},
+}
+
+func readGatewayConfig(path string) ([]gatewayServerConfig, error) {
+
+	v := viper.New()
+	v.SetConfigFile(path)
+
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("unable to read gateway config: %w", err)
+	}
+
+	configs := []gatewayServerConfig{}
+	if err := v.UnmarshalKey("servers", &configs); err != nil {
+		return nil, fmt.Errorf("unable to decode gateway config: %w", err)
+	}
+
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("gateway config must define at least one server")
+	}
+
+	return configs, nil
+}
+
+// sortGatewayConfigs returns the given configs ordered so that each
+// server comes after its upstreams. The order of the file is kept
+// otherwise. Unknown upstreams are left to be reported when the
+// aggregate is built.
+func sortGatewayConfigs(configs []gatewayServerConfig) ([]gatewayServerConfig, error) {
+
+	byName := make(map[string]gatewayServerConfig, len(configs))
+	for _, c := range configs {
+		if _, ok := byName[c.Name]; ok {
+			return nil, fmt.Errorf("duplicate server name '%s'", c.Name)
+		}
+		byName[c.Name] = c
+	}
+
+	sorted := make([]gatewayServerConfig, 0, len(configs))
+	done := make(map[string]bool, len(configs))
+	path := []string{}
+
+	var visit func(c gatewayServerConfig) error
+	visit = func(c gatewayServerConfig) error {
+
+		if done[c.Name] {
+			return nil
+		}
+
+		for i, name := range path {
+			if name == c.Name {
+				return fmt.Errorf("upstream cycle: %s", strings.Join(append(path[i:], c.Name), " -> "))
+			}
+		}
+
+		path = append(path, c.Name)
+		for _, name := range c.Upstreams {
+			if u, ok := byName[name]; ok {
+				if err := visit(u); err != nil {
+					return err
+				}
+			}
+		}
+		path = path[:len(path)-1]
+
+		done[c.Name] = true
+		sorted = append(sorted, c)
+
+		return nil
+	}
+
+	for _, c := range configs {
+		if err := visit(c); err != nil {
+			return nil, fmt.Errorf("unable to order gateway servers: %w", err)
+		}
+	}
+
+	return sorted, nil
+}
+
+// makeGatewayServer builds the backend.GatewayServer described by the
+// given config. The flags of the server temporarily override the ones
+// given to the command while its policer, sbom and client are created.
+func makeGatewayServer(ctx context.Context, c gatewayServerConfig, clients map[string]client.Client, mm *metrics.Manager) (backend.GatewayServer, error) {
+
+	srv := backend.GatewayServer{Name: c.Name}
+
+	var args []string
+	switch {
+	case len(c.Command) > 0 && c.URL != "",
+		len(c.Upstreams) > 0 && (len(c.Command) > 0 || c.URL != ""):
+		return srv, fmt.Errorf("command, url and upstreams are mutually exclusive")
+	case len(c.Command) > 0:
+		args = c.Command
+	case c.URL != "":
+		args = []string{c.URL}
+	case len(c.Upstreams) == 0:
+		return srv, fmt.Errorf("command, url or upstreams must be set")
+	}
+
+	restore, err := overrideFlags(c.Flags)
+	if err != nil {
+		return srv, err
+	}
+	defer restore()
+
+	policer, penforce, err := makePolicer(ctx, mm)
+	if err != nil {
+		return srv, fmt.Errorf("unable to make policer: %w", err)
+	}
+
+	sbom, err := makeSBOM()
+	if err != nil {
+		return srv, fmt.Errorf("unable to make hashes: %w", err)
+	}
+
+	var mcpClient client.Client
+	if len(c.Upstreams) > 0 {
+		mcpClient, err = makeAggregateClient(c.Upstreams, clients)
+	} else {
+		mcpClient, err = makeMCPClient(args, true, mm)
+	}
+	if err != nil {
+		return srv, fmt.Errorf("unable to create MCP client: %w", err)
+	}
+
+	sharedServer := viper.GetBool("shared-server")
+	if _, ok := mcpClient.(client.RemoteClient); ok && sharedServer {
+		return srv, fmt.Errorf("cannot use shared-server with a remote MCP server")
+	}
+
+	egressAllowlist, err := makeEgressAllowlist(mcpClient)
+	if err != nil {
+		return srv, err
+	}
+
+	toolTags, err := makeToolTags()
+	if err != nil {
+		return srv, err
+	}
+
+	srv.Client = mcpClient
+	srv.Options = []backend.Option{
+		backend.OptPolicer(policer),
+		backend.OptPolicerEnforce(penforce),
+		backend.OptSBOM(sbom),
+		backend.OptSharedServer(sharedServer),
+		backend.OptEgressAllowlist(egressAllowlist),
+		backend.OptToolTags(toolTags),
+	}
+
+	return srv, nil
+}
+
+func makeAggregateClient(names []string, clients map[string]client.Client) (client.Client, error) {
+
+	upstreams := make([]client.Upstream, 0, len(names))
+	for _, name := range names {
+
+		cl, ok := clients[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown upstream '%s'", name)
+		}
+
+		upstreams = append(upstreams, client.Upstream{Name: name, Client: cl})
+	}
+
+	slog.Info("MCP server configured", "mode", "aggregate", "upstreams", names)
+
+	return client.NewAggregate(upstreams)
+}
+
+// overrideFlags sets the given flags in viper, and returns
+// a function restoring their previous values. Only the flags
+// that can be set for a single server are accepted.
+func overrideFlags(flags map[string]any) (func(), error) {
+
+	previous := make(map[string]any, len(flags))
+	restore := func() {
+		for k, v := range previous {
+			viper.Set(k, v)
+		}
+	}
+
+	for k, v := range flags {
+
+		if !isGatewayServerFlag(k) {
+			restore()
+			return nil, fmt.Errorf("flag '%s' cannot be set per server", k)
+		}
+
+		previous[k] = viper.Get(k)
+		viper.Set(k, v)
+	}
+
+	return restore, nil
+}
+
+func isGatewayServerFlag(name string) bool {
+
+	if slices.Contains([]string{"shared-server", "mcp-egress-allow"}, name) {
+		return true
+	}
+
+	for _, fs := range []*pflag.FlagSet{fPolicer, fSBOM, fMCP} {
+		if fs.Lookup(name) != nil {
+			return true
+		}
+	}
+
+	return false
+}
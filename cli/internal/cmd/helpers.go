@@ -6,8 +6,11 @@ import (
 	"crypto/x509"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"net"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
@@ -15,8 +18,11 @@ import (
 	"github.com/spf13/viper"
 	"github.com/zalando/go-keyring"
 	"go.acuvity.ai/bahamut"
+	"go.acuvity.ai/minibridge/pkgs/approvals"
 	"go.acuvity.ai/minibridge/pkgs/auth"
+	"go.acuvity.ai/minibridge/pkgs/backend"
 	"go.acuvity.ai/minibridge/pkgs/backend/client"
+	"go.acuvity.ai/minibridge/pkgs/egress"
 	"go.acuvity.ai/minibridge/pkgs/frontend"
 	"go.acuvity.ai/minibridge/pkgs/metrics"
 	"go.acuvity.ai/minibridge/pkgs/oauth"
@@ -133,11 +139,128 @@ func startHealthServer(ctx context.Context) (manager *metrics.Manager) {
 	return manager
 }
 
-func makePolicer() (policer.Policer, bool, error) {
+func startApprovalsServer(ctx context.Context) (*approvals.Manager, error) {
 
-	pType := viper.GetString("policer-type")
+	approvalsListen := viper.GetString("approvals-listen")
+
+	if approvalsListen == "" {
+		return nil, nil
+	}
+
+	approvalsToken := viper.GetString("approvals-token")
+	if approvalsToken == "" && !isLoopback(approvalsListen) {
+		return nil, fmt.Errorf("--approvals-token must be set when --approvals-listen is not a loopback address")
+	}
+
+	manager := approvals.NewManager(
+		approvalsListen,
+		approvals.OptTimeout(viper.GetDuration("approvals-timeout")),
+		approvals.OptToken(approvalsToken),
+	)
+
+	go func() {
+		if err := manager.Start(ctx); err != nil {
+			slog.Error("unable to start approvals manager", "err", err)
+		}
+	}()
+
+	slog.Info("Approvals manager configured", "listen", approvalsListen, "timeout", viper.GetDuration("approvals-timeout"), "auth", approvalsToken != "")
+
+	return manager, nil
+}
+
+// isLoopback returns true if the given listen
+// address only accepts local connections.
+func isLoopback(listen string) bool {
+
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		return false
+	}
+
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
+
+func makePolicer(ctx context.Context, mm *metrics.Manager) (policer.Policer, bool, error) {
+
+	pTypes := viper.GetString("policer-type")
 	pEnforce := viper.GetBool("policer-enforce")
 
+	if pTypes == "" {
+		return nil, false, nil
+	}
+
+	types := strings.Split(pTypes, ",")
+	if len(types) == 1 {
+		p, err := makePolicerOfType(ctx, pTypes, pEnforce, mm)
+		if err != nil {
+			return nil, false, err
+		}
+		return withPolicerCache(p, mm), pEnforce, nil
+	}
+
+	mode := policer.ChainMode(viper.GetString("policer-chain-mode"))
+	logOnly := viper.GetStringSlice("policer-log-only")
+
+	stages := make([]policer.ChainStage, len(types))
+	for i, t := range types {
+
+		t = strings.TrimSpace(t)
+		stageLogOnly := slices.Contains(logOnly, t)
+
+		p, err := makePolicerOfType(ctx, t, pEnforce && !stageLogOnly, mm)
+		if err != nil {
+			return nil, false, err
+		}
+
+		stages[i] = policer.ChainStage{
+			Name:    t,
+			Policer: p,
+			LogOnly: stageLogOnly,
+		}
+	}
+
+	chain, err := policer.NewChain(stages,
+		policer.OptChainMode(mode),
+		policer.OptChainMetricsManager(mm),
+	)
+	if err != nil {
+		return nil, false, fmt.Errorf("unable to make policer chain: %w", err)
+	}
+
+	slog.Info("Policer chain configured", "stages", types, "mode", mode, "log-only", logOnly, "enforced", pEnforce)
+
+	return withPolicerCache(chain, mm), pEnforce, nil
+}
+
+// withPolicerCache wraps the given policer in a
+// decision cache, if --policer-cache-ttl is set.
+func withPolicerCache(p policer.Policer, mm *metrics.Manager) policer.Policer {
+
+	ttl := viper.GetDuration("policer-cache-ttl")
+	size := viper.GetInt64("policer-cache-size")
+
+	if ttl <= 0 {
+		return p
+	}
+
+	slog.Info("Policer cache configured", "ttl", ttl, "size", size)
+
+	return policer.NewCache(p,
+		policer.OptCacheTTL(ttl),
+		policer.OptCacheMaxSize(size),
+		policer.OptCacheMetricsManager(mm),
+	)
+}
+
+func makePolicerOfType(ctx context.Context, pType string, pEnforce bool, mm *metrics.Manager) (policer.Policer, error) {
+
 	switch pType {
 
 	case "http":
@@ -148,17 +271,22 @@ func makePolicer() (policer.Policer, bool, error) {
 		httpUser := viper.GetString("policer-http-basic-user")
 		httpPassword := viper.GetString("policer-http-basic-pass")
 		httpToken := viper.GetString("policer-http-bearer-token")
+		httpFailMode := policer.HTTPFailMode(viper.GetString("policer-http-fail-mode"))
 
 		if httpURL == "" {
-			return nil, false, fmt.Errorf("you must set --policer-http-url when using an http policer")
+			return nil, fmt.Errorf("you must set --policer-http-url when using an http policer")
+		}
+
+		if httpFailMode != policer.HTTPFailModeClosed && httpFailMode != policer.HTTPFailModeOpen {
+			return nil, fmt.Errorf("--policer-http-fail-mode must be 'closed' or 'open'")
 		}
 
 		if (httpUser != "" && httpPassword == "") || (httpUser == "" && httpPassword != "") {
-			return nil, false, fmt.Errorf("you must set both --policer-http-basic-user and --policer-http-basic-passw")
+			return nil, fmt.Errorf("you must set both --policer-http-basic-user and --policer-http-basic-passw")
 		}
 
 		if httpUser != "" && httpToken != "" {
-			return nil, false, fmt.Errorf("if you set --policer-http-bearer-token, you can't --policer-http-basic-*")
+			return nil, fmt.Errorf("if you set --policer-http-bearer-token, you can't --policer-http-basic-*")
 		}
 
 		var a *auth.Auth
@@ -172,14 +300,15 @@ func makePolicer() (policer.Policer, bool, error) {
 		if httpCA != "" {
 			caData, err := os.ReadFile(httpCA) // #nosec: G304
 			if err != nil {
-				return nil, false, fmt.Errorf("unable to read policer CA: %w", err)
+				return nil, fmt.Errorf("unable to read policer CA: %w", err)
 			}
+			pool = x509.NewCertPool()
 			pool.AppendCertsFromPEM(caData)
 		} else {
 			var err error
 			pool, err = x509.SystemCertPool()
 			if err != nil {
-				return nil, false, fmt.Errorf("unable to load system ca pool: %w", err)
+				return nil, fmt.Errorf("unable to load system ca pool: %w", err)
 			}
 		}
 
@@ -188,40 +317,101 @@ func makePolicer() (policer.Policer, bool, error) {
 			RootCAs:            pool,
 		}
 
-		slog.Info("Policer configured", "type", "http", "url", httpURL, "enforced", pEnforce, "auth")
+		slog.Info("Policer configured", "type", "http", "url", httpURL, "enforced", pEnforce, "fail-mode", httpFailMode, "auth")
 		if a != nil {
 			slog.Info("Policer auth enabled", "type", a.Type(), "user", a.User(), "password", a.Password() != "")
 		}
 
-		return policer.NewHTTP(httpURL, a, tlsConfig), pEnforce, nil
+		return policer.NewHTTP(httpURL, a, tlsConfig,
+			policer.OptHTTPTimeout(viper.GetDuration("policer-http-timeout")),
+			policer.OptHTTPRetries(viper.GetInt("policer-http-retries"), viper.GetDuration("policer-http-retry-backoff")),
+			policer.OptHTTPCircuitBreaker(viper.GetInt("policer-http-breaker-threshold"), viper.GetDuration("policer-http-breaker-cooldown")),
+			policer.OptHTTPFailMode(httpFailMode),
+		), nil
 
 	case "rego":
 
-		regoFile := viper.GetString("policer-rego-policy")
+		regoPath := viper.GetString("policer-rego-policy")
+		regoWatch := viper.GetBool("policer-rego-watch")
+
+		if regoPath == "" {
+			return nil, fmt.Errorf("you must set --policer-rego-policy when using a rego policer")
+		}
+
+		slog.Info("Policer configured", "type", "rego", "policy", regoPath, "watch", regoWatch, "enforced", pEnforce)
 
-		if regoFile == "" {
-			return nil, false, fmt.Errorf("you must set --policer-rego-policy when using a rego policer")
+		return policer.NewRegoFromPath(ctx, regoPath, policer.OptRegoWatch(regoWatch))
+
+	case "rules":
+
+		rulesFile := viper.GetString("policer-rules-file")
+
+		if rulesFile == "" {
+			return nil, fmt.Errorf("you must set --policer-rules-file when using a rules policer")
 		}
 
-		data, err := os.ReadFile(regoFile) // #nosec: G304
+		data, err := os.ReadFile(rulesFile) // #nosec: G304
 		if err != nil {
-			return nil, false, fmt.Errorf("unable open rego policy file: %w", err)
+			return nil, fmt.Errorf("unable to read rules file: %w", err)
 		}
 
-		slog.Info("Policer configured", "type", "rego", "policy", regoFile, "enforced", pEnforce)
+		slog.Info("Policer configured", "type", "rules", "rules", rulesFile, "enforced", pEnforce)
 
-		p, err := policer.NewRego(string(data))
-		if err != nil {
-			return nil, false, err
+		return policer.NewRules(string(data))
+
+	case "secrets":
+
+		mode := policer.DetectionMode(viper.GetString("policer-secrets-mode"))
+		detectors := viper.GetStringSlice("policer-secrets-detectors")
+
+		if mode != policer.DetectionModeRedact && mode != policer.DetectionModeBlock {
+			return nil, fmt.Errorf("--policer-secrets-mode must be 'redact' or 'block'")
 		}
 
-		return p, pEnforce, nil
+		slog.Info("Policer configured", "type", "secrets", "mode", mode, "detectors", detectors, "enforced", pEnforce)
 
-	case "":
-		return nil, false, nil
+		return policer.NewSecrets(detectors,
+			policer.OptDetectionMode(mode),
+			policer.OptDetectionMetricsManager(mm),
+		)
+
+	case "pii":
+
+		entities := viper.GetStringSlice("policer-pii-entities")
+		mode := policer.PIIMode(viper.GetString("policer-pii-mode"))
+		hashKey := viper.GetString("policer-pii-hash-key")
+
+		entityModes := map[string]policer.PIIMode{}
+		for e, m := range viper.GetStringMapString("policer-pii-entity-modes") {
+			entityModes[e] = policer.PIIMode(m)
+		}
+
+		opts := []policer.PIIOption{
+			policer.OptPIIMode(mode),
+			policer.OptPIIEntityModes(entityModes),
+			policer.OptPIIMetricsManager(mm),
+		}
+		if hashKey != "" {
+			opts = append(opts, policer.OptPIIHashKey([]byte(hashKey)))
+		}
+
+		slog.Info("Policer configured", "type", "pii", "mode", mode, "entities", entities, "entity-modes", entityModes, "enforced", pEnforce)
+
+		return policer.NewPII(entities, opts...)
+
+	case "injection":
+
+		mode := policer.InjectionMode(viper.GetString("policer-injection-mode"))
+
+		slog.Info("Policer configured", "type", "injection", "mode", mode, "enforced", pEnforce)
+
+		return policer.NewInjection(
+			policer.OptInjectionMode(mode),
+			policer.OptInjectionMetricsManager(mm),
+		)
 
 	default:
-		return nil, false, fmt.Errorf("unknown type of policer: %s", pType)
+		return nil, fmt.Errorf("unknown type of policer: %s", pType)
 	}
 }
 
@@ -378,7 +568,7 @@ func makeTracer(ctx context.Context, name string) (trace.Tracer, error) {
 	return tp.Tracer(name), nil
 }
 
-func makeMCPClient(args []string, log bool) (client.Client, error) {
+func makeMCPClient(args []string, log bool, mm *metrics.Manager) (client.Client, error) {
 
 	ca := viper.GetString("mcp-tls-ca")
 	skip := viper.GetBool("mcp-tls-insecure-skip-verify")
@@ -386,14 +576,32 @@ func makeMCPClient(args []string, log bool) (client.Client, error) {
 	gid := viper.GetInt("mcp-gid")
 	groups := viper.GetIntSlice("mcp-groups")
 	tmp := viper.GetBool("mcp-use-tempdir")
+	transport := viper.GetString("mcp-transport")
+	sandbox := viper.GetBool("mcp-sandbox")
+	seccomp := viper.GetString("mcp-seccomp")
+	landlock := viper.GetBool("mcp-landlock")
+	env := viper.GetStringSlice("mcp-env")
+	cleanEnv := viper.GetBool("mcp-clean-env")
+	maxRestarts := viper.GetInt("mcp-max-restarts")
+	poolSize := viper.GetInt("mcp-pool-size")
+	poolMax := viper.GetInt("mcp-pool-max")
+
+	limits, err := makeResourceLimits()
+	if err != nil {
+		return nil, err
+	}
 
 	switch {
 
-	// args[0] is an URL, we'll use SSE to connect to the MCP Server
+	// args[0] is an URL, we'll use SSE or Streamable HTTP to connect to the MCP Server
 	case strings.HasPrefix(args[0], "http://") || strings.HasPrefix(args[0], "https://"):
 
-		if uid != -1 || gid != -1 || len(groups) > 0 || tmp {
-			return nil, fmt.Errorf("cannot use --mcp-uid, --mcp-gid, --mcp-groups or --mcp-use-tempdir when using SSE")
+		if uid != -1 || gid != -1 || len(groups) > 0 || tmp || sandbox || seccomp != "" || landlock || !limits.IsZero() || len(env) > 0 || cleanEnv || maxRestarts > 0 || poolSize > 0 {
+			return nil, fmt.Errorf("cannot use --mcp-uid, --mcp-gid, --mcp-groups, --mcp-use-tempdir, --mcp-sandbox, --mcp-seccomp, --mcp-landlock, --mcp-env, --mcp-clean-env, --mcp-max-restarts, --mcp-pool-size or resource limits when using a remote MCP server")
+		}
+
+		if transport != "sse" && transport != "http" {
+			return nil, fmt.Errorf("invalid --mcp-transport '%s': must be 'sse' or 'http'", transport)
 		}
 
 		var tlsConfig *tls.Config
@@ -423,7 +631,11 @@ func makeMCPClient(args []string, log bool) (client.Client, error) {
 			l = slog.Debug
 		}
 
-		l("MCP server configured", "mode", "sse", "url", args[0])
+		l("MCP server configured", "mode", transport, "url", args[0])
+
+		if transport == "http" {
+			return client.NewStreamableHTTP(args[0], tlsConfig), nil
+		}
 
 		return client.NewSSE(args[0], tlsConfig), nil
 
@@ -434,8 +646,45 @@ func makeMCPClient(args []string, log bool) (client.Client, error) {
 			return nil, fmt.Errorf("cannot use --mcp-tls-ca or mcp-tls-insecure-skip-verify when using STDIO")
 		}
 
+		if sandbox && (uid > -1 || gid > -1 || len(groups) > 0) {
+			return nil, fmt.Errorf("cannot use --mcp-uid, --mcp-gid or --mcp-groups with --mcp-sandbox")
+		}
+
 		opts := []client.StdioOption{
 			client.OptStdioUseTempDir(tmp),
+			client.OptStdioSandbox(sandbox),
+			client.OptStdioMetricsManager(mm),
+			client.OptStdioResourceLimits(limits),
+			client.OptStdioCgroupParent(viper.GetString("mcp-cgroup-parent")),
+		}
+
+		if landlock {
+			opts = append(opts, client.OptStdioLandlock(
+				viper.GetStringSlice("mcp-landlock-ro"),
+				viper.GetStringSlice("mcp-landlock-rw"),
+			))
+		}
+
+		if poolSize > 0 {
+			opts = append(opts, client.OptStdioPool(poolSize, poolMax))
+		}
+
+		if cleanEnv {
+			opts = append(opts, client.OptStdioCleanEnv(viper.GetStringSlice("mcp-env-allow")...))
+		}
+
+		// The env only holds --mcp-env, given by the
+		// operator, so its references can be resolved.
+		if len(env) > 0 {
+			opts = append(opts, client.OptStdioResolveEnv())
+		}
+
+		if seccomp != "" {
+			profile, err := makeSeccompProfile(seccomp)
+			if err != nil {
+				return nil, err
+			}
+			opts = append(opts, client.OptStdioSeccomp(profile))
 		}
 
 		l := slog.Info
@@ -447,9 +696,17 @@ func makeMCPClient(args []string, log bool) (client.Client, error) {
 			opts = append(opts, client.OptStdioCredentials(uid, gid, groups))
 		}
 
-		if uid > -1 || gid > -1 || len(groups) > 0 || tmp {
+		if uid > -1 || gid > -1 || len(groups) > 0 || tmp || sandbox || seccomp != "" || landlock || cleanEnv || !limits.IsZero() {
 			l("MCP server isolation",
 				"use-temp", tmp,
+				"sandbox", sandbox,
+				"seccomp", seccomp,
+				"landlock", landlock,
+				"clean-env", cleanEnv,
+				"memory-limit", limits.Memory,
+				"cpu-limit", limits.CPU,
+				"pids-limit", limits.PIDs,
+				"io-limits", len(limits.IO),
 				"uid", uid,
 				"gid", gid,
 				"groups", groups,
@@ -461,7 +718,22 @@ func makeMCPClient(args []string, log bool) (client.Client, error) {
 			return nil, fmt.Errorf("unable to create mcp server: %w", err)
 		}
 
-		l("MCP server configured", "mode", "stdio", "command", mcpsrv.Command, "args", mcpsrv.Args)
+		for _, e := range env {
+			if k, _, ok := strings.Cut(e, "="); !ok || k == "" {
+				return nil, fmt.Errorf("invalid --mcp-env '%s': must be KEY=VALUE", e)
+			}
+		}
+		mcpsrv.Env = env
+
+		l("MCP server configured", "mode", "stdio", "command", mcpsrv.Command, "args", mcpsrv.Args, "max-restarts", maxRestarts, "pool-size", poolSize, "pool-max", poolMax)
+
+		if maxRestarts > 0 {
+			return client.NewSupervisor(
+				client.NewStdio(mcpsrv, opts...),
+				client.OptSupervisorMaxRestarts(maxRestarts),
+				client.OptSupervisorMetricsManager(mm),
+			), nil
+		}
 
 		return client.NewStdio(mcpsrv, opts...), nil
 	}
@@ -545,3 +817,100 @@ func startFrontendWithOAuth(ctx context.Context, mfrontend frontend.Frontend, ag
 		tryN++
 	}
 }
+
+// makeSeccompProfile returns the built-in seccomp profile with the
+// given name, or loads the profile from the file at the given path.
+func makeSeccompProfile(nameOrPath string) (client.SeccompProfile, error) {
+
+	if slices.Contains(client.SeccompProfiles(), nameOrPath) {
+		return client.GetSeccompProfile(nameOrPath)
+	}
+
+	if _, err := os.Stat(nameOrPath); err != nil {
+		return client.SeccompProfile{}, fmt.Errorf(
+			"invalid --mcp-seccomp '%s': must be one of %s or a path to a JSON profile",
+			nameOrPath,
+			strings.Join(client.SeccompProfiles(), ", "),
+		)
+	}
+
+	return client.LoadSeccompProfile(nameOrPath)
+}
+
+func makeResourceLimits() (client.ResourceLimits, error) {
+
+	limits := client.ResourceLimits{
+		CPU:  viper.GetFloat64("mcp-cpu-limit"),
+		PIDs: viper.GetInt64("mcp-pids-limit"),
+	}
+
+	if mem := viper.GetString("mcp-memory-limit"); mem != "" {
+		v, err := client.ParseByteSize(mem)
+		if err != nil {
+			return limits, fmt.Errorf("invalid --mcp-memory-limit: %w", err)
+		}
+		limits.Memory = v
+	}
+
+	for _, s := range viper.GetStringSlice("mcp-io-limit") {
+		l, err := client.ParseIOLimit(s)
+		if err != nil {
+			return limits, fmt.Errorf("invalid --mcp-io-limit: %w", err)
+		}
+		limits.IO = append(limits.IO, l)
+	}
+
+	return limits, nil
+}
+
+// closeMCPClient releases the resources held by the
+// given client, like the idle servers of its pool.
+func closeMCPClient(mcpClient client.Client) {
+	if c, ok := mcpClient.(io.Closer); ok {
+		_ = c.Close()
+	}
+}
+
+func makeEgressAllowlist(mcpClient client.Client) (*egress.Allowlist, error) {
+
+	entries := viper.GetStringSlice("mcp-egress-allow")
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	if _, ok := mcpClient.(client.RemoteClient); ok {
+		return nil, fmt.Errorf("cannot use --mcp-egress-allow with a remote MCP server")
+	}
+
+	// The pooled servers are started before the sessions
+	// and their proxies, so they cannot use them.
+	if viper.GetInt("mcp-pool-size") > 0 {
+		return nil, fmt.Errorf("cannot use --mcp-egress-allow with --mcp-pool-size")
+	}
+
+	allowlist, err := egress.NewAllowlist(entries...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --mcp-egress-allow: %w", err)
+	}
+
+	slog.Info("MCP server egress proxy configured", "allow", entries)
+
+	return allowlist, nil
+}
+
+func makeToolTags() (backend.ToolTags, error) {
+
+	entries := viper.GetStringSlice("mcp-tool-tag")
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	tags, err := backend.ParseToolTags(entries...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --mcp-tool-tag: %w", err)
+	}
+
+	slog.Info("Tool tags configured", "tags", entries)
+
+	return tags, nil
+}
@@ -21,6 +21,7 @@ func init() {
 
 	Root.AddCommand(
 		Backend,
+		Gateway,
 		Frontend,
 		AIO,
 		Completion,
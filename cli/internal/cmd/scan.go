@@ -32,8 +32,8 @@ func init() {
 
 // Scan is the cobra command to run the server.
 var Scan = &cobra.Command{
-	Use:              "scan [dump|sbom|check file.sbom] -- command [args...]",
-	Short:            "Scan an MCP server for resources, prompts, etc and generate sbom",
+	Use:              "scan [dump|sbom|injections|check file.sbom] -- command [args...]",
+	Short:            "Scan an MCP server for resources, prompts, etc, generate sbom or look for prompt injections",
 	SilenceUsage:     true,
 	SilenceErrors:    true,
 	TraverseChildren: true,
@@ -70,10 +70,11 @@ var Scan = &cobra.Command{
 			mcpArgs = args[2:]
 		}
 
-		mcpClient, err := makeMCPClient(append([]string{mcpCommand}, mcpArgs...), false)
+		mcpClient, err := makeMCPClient(append([]string{mcpCommand}, mcpArgs...), false, nil)
 		if err != nil {
 			return err
 		}
+		defer closeMCPClient(mcpClient)
 
 		agentAuth, err := makeAgentAuth(false)
 		if err != nil {
@@ -140,6 +141,21 @@ var Scan = &cobra.Command{
 				return fmt.Errorf("unable to encode sbom: %w", err)
 			}
 
+		case "injections":
+
+			injections := scan.InspectTools(dump.Tools)
+
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.SetEscapeHTML(false)
+			if err := enc.Encode(injections); err != nil {
+				return fmt.Errorf("unable to encode injections: %w", err)
+			}
+
+			if len(injections) > 0 {
+				return fmt.Errorf("found %d prompt injections: %s", len(injections), injections)
+			}
+
 		case "dump":
 
 			enc := json.NewEncoder(os.Stdout)
@@ -149,7 +165,7 @@ var Scan = &cobra.Command{
 			}
 
 		default:
-			return fmt.Errorf("first command must be either dump, sbom or check")
+			return fmt.Errorf("first command must be either dump, sbom, injections or check")
 		}
 
 		return nil
@@ -23,7 +23,8 @@ def police():
 
     req = request.get_json()
     agent = req["agent"]
-    mcp = req["mcp"]
+    # session calls (sessionStart, sessionEnd) have no mcp message.
+    mcp = req.get("mcp", {})
 
     print()
     print("---")
@@ -68,10 +69,10 @@ def police():
     # from the response.
     if (
         req["type"] == "response"
-        and "result" in req["mcp"]
+        and "result" in mcp
         and claims["email"] == "bob@example.com"
     ):
-        result = req["mcp"]["result"]
+        result = mcp["result"]
         if "tools" in result:
             result["tools"] = [
                 cell
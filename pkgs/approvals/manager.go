@@ -0,0 +1,289 @@
+package approvals
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+// ErrTimeout is returned by Wait when no
+// decision has been made before the timeout.
+var ErrTimeout = errors.New("approval timed out")
+
+// errNotFound is returned when deciding
+// an approval that is not pending.
+var errNotFound = errors.New("approval not found")
+
+// An Approval is a tool call waiting for a human decision.
+type Approval struct {
+
+	// ID identifies the approval in the API.
+	ID string `json:"ID"`
+
+	// Session is the ID of the session making the call.
+	Session string `json:"session"`
+
+	// Server is the name of the MCP server, as
+	// returned by its initialize response.
+	Server string `json:"server,omitempty"`
+
+	// User is the user of the agent.
+	User string `json:"user,omitempty"`
+
+	// RemoteAddr is the address of the agent.
+	RemoteAddr string `json:"remoteAddr,omitempty"`
+
+	// Tool is the name of the called tool.
+	Tool string `json:"tool"`
+
+	// Arguments are the arguments of the call.
+	Arguments map[string]any `json:"arguments,omitempty"`
+
+	// Reason is the reason given by the policer.
+	Reason string `json:"reason,omitempty"`
+
+	// Created is the time the call was received.
+	Created time.Time `json:"created"`
+
+	// Expires is the time the call will be denied
+	// if no decision has been made.
+	Expires time.Time `json:"expires"`
+}
+
+// A Decision is the decision made on an Approval.
+type Decision struct {
+
+	// Approved is true if the call can be forwarded.
+	Approved bool `json:"approved"`
+
+	// Reason is the optional reason given with the decision.
+	Reason string `json:"reason,omitempty"`
+}
+
+type pending struct {
+	approval Approval
+	decision chan Decision
+}
+
+// A Manager holds the tool calls waiting for a decision, and serves
+// an HTTP API to list and decide them:
+//
+//	GET  /approvals
+//	POST /approvals/{id}/approve
+//	POST /approvals/{id}/deny
+//
+// The decisions can have a JSON body like {"reason": "..."}.
+type Manager struct {
+	cfg     cfg
+	server  *http.Server
+	pending map[string]*pending
+
+	sync.Mutex
+}
+
+// NewManager returns a new Manager serving its API on the given address.
+func NewManager(listen string, opts ...Option) *Manager {
+
+	cfg := newCfg()
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	m := &Manager{
+		cfg:     cfg,
+		pending: map[string]*pending{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /approvals", m.handleList)
+	mux.HandleFunc("POST /approvals/{id}/approve", m.handleDecision(true))
+	mux.HandleFunc("POST /approvals/{id}/deny", m.handleDecision(false))
+
+	m.server = &http.Server{
+		Addr:              listen,
+		ReadHeaderTimeout: time.Second,
+		Handler:           m.authenticate(mux),
+	}
+
+	return m
+}
+
+// Start starts the API server and will block
+// until the given context is canceled.
+func (m *Manager) Start(ctx context.Context) error {
+
+	errCh := make(chan error, 1)
+
+	sctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	m.server.BaseContext = func(net.Listener) context.Context { return sctx }
+	m.server.RegisterOnShutdown(func() { cancel() })
+
+	go func() {
+		err := m.server.ListenAndServe()
+		if err != nil {
+			if !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("unable to start approvals server", "err", err)
+			}
+		}
+		errCh <- err
+	}()
+
+	select {
+	case <-sctx.Done():
+	case err := <-errCh:
+		return err
+	}
+
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer stopCancel()
+
+	return m.server.Shutdown(stopCtx)
+}
+
+// Wait publishes the given Approval and blocks until a decision is made.
+// The ID, the creation and the expiration times are set by Wait. It returns
+// ErrTimeout if no decision has been made before the timeout, or the error
+// of the given context if it is canceled first. In both cases, the Approval
+// is withdrawn.
+func (m *Manager) Wait(ctx context.Context, a Approval) (Decision, error) {
+
+	a.ID = uuid.Must(uuid.NewV7()).String()
+	a.Created = time.Now()
+	a.Expires = a.Created.Add(m.cfg.timeout)
+
+	p := &pending{
+		approval: a,
+		decision: make(chan Decision, 1),
+	}
+
+	m.Lock()
+	m.pending[a.ID] = p
+	m.Unlock()
+
+	defer func() {
+		m.Lock()
+		delete(m.pending, a.ID)
+		m.Unlock()
+	}()
+
+	slog.Info("Tool call pending approval", "id", a.ID, "tool", a.Tool, "session", a.Session, "user", a.User, "reason", a.Reason)
+
+	timer := time.NewTimer(m.cfg.timeout)
+	defer timer.Stop()
+
+	select {
+	case d := <-p.decision:
+		return d, nil
+	case <-timer.C:
+		slog.Warn("Tool call approval timed out", "id", a.ID, "tool", a.Tool, "session", a.Session)
+		return Decision{}, ErrTimeout
+	case <-ctx.Done():
+		return Decision{}, ctx.Err()
+	}
+}
+
+// List returns the approvals waiting for a
+// decision, from the oldest to the newest.
+func (m *Manager) List() []Approval {
+
+	m.Lock()
+	defer m.Unlock()
+
+	out := make([]Approval, 0, len(m.pending))
+	for _, p := range m.pending {
+		out = append(out, p.approval)
+	}
+
+	slices.SortFunc(out, func(a, b Approval) int { return a.Created.Compare(b.Created) })
+
+	return out
+}
+
+// Decide makes the given decision on the approval with the
+// given ID. It returns an error if there is no such approval.
+func (m *Manager) Decide(id string, d Decision) error {
+
+	m.Lock()
+	p, ok := m.pending[id]
+	delete(m.pending, id)
+	m.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %s", errNotFound, id)
+	}
+
+	slog.Info("Tool call approval decided", "id", id, "tool", p.approval.Tool, "session", p.approval.Session, "approved", d.Approved, "reason", d.Reason)
+
+	p.decision <- d
+
+	return nil
+}
+
+func (m *Manager) authenticate(next http.Handler) http.Handler {
+
+	if m.cfg.token == "" {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+		token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(m.cfg.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, req)
+	})
+}
+
+func (m *Manager) handleList(w http.ResponseWriter, _ *http.Request) {
+
+	data, err := json.Marshal(m.List())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("unable to encode approvals: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
+
+func (m *Manager) handleDecision(approved bool) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, req *http.Request) {
+
+		d := Decision{}
+
+		// The body is optional, and only used for the reason.
+		if err := json.NewDecoder(http.MaxBytesReader(w, req.Body, 64*1024)).Decode(&d); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, fmt.Sprintf("unable to decode decision: %s", err), http.StatusBadRequest)
+			return
+		}
+
+		d.Approved = approved
+
+		if err := m.Decide(req.PathValue("id"), d); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
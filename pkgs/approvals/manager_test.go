@@ -0,0 +1,151 @@
+package approvals
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// waitPending returns the approvals once there are n of them.
+func waitPending(m *Manager, n int) []Approval {
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(m.List()) < n && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	return m.List()
+}
+
+func TestManager(t *testing.T) {
+
+	Convey("Given I have a manager", t, func() {
+
+		m := NewManager("127.0.0.1:0", OptToken("secret"))
+
+		ts := httptest.NewServer(m.server.Handler)
+		defer ts.Close()
+
+		call := func(method string, path string, token string, body string) *http.Response {
+			req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+			So(err, ShouldBeNil)
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			So(err, ShouldBeNil)
+			_ = resp.Body.Close()
+			return resp
+		}
+
+		type result struct {
+			d   Decision
+			err error
+		}
+
+		wait := func(tool string) chan result {
+			ch := make(chan result, 1)
+			go func() {
+				d, err := m.Wait(context.Background(), Approval{Session: "s", Tool: tool, Reason: "approve me"})
+				ch <- result{d, err}
+			}()
+			return ch
+		}
+
+		Convey("Then the pending calls should be listed", func() {
+
+			ch := wait("delete_file")
+			pending := waitPending(m, 1)
+			So(pending, ShouldHaveLength, 1)
+			So(pending[0].Tool, ShouldEqual, "delete_file")
+			So(pending[0].Expires, ShouldEqual, pending[0].Created.Add(5*time.Minute))
+
+			req, err := http.NewRequest(http.MethodGet, ts.URL+"/approvals", nil)
+			So(err, ShouldBeNil)
+			req.Header.Set("Authorization", "Bearer secret")
+			resp, err := http.DefaultClient.Do(req)
+			So(err, ShouldBeNil)
+			defer func() { _ = resp.Body.Close() }()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+
+			var listed []Approval
+			So(json.NewDecoder(resp.Body).Decode(&listed), ShouldBeNil)
+			So(listed, ShouldHaveLength, 1)
+			So(listed[0].ID, ShouldEqual, pending[0].ID)
+			So(listed[0].Reason, ShouldEqual, "approve me")
+
+			So(m.Decide(pending[0].ID, Decision{}), ShouldBeNil)
+			<-ch
+		})
+
+		Convey("Then a call should be approved", func() {
+
+			ch := wait("deploy")
+			id := waitPending(m, 1)[0].ID
+
+			So(call(http.MethodPost, "/approvals/"+id+"/approve", "secret", "").StatusCode, ShouldEqual, http.StatusNoContent)
+
+			r := <-ch
+			So(r.err, ShouldBeNil)
+			So(r.d, ShouldResemble, Decision{Approved: true})
+			So(m.List(), ShouldBeEmpty)
+
+			So(call(http.MethodPost, "/approvals/"+id+"/approve", "secret", "").StatusCode, ShouldEqual, http.StatusNotFound)
+		})
+
+		Convey("Then a call should be denied with a reason", func() {
+
+			ch := wait("create_payment")
+			id := waitPending(m, 1)[0].ID
+
+			So(call(http.MethodPost, "/approvals/"+id+"/deny", "secret", `{"reason":"too expensive","approved":true}`).StatusCode, ShouldEqual, http.StatusNoContent)
+
+			r := <-ch
+			So(r.err, ShouldBeNil)
+			So(r.d, ShouldResemble, Decision{Approved: false, Reason: "too expensive"})
+		})
+
+		Convey("Then invalid requests should be rejected", func() {
+
+			ch := wait("deploy")
+			id := waitPending(m, 1)[0].ID
+
+			So(call(http.MethodGet, "/approvals", "", "").StatusCode, ShouldEqual, http.StatusUnauthorized)
+			So(call(http.MethodPost, "/approvals/"+id+"/approve", "nope", "").StatusCode, ShouldEqual, http.StatusUnauthorized)
+			So(call(http.MethodPost, "/approvals/"+id+"/approve", "secret", "{").StatusCode, ShouldEqual, http.StatusBadRequest)
+			So(call(http.MethodGet, "/approvals/"+id+"/approve", "secret", "").StatusCode, ShouldEqual, http.StatusMethodNotAllowed)
+			So(m.List(), ShouldHaveLength, 1)
+
+			So(m.Decide(id, Decision{}), ShouldBeNil)
+			<-ch
+		})
+	})
+
+	Convey("Given I have a manager with a short timeout", t, func() {
+
+		m := NewManager("127.0.0.1:0", OptTimeout(10*time.Millisecond))
+
+		_, err := m.Wait(context.Background(), Approval{Tool: "deploy"})
+		So(errors.Is(err, ErrTimeout), ShouldBeTrue)
+		So(m.List(), ShouldBeEmpty)
+	})
+
+	Convey("Given I wait with a canceled context", t, func() {
+
+		m := NewManager("127.0.0.1:0")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := m.Wait(ctx, Approval{Tool: "deploy"})
+		So(errors.Is(err, context.Canceled), ShouldBeTrue)
+		So(m.List(), ShouldBeEmpty)
+	})
+}
@@ -0,0 +1,34 @@
+package approvals
+
+import "time"
+
+type cfg struct {
+	timeout time.Duration
+	token   string
+}
+
+func newCfg() cfg {
+	return cfg{
+		timeout: 5 * time.Minute,
+	}
+}
+
+// An Option can be given to NewManager.
+type Option func(*cfg)
+
+// OptTimeout sets how long a call waits for a decision
+// before being denied. The default is 5m.
+func OptTimeout(timeout time.Duration) Option {
+	return func(c *cfg) {
+		c.timeout = timeout
+	}
+}
+
+// OptToken sets the token the clients of the API must
+// send using the Bearer scheme. If empty, the API is
+// not authenticated.
+func OptToken(token string) Option {
+	return func(c *cfg) {
+		c.token = token
+	}
+}
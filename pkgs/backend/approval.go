@@ -0,0 +1,102 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.acuvity.ai/elemental"
+	"go.acuvity.ai/minibridge/pkgs/approvals"
+	"go.acuvity.ai/minibridge/pkgs/mcp"
+	"go.acuvity.ai/minibridge/pkgs/policer/api"
+)
+
+// heldCall is a tool call that was held until
+// a decision was made on its approval.
+type heldCall struct {
+
+	// msg is the held call.
+	msg mcp.Message
+
+	// data is the call to forward to the MCP server if
+	// approved, or the error to send to the agent otherwise.
+	data     []byte
+	approved bool
+}
+
+// approvalTracker holds the tool calls of a session pending
+// approval until a human decides them using the approvals.Manager.
+// The decisions are sent to results, so the session keeps handling
+// the other messages meanwhile.
+type approvalTracker struct {
+	manager *approvals.Manager
+	results chan heldCall
+}
+
+func newApprovalTracker(manager *approvals.Manager) *approvalTracker {
+	return &approvalTracker{
+		manager: manager,
+		results: make(chan heldCall, 8),
+	}
+}
+
+// hold publishes the given call for approval, and sends the decision
+// to t.results once it is made. The data is the call as modified by the
+// policer, which is the one published and forwarded once approved. The
+// given error is the one returned by the policer. It returns an error
+// wrapping api.ErrBlocked if the call cannot be held.
+func (t *approvalTracker) hold(ctx context.Context, session api.Session, agent api.Agent, data []byte, perr error) error {
+
+	reason := strings.TrimPrefix(perr.Error(), api.ErrPending.Error()+": ")
+
+	msg := mcp.NewMessage("")
+	if err := elemental.Decode(elemental.EncodingTypeJSON, data, &msg); err != nil {
+		return fmt.Errorf("%w: %s: unable to decode held call: %w", api.ErrBlocked, reason, err)
+	}
+
+	if t.manager == nil {
+		return fmt.Errorf("%w: %s: no approval workflow configured", api.ErrBlocked, reason)
+	}
+
+	if msg.Method != "tools/call" || msg.ID == nil {
+		return fmt.Errorf("%w: %s: only tool calls can be approved", api.ErrBlocked, reason)
+	}
+
+	name, _ := msg.Params["name"].(string)
+	args, _ := msg.Params["arguments"].(map[string]any)
+
+	approval := approvals.Approval{
+		Session:    session.ID,
+		Server:     session.Server.Name,
+		User:       agent.User,
+		RemoteAddr: agent.RemoteAddr,
+		Tool:       name,
+		Arguments:  args,
+		Reason:     reason,
+	}
+
+	go func() {
+
+		h := heldCall{msg: msg, data: data}
+
+		d, err := t.manager.Wait(ctx, approval)
+
+		switch {
+		case err != nil:
+			h.data = makeMCPError(msg.ID, fmt.Errorf("%w: tool call not approved: %w", api.ErrBlocked, err))
+		case !d.Approved && d.Reason != "":
+			h.data = makeMCPError(msg.ID, fmt.Errorf("%w: tool call denied: %s", api.ErrBlocked, d.Reason))
+		case !d.Approved:
+			h.data = makeMCPError(msg.ID, fmt.Errorf("%w: tool call denied", api.ErrBlocked))
+		default:
+			h.approved = true
+		}
+
+		select {
+		case t.results <- h:
+		case <-ctx.Done():
+		}
+	}()
+
+	return nil
+}
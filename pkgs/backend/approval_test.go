@@ -0,0 +1,96 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.acuvity.ai/minibridge/pkgs/approvals"
+	"go.acuvity.ai/minibridge/pkgs/policer/api"
+)
+
+func TestApprovalTracker(t *testing.T) {
+
+	perr := fmt.Errorf("%w: deletions must be approved", api.ErrPending)
+	session := api.Session{ID: "s", Server: api.ServerInfo{Name: "files"}}
+	agent := api.Agent{User: "bob", Password: "secret", RemoteAddr: "10.0.0.1"}
+	call := []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"delete_file","arguments":{"path":"/a"}}}`)
+
+	Convey("Given I have a tracker without manager", t, func() {
+
+		held := newApprovalTracker(nil)
+
+		err := held.hold(context.Background(), session, agent, call, perr)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "request blocked: deletions must be approved: no approval workflow configured")
+	})
+
+	Convey("Given I have a tracker with a manager", t, func() {
+
+		m := approvals.NewManager("127.0.0.1:0", approvals.OptTimeout(time.Minute))
+		held := newApprovalTracker(m)
+
+		pending := func() approvals.Approval {
+			deadline := time.Now().Add(5 * time.Second)
+			for len(m.List()) == 0 && time.Now().Before(deadline) {
+				time.Sleep(5 * time.Millisecond)
+			}
+			So(m.List(), ShouldHaveLength, 1)
+			return m.List()[0]
+		}
+
+		Convey("Then an approved call should be forwarded", func() {
+
+			So(held.hold(context.Background(), session, agent, call, perr), ShouldBeNil)
+
+			a := pending()
+			So(a.Session, ShouldEqual, "s")
+			So(a.Server, ShouldEqual, "files")
+			So(a.User, ShouldEqual, "bob")
+			So(a.RemoteAddr, ShouldEqual, "10.0.0.1")
+			So(a.Tool, ShouldEqual, "delete_file")
+			So(a.Arguments, ShouldResemble, map[string]any{"path": "/a"})
+			So(a.Reason, ShouldEqual, "deletions must be approved")
+
+			So(m.Decide(a.ID, approvals.Decision{Approved: true}), ShouldBeNil)
+
+			h := <-held.results
+			So(h.approved, ShouldBeTrue)
+			So(string(h.data), ShouldEqual, string(call))
+			So(h.msg.Params["name"], ShouldEqual, "delete_file")
+		})
+
+		Convey("Then a denied call should be answered with an error", func() {
+
+			So(held.hold(context.Background(), session, agent, call, perr), ShouldBeNil)
+
+			So(m.Decide(pending().ID, approvals.Decision{Reason: "not today"}), ShouldBeNil)
+
+			h := <-held.results
+			So(h.approved, ShouldBeFalse)
+			So(string(h.data), ShouldContainSubstring, `"message":"request blocked: tool call denied: not today"`)
+			So(string(h.data), ShouldContainSubstring, `"id":1`)
+		})
+
+		Convey("Then the calls that are not tool calls should be refused", func() {
+
+			err := held.hold(context.Background(), session, agent, []byte(`{"jsonrpc":"2.0","id":1,"method":"prompts/get"}`), perr)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "request blocked: deletions must be approved: only tool calls can be approved")
+			So(m.List(), ShouldBeEmpty)
+		})
+	})
+
+	Convey("Given I have a tracker with a manager timing out", t, func() {
+
+		held := newApprovalTracker(approvals.NewManager("127.0.0.1:0", approvals.OptTimeout(time.Millisecond)))
+
+		So(held.hold(context.Background(), session, agent, call, perr), ShouldBeNil)
+
+		h := <-held.results
+		So(h.approved, ShouldBeFalse)
+		So(string(h.data), ShouldContainSubstring, `"message":"request blocked: tool call not approved: approval timed out"`)
+	})
+}
@@ -0,0 +1,788 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	"go.acuvity.ai/elemental"
+	"go.acuvity.ai/minibridge/pkgs/mcp"
+)
+
+// aggregateListKeys maps the list methods merged by
+// the aggregate to the key holding their items.
+var aggregateListKeys = map[string]string{
+	"tools/list":               "tools",
+	"prompts/list":             "prompts",
+	"resources/list":           "resources",
+	"resources/templates/list": "resourceTemplates",
+}
+
+var _ Client = (*aggregate)(nil)
+
+// An Upstream is a named Client aggregated by NewAggregate.
+type Upstream struct {
+	Name   string
+	Client Client
+}
+
+type aggregate struct {
+	upstreams []Upstream
+	cfg       aggregateCfg
+}
+
+// NewAggregate returns a Client presenting the MCP servers started by the
+// given upstreams as a single MCP server.
+//
+// The tools, prompts and resources of the upstreams are merged, and their
+// names are prefixed by the name of their upstream. Calls are routed to the
+// right upstream using these prefixes, or using the uri of the resources.
+// The capabilities returned by the upstreams on initialize are merged, and
+// their notifications, like list_changed, are forwarded as is.
+//
+// If one of the upstreams exits, the whole aggregate exits. Upstreams can
+// be wrapped with NewSupervisor to be restarted instead.
+func NewAggregate(upstreams []Upstream, options ...AggregateOption) (Client, error) {
+
+	cfg := newAggregateCfg()
+	for _, o := range options {
+		o(&cfg)
+	}
+
+	if len(upstreams) == 0 {
+		return nil, fmt.Errorf("at least one upstream must be given")
+	}
+
+	if cfg.separator == "" {
+		return nil, fmt.Errorf("separator must not be empty")
+	}
+
+	names := make(map[string]struct{}, len(upstreams))
+	for _, u := range upstreams {
+
+		switch {
+		case u.Name == "":
+			return nil, fmt.Errorf("upstream name must not be empty")
+		case strings.Contains(u.Name, cfg.separator):
+			return nil, fmt.Errorf("invalid upstream name '%s': must not contain '%s'", u.Name, cfg.separator)
+		case u.Client == nil:
+			return nil, fmt.Errorf("upstream '%s' has no client", u.Name)
+		}
+
+		if _, ok := names[u.Name]; ok {
+			return nil, fmt.Errorf("duplicate upstream name '%s'", u.Name)
+		}
+		names[u.Name] = struct{}{}
+	}
+
+	return &aggregate{
+		upstreams: slices.Clone(upstreams),
+		cfg:       cfg,
+	}, nil
+}
+
+func (a *aggregate) Type() string { return "aggregate" }
+
+func (a *aggregate) Server() string {
+
+	names := make([]string, len(a.upstreams))
+	for i, u := range a.upstreams {
+		names[i] = u.Name
+	}
+
+	return strings.Join(names, ",")
+}
+
+func (a *aggregate) Start(ctx context.Context, opts ...Option) (*MCPStream, error) {
+
+	uctx, cancel := context.WithCancel(ctx)
+
+	ag := &aggregation{
+		cfg:            a.cfg,
+		upstreams:      make([]*aggregateUpstream, len(a.upstreams)),
+		pending:        map[string]*aggregatePending{},
+		serverRequests: map[string]aggregateServerRequest{},
+		resources:      map[string]int{},
+	}
+
+	for i, u := range a.upstreams {
+
+		stream, err := u.Client.Start(uctx, opts...)
+		if err != nil {
+			cancel()
+			for _, started := range ag.upstreams[:i] {
+				started.unregister()
+			}
+			return nil, fmt.Errorf("unable to start upstream '%s': %w", u.Name, err)
+		}
+
+		ag.upstreams[i] = &aggregateUpstream{
+			name:          u.Name,
+			watchedStream: watchStream(stream),
+		}
+	}
+
+	stream := NewMCPStream(ctx)
+
+	go ag.run(ctx, stream, cancel)
+
+	return stream, nil
+}
+
+type aggregateUpstream struct {
+	*watchedStream
+	name string
+}
+
+type aggregateMessage struct {
+	upstream int
+	data     []byte
+}
+
+type aggregateExit struct {
+	upstream int
+	err      error
+}
+
+// aggregatePending is a request sent to an upstream. It is either
+// routed back to the agent, or part of a fanout.
+type aggregatePending struct {
+	upstream int
+	agentID  any
+	fanout   *aggregateFanout
+}
+
+// aggregateFanout is a request of the agent
+// sent to all the upstreams.
+type aggregateFanout struct {
+	agentID   any
+	method    string
+	remaining int
+	results   []map[string]any
+	items     [][]any
+	errors    []*mcp.Error
+}
+
+// aggregateServerRequest is a request
+// sent by an upstream to the agent.
+type aggregateServerRequest struct {
+	upstream int
+	id       any
+}
+
+type aggregateTemplate struct {
+	prefix   string
+	upstream int
+}
+
+// aggregation holds the state of an agent session
+// with the aggregated upstreams.
+type aggregation struct {
+	cfg            aggregateCfg
+	upstreams      []*aggregateUpstream
+	nextID         int
+	pending        map[string]*aggregatePending
+	serverRequests map[string]aggregateServerRequest
+	resources      map[string]int
+	templates      []aggregateTemplate
+}
+
+func (ag *aggregation) run(ctx context.Context, stream *MCPStream, cancel context.CancelFunc) {
+
+	defer cancel()
+
+	msgs := make(chan aggregateMessage)
+	exits := make(chan aggregateExit, len(ag.upstreams))
+
+	for i, u := range ag.upstreams {
+		defer u.unregister()
+		go ag.pump(ctx, i, stream, msgs, exits)
+	}
+
+	for {
+		select {
+
+		case data := <-stream.stdin:
+			ag.fromAgent(ctx, stream, data)
+
+		case m := <-msgs:
+			ag.fromUpstream(ctx, stream, m.upstream, m.data)
+
+		case e := <-exits:
+
+			if ctx.Err() != nil {
+				sendExit(stream, ctx.Err())
+				return
+			}
+
+			err := fmt.Errorf("upstream '%s' exited", ag.upstreams[e.upstream].name)
+			if e.err != nil {
+				err = fmt.Errorf("upstream '%s' exited: %w", ag.upstreams[e.upstream].name, e.err)
+			}
+
+			slog.Error("Aggregated MCP server exited", "upstream", ag.upstreams[e.upstream].name, "err", e.err)
+			sendExit(stream, err)
+
+			return
+
+		case <-ctx.Done():
+			sendExit(stream, ctx.Err())
+			return
+		}
+	}
+}
+
+// pump forwards the messages of the given upstream to the main loop,
+// and its stderr directly to the stream.
+func (ag *aggregation) pump(ctx context.Context, i int, stream *MCPStream, msgs chan aggregateMessage, exits chan aggregateExit) {
+
+	u := ag.upstreams[i]
+
+	for {
+		select {
+
+		case data := <-u.out:
+			select {
+			case msgs <- aggregateMessage{upstream: i, data: data}:
+			case <-ctx.Done():
+				return
+			}
+
+		case data := <-u.errs:
+			select {
+			case stream.stderr <- data:
+			case <-ctx.Done():
+				return
+			}
+
+		case err := <-u.exit:
+			exits <- aggregateExit{upstream: i, err: err}
+			return
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (ag *aggregation) fromAgent(ctx context.Context, stream *MCPStream, data []byte) {
+
+	msg := mcp.Message{}
+	if err := elemental.Decode(elemental.EncodingTypeJSON, data, &msg); err != nil {
+		slog.Error("Unable to decode agent message for aggregate", "err", err)
+		return
+	}
+
+	switch {
+
+	case msg.Method == "" && msg.ID != nil:
+
+		r, ok := ag.serverRequests[msg.IDString()]
+		if !ok {
+			slog.Debug("Dropping agent response to unknown request", "id", msg.ID)
+			return
+		}
+		delete(ag.serverRequests, msg.IDString())
+
+		ag.sendWithID(ctx, r.upstream, data, r.id)
+
+	case msg.ID == nil:
+		ag.notifyUpstreams(ctx, msg, data)
+
+	default:
+		ag.handleRequest(ctx, stream, msg)
+	}
+}
+
+func (ag *aggregation) handleRequest(ctx context.Context, stream *MCPStream, msg mcp.Message) {
+
+	switch msg.Method {
+
+	case "initialize", "logging/setLevel", "tools/list", "prompts/list", "resources/list", "resources/templates/list":
+		ag.fanout(ctx, msg)
+
+	case "ping":
+		ag.reply(ctx, stream, msg.ID, map[string]any{}, nil)
+
+	case "tools/call", "prompts/get":
+
+		name, _ := msg.Params["name"].(string)
+
+		i, local, ok := ag.split(name)
+		if !ok {
+			ag.reply(ctx, stream, msg.ID, nil, &mcp.Error{Code: -32602, Message: fmt.Sprintf("unknown name '%s'", name)})
+			return
+		}
+
+		params := maps.Clone(msg.Params)
+		params["name"] = local
+
+		ag.route(ctx, i, msg, params)
+
+	case "resources/read", "resources/subscribe", "resources/unsubscribe":
+
+		uri, _ := msg.Params["uri"].(string)
+
+		i, ok := ag.resourceUpstream(uri)
+		if !ok {
+			ag.reply(ctx, stream, msg.ID, nil, &mcp.Error{Code: -32002, Message: fmt.Sprintf("unknown resource '%s'", uri)})
+			return
+		}
+
+		ag.route(ctx, i, msg, msg.Params)
+
+	case "completion/complete":
+
+		ref, _ := msg.Params["ref"].(map[string]any)
+
+		var i int
+		var ok bool
+
+		ref = maps.Clone(ref)
+		switch ref["type"] {
+
+		case "ref/prompt":
+			var local string
+			name, _ := ref["name"].(string)
+			if i, local, ok = ag.split(name); ok {
+				ref["name"] = local
+			}
+
+		case "ref/resource":
+			uri, _ := ref["uri"].(string)
+			i, ok = ag.resourceUpstream(uri)
+		}
+
+		if !ok {
+			ag.reply(ctx, stream, msg.ID, nil, &mcp.Error{Code: -32602, Message: "unknown completion reference"})
+			return
+		}
+
+		params := maps.Clone(msg.Params)
+		params["ref"] = ref
+
+		ag.route(ctx, i, msg, params)
+
+	default:
+		ag.reply(ctx, stream, msg.ID, nil, &mcp.Error{Code: -32601, Message: fmt.Sprintf("method '%s' not found", msg.Method)})
+	}
+}
+
+// notifyUpstreams forwards the given notification of the agent.
+// Cancellations are sent to the upstream handling the request,
+// the other notifications to all upstreams.
+func (ag *aggregation) notifyUpstreams(ctx context.Context, msg mcp.Message, data []byte) {
+
+	if msg.Method != "notifications/cancelled" {
+		for i := range ag.upstreams {
+			ag.send(ctx, i, data)
+		}
+		return
+	}
+
+	for id, p := range ag.pending {
+
+		if p.fanout != nil || !mcp.RelatedIDs(p.agentID, msg.Params["requestId"]) {
+			continue
+		}
+
+		delete(ag.pending, id)
+
+		notif := mcp.NewNotification(msg.Method)
+		notif.Params = maps.Clone(msg.Params)
+		notif.Params["requestId"] = id
+
+		ag.sendMessage(ctx, p.upstream, notif)
+
+		return
+	}
+}
+
+// route sends the request of the agent to the given upstream,
+// and its response back to the agent.
+func (ag *aggregation) route(ctx context.Context, i int, msg mcp.Message, params map[string]any) {
+	ag.request(ctx, i, msg.Method, params, &aggregatePending{upstream: i, agentID: msg.ID})
+}
+
+// fanout sends the request of the agent to all upstreams,
+// and responds to the agent once they all responded.
+func (ag *aggregation) fanout(ctx context.Context, msg mcp.Message) {
+
+	f := &aggregateFanout{
+		agentID:   msg.ID,
+		method:    msg.Method,
+		remaining: len(ag.upstreams),
+		results:   make([]map[string]any, len(ag.upstreams)),
+		items:     make([][]any, len(ag.upstreams)),
+		errors:    make([]*mcp.Error, len(ag.upstreams)),
+	}
+
+	for i := range ag.upstreams {
+		ag.request(ctx, i, msg.Method, msg.Params, &aggregatePending{upstream: i, fanout: f})
+	}
+}
+
+func (ag *aggregation) request(ctx context.Context, i int, method string, params map[string]any, p *aggregatePending) {
+
+	ag.nextID++
+	id := fmt.Sprintf("aggregate-%d", ag.nextID)
+
+	ag.pending[id] = p
+
+	req := mcp.NewMessage(id)
+	req.Method = method
+	req.Params = params
+
+	ag.sendMessage(ctx, i, req)
+}
+
+func (ag *aggregation) fromUpstream(ctx context.Context, stream *MCPStream, i int, data []byte) {
+
+	msg := mcp.Message{}
+	if err := elemental.Decode(elemental.EncodingTypeJSON, data, &msg); err != nil {
+		slog.Error("Unable to decode upstream message for aggregate", "upstream", ag.upstreams[i].name, "err", err)
+		return
+	}
+
+	switch {
+
+	case msg.Method == "" && msg.ID != nil:
+		ag.handleResponse(ctx, stream, i, msg, data)
+
+	case msg.ID != nil:
+
+		id := ag.upstreams[i].name + ag.cfg.separator + msg.IDString()
+		ag.serverRequests[id] = aggregateServerRequest{upstream: i, id: msg.ID}
+
+		if data = withID(data, id); data != nil {
+			ag.toAgent(ctx, stream, data)
+		}
+
+	case msg.Method == "notifications/cancelled":
+
+		id := ag.upstreams[i].name + ag.cfg.separator + fmt.Sprintf("%v", msg.Params["requestId"])
+		if r, ok := ag.serverRequests[id]; ok && mcp.RelatedIDs(r.id, msg.Params["requestId"]) {
+			delete(ag.serverRequests, id)
+			msg.Params["requestId"] = id
+			if data = encodeMessage(msg); data != nil {
+				ag.toAgent(ctx, stream, data)
+			}
+		}
+
+	default:
+		ag.toAgent(ctx, stream, data)
+	}
+}
+
+func (ag *aggregation) handleResponse(ctx context.Context, stream *MCPStream, i int, msg mcp.Message, data []byte) {
+
+	p, ok := ag.pending[msg.IDString()]
+	if !ok || p.upstream != i {
+		slog.Debug("Dropping upstream response to unknown request", "upstream", ag.upstreams[i].name, "id", msg.ID)
+		return
+	}
+	delete(ag.pending, msg.IDString())
+
+	if p.fanout == nil {
+		if data = withID(data, p.agentID); data != nil {
+			ag.toAgent(ctx, stream, data)
+		}
+		return
+	}
+
+	f := p.fanout
+
+	switch key, isList := aggregateListKeys[f.method]; {
+
+	case msg.Error != nil:
+		f.errors[i] = msg.Error
+
+	case isList:
+
+		items, _ := msg.Result[key].([]any)
+		f.items[i] = append(f.items[i], items...)
+
+		if cursor, _ := msg.Result["nextCursor"].(string); cursor != "" {
+			ag.request(ctx, i, f.method, map[string]any{"cursor": cursor}, p)
+			return
+		}
+
+	default:
+		f.results[i] = msg.Result
+	}
+
+	if f.remaining--; f.remaining > 0 {
+		return
+	}
+
+	ag.complete(ctx, stream, f)
+}
+
+// complete merges the results of the given
+// fanout, and responds to the agent.
+func (ag *aggregation) complete(ctx context.Context, stream *MCPStream, f *aggregateFanout) {
+
+	switch f.method {
+
+	case "initialize":
+
+		for i, e := range f.errors {
+			if e != nil {
+				ag.reply(ctx, stream, f.agentID, nil, &mcp.Error{Code: e.Code, Message: fmt.Sprintf("upstream '%s': %s", ag.upstreams[i].name, e.Message)})
+				return
+			}
+		}
+
+		ag.reply(ctx, stream, f.agentID, ag.mergeInitialize(f.results), nil)
+
+	case "logging/setLevel":
+		ag.reply(ctx, stream, f.agentID, map[string]any{}, nil)
+
+	default:
+
+		if f.method == "resources/templates/list" {
+			ag.templates = nil
+		}
+
+		merged := []any{}
+
+		for i, items := range f.items {
+
+			if f.errors[i] != nil {
+				slog.Debug("Upstream unable to list items", "upstream", ag.upstreams[i].name, "method", f.method, "err", f.errors[i].Message)
+				continue
+			}
+
+			for _, item := range items {
+
+				m, ok := item.(map[string]any)
+				if !ok {
+					continue
+				}
+
+				ag.track(f.method, i, m)
+
+				m = maps.Clone(m)
+				if name, ok := m["name"].(string); ok {
+					m["name"] = ag.upstreams[i].name + ag.cfg.separator + name
+				}
+
+				merged = append(merged, m)
+			}
+		}
+
+		ag.reply(ctx, stream, f.agentID, map[string]any{aggregateListKeys[f.method]: merged}, nil)
+	}
+}
+
+func (ag *aggregation) mergeInitialize(results []map[string]any) map[string]any {
+
+	var version string
+	var instructions []string
+	capabilities := map[string]any{}
+
+	for i, r := range results {
+
+		if v, _ := r["protocolVersion"].(string); v != "" && (version == "" || v < version) {
+			version = v
+		}
+
+		if c, ok := r["capabilities"].(map[string]any); ok {
+			mergeCapabilities(capabilities, c)
+		}
+
+		if s, _ := r["instructions"].(string); s != "" {
+			instructions = append(instructions, fmt.Sprintf("%s: %s", ag.upstreams[i].name, s))
+		}
+	}
+
+	out := map[string]any{
+		"protocolVersion": version,
+		"capabilities":    capabilities,
+		"serverInfo": map[string]any{
+			"name":    "minibridge-aggregate",
+			"version": "1.0",
+		},
+	}
+
+	if len(instructions) > 0 {
+		out["instructions"] = strings.Join(instructions, "\n\n")
+	}
+
+	return out
+}
+
+// track records the upstream owning the given resource or template.
+func (ag *aggregation) track(method string, i int, item map[string]any) {
+
+	switch method {
+
+	case "resources/list":
+		if uri, ok := item["uri"].(string); ok {
+			ag.resources[uri] = i
+		}
+
+	case "resources/templates/list":
+		if tmpl, ok := item["uriTemplate"].(string); ok {
+			prefix, _, _ := strings.Cut(tmpl, "{")
+			ag.templates = append(ag.templates, aggregateTemplate{prefix: prefix, upstream: i})
+		}
+	}
+}
+
+// split returns the upstream and the local
+// name of the given prefixed name.
+func (ag *aggregation) split(name string) (int, string, bool) {
+
+	prefix, local, ok := strings.Cut(name, ag.cfg.separator)
+	if !ok {
+		return 0, "", false
+	}
+
+	for i, u := range ag.upstreams {
+		if u.name == prefix {
+			return i, local, true
+		}
+	}
+
+	return 0, "", false
+}
+
+// resourceUpstream returns the upstream owning the given uri,
+// based on the resources and templates the agent listed.
+func (ag *aggregation) resourceUpstream(uri string) (int, bool) {
+
+	if i, ok := ag.resources[uri]; ok {
+		return i, true
+	}
+
+	found, longest := -1, -1
+	for _, t := range ag.templates {
+		if strings.HasPrefix(uri, t.prefix) && len(t.prefix) > longest {
+			found, longest = t.upstream, len(t.prefix)
+		}
+	}
+
+	if found >= 0 {
+		return found, true
+	}
+
+	if len(ag.upstreams) == 1 {
+		return 0, true
+	}
+
+	return 0, false
+}
+
+func (ag *aggregation) reply(ctx context.Context, stream *MCPStream, id any, result map[string]any, e *mcp.Error) {
+
+	resp := map[string]any{
+		"jsonrpc": "2.0",
+		"id":      id,
+	}
+
+	if e != nil {
+		resp["error"] = e
+	} else {
+		resp["result"] = result
+	}
+
+	if data := encodeMessage(resp); data != nil {
+		ag.toAgent(ctx, stream, data)
+	}
+}
+
+func (ag *aggregation) toAgent(ctx context.Context, stream *MCPStream, data []byte) {
+	select {
+	case stream.stdout <- data:
+	case <-ctx.Done():
+	}
+}
+
+func (ag *aggregation) sendMessage(ctx context.Context, i int, msg any) {
+	if data := encodeMessage(msg); data != nil {
+		ag.send(ctx, i, data)
+	}
+}
+
+func (ag *aggregation) sendWithID(ctx context.Context, i int, data []byte, id any) {
+	if data = withID(data, id); data != nil {
+		ag.send(ctx, i, data)
+	}
+}
+
+func (ag *aggregation) send(ctx context.Context, i int, data []byte) {
+	select {
+	case ag.upstreams[i].Stdin() <- data:
+	case <-ctx.Done():
+	}
+}
+
+// mergeCapabilities merges the src capabilities into dst.
+// A boolean capability is enabled if any upstream enables it.
+func mergeCapabilities(dst map[string]any, src map[string]any) {
+
+	for k, v := range src {
+
+		switch sv := v.(type) {
+
+		case map[string]any:
+			dv, ok := dst[k].(map[string]any)
+			if !ok {
+				dv = map[string]any{}
+				dst[k] = dv
+			}
+			mergeCapabilities(dv, sv)
+
+		case bool:
+			dv, _ := dst[k].(bool)
+			dst[k] = dv || sv
+
+		default:
+			if _, ok := dst[k]; !ok {
+				dst[k] = v
+			}
+		}
+	}
+}
+
+// withID returns the given message with its id replaced,
+// leaving the rest of the message untouched.
+func withID(data []byte, id any) []byte {
+
+	msg := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		slog.Error("Unable to decode message to replace its id", "err", err)
+		return nil
+	}
+
+	rid, err := json.Marshal(id)
+	if err != nil {
+		slog.Error("Unable to encode message id", "err", err)
+		return nil
+	}
+	msg["id"] = rid
+
+	out, err := json.Marshal(msg)
+	if err != nil {
+		slog.Error("Unable to encode message with replaced id", "err", err)
+		return nil
+	}
+
+	return out
+}
+
+func encodeMessage(msg any) []byte {
+
+	data, err := elemental.Encode(elemental.EncodingTypeJSON, msg)
+	if err != nil {
+		slog.Error("Unable to encode aggregate message", "err", err)
+		return nil
+	}
+
+	return data
+}
@@ -0,0 +1,240 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.acuvity.ai/elemental"
+	"go.acuvity.ai/minibridge/pkgs/mcp"
+)
+
+// fakeUpstream is a Client answering MCP
+// requests with the given handler.
+type fakeUpstream struct {
+	handle func(msg mcp.Message) map[string]any
+	calls  chan mcp.Message
+	stream *MCPStream
+}
+
+func newFakeUpstream(handle func(msg mcp.Message) map[string]any) *fakeUpstream {
+	return &fakeUpstream{
+		handle: handle,
+		calls:  make(chan mcp.Message, 16),
+	}
+}
+
+func (c *fakeUpstream) Type() string   { return "fake" }
+func (c *fakeUpstream) Server() string { return "fake" }
+
+func (c *fakeUpstream) Start(ctx context.Context, _ ...Option) (*MCPStream, error) {
+
+	c.stream = NewMCPStream(ctx)
+
+	go func() {
+		for {
+			select {
+
+			case data := <-c.stream.stdin:
+
+				msg := mcp.Message{}
+				if err := elemental.Decode(elemental.EncodingTypeJSON, data, &msg); err != nil {
+					panic(err)
+				}
+
+				c.calls <- msg
+
+				if msg.ID == nil || msg.Method == "" {
+					continue
+				}
+
+				resp := map[string]any{"jsonrpc": "2.0", "id": msg.ID, "result": c.handle(msg)}
+				data, _ = elemental.Encode(elemental.EncodingTypeJSON, resp)
+				c.stream.stdout <- data
+
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return c.stream, nil
+}
+
+func TestNewAggregate(t *testing.T) {
+
+	up := newFakeUpstream(nil)
+
+	Convey("Given I create an aggregate without upstreams", t, func() {
+		_, err := NewAggregate(nil)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "at least one upstream must be given")
+	})
+
+	Convey("Given I create an aggregate with an invalid upstream name", t, func() {
+		_, err := NewAggregate([]Upstream{{Name: "a__b", Client: up}})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "invalid upstream name 'a__b': must not contain '__'")
+	})
+
+	Convey("Given I create an aggregate with duplicate upstreams", t, func() {
+		_, err := NewAggregate([]Upstream{{Name: "a", Client: up}, {Name: "a", Client: up}})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "duplicate upstream name 'a'")
+	})
+
+	Convey("Given I create an aggregate with an upstream without client", t, func() {
+		_, err := NewAggregate([]Upstream{{Name: "a"}})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "upstream 'a' has no client")
+	})
+
+	Convey("Given I create a valid aggregate", t, func() {
+		cl, err := NewAggregate([]Upstream{{Name: "a", Client: up}, {Name: "b", Client: up}})
+		So(err, ShouldBeNil)
+		So(cl.Type(), ShouldEqual, "aggregate")
+		So(cl.Server(), ShouldEqual, "a,b")
+	})
+}
+
+func TestAggregate(t *testing.T) {
+
+	Convey("Given I have an aggregate of two upstreams", t, func() {
+
+		handler := func(name string, caps map[string]any) func(msg mcp.Message) map[string]any {
+			return func(msg mcp.Message) map[string]any {
+				switch msg.Method {
+				case "initialize":
+					return map[string]any{
+						"protocolVersion": "2025-03-26",
+						"capabilities":    caps,
+						"instructions":    "use " + name,
+					}
+				case "tools/list":
+					if _, ok := msg.Params["cursor"]; ok {
+						return map[string]any{"tools": []any{map[string]any{"name": "last"}}}
+					}
+					return map[string]any{"tools": []any{map[string]any{"name": "echo"}}, "nextCursor": "next"}
+				case "resources/list":
+					return map[string]any{"resources": []any{map[string]any{"name": "file", "uri": "file:///" + name}}}
+				default:
+					return map[string]any{"from": name, "params": msg.Params}
+				}
+			}
+		}
+
+		upA := newFakeUpstream(handler("a", map[string]any{"tools": map[string]any{"listChanged": true}}))
+		upB := newFakeUpstream(handler("b", map[string]any{"tools": map[string]any{"listChanged": false}, "resources": map[string]any{}}))
+
+		cl, err := NewAggregate([]Upstream{{Name: "a", Client: upA}, {Name: "b", Client: upB}})
+		So(err, ShouldBeNil)
+
+		ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+		defer cancel()
+
+		stream, err := cl.Start(ctx)
+		So(err, ShouldBeNil)
+
+		out, unregister := stream.Stdout()
+		defer unregister()
+
+		call := func(msg string) map[string]any {
+			stream.Stdin() <- []byte(msg)
+			select {
+			case data := <-out:
+				resp := map[string]any{}
+				So(elemental.Decode(elemental.EncodingTypeJSON, data, &resp), ShouldBeNil)
+				return resp
+			case <-time.After(2 * time.Second):
+				panic(fmt.Sprintf("no response to %s", msg))
+			}
+		}
+
+		Convey("Then initialize should merge the upstreams", func() {
+			resp := call(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+			So(resp["id"], ShouldEqual, 1)
+			result := resp["result"].(map[string]any)
+			So(result["protocolVersion"], ShouldEqual, "2025-03-26")
+			So(result["capabilities"], ShouldResemble, map[string]any{"tools": map[string]any{"listChanged": true}, "resources": map[string]any{}})
+			So(result["instructions"], ShouldEqual, "a: use a\n\nb: use b")
+		})
+
+		Convey("Then tools/list should return all the pages of all the upstreams prefixed", func() {
+			resp := call(`{"jsonrpc":"2.0","id":"x","method":"tools/list"}`)
+			So(resp["id"], ShouldEqual, "x")
+			So(resp["result"], ShouldResemble, map[string]any{"tools": []any{
+				map[string]any{"name": "a__echo"},
+				map[string]any{"name": "a__last"},
+				map[string]any{"name": "b__echo"},
+				map[string]any{"name": "b__last"},
+			}})
+		})
+
+		Convey("Then tools/call should be routed to the right upstream", func() {
+			resp := call(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"b__echo","arguments":{"a":1}}}`)
+			So(resp["id"], ShouldEqual, 2)
+			So(resp["result"], ShouldResemble, map[string]any{"from": "b", "params": map[string]any{"name": "echo", "arguments": map[string]any{"a": 1.0}}})
+		})
+
+		Convey("Then tools/call of an unknown tool should fail", func() {
+			resp := call(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"c__echo"}}`)
+			So(resp["error"], ShouldResemble, map[string]any{"code": -32602.0, "message": "unknown name 'c__echo'"})
+		})
+
+		Convey("Then resources/read should be routed using the listed uris", func() {
+			resp := call(`{"jsonrpc":"2.0","id":3,"method":"resources/read","params":{"uri":"file:///b"}}`)
+			So(resp["error"], ShouldResemble, map[string]any{"code": -32002.0, "message": "unknown resource 'file:///b'"})
+
+			resp = call(`{"jsonrpc":"2.0","id":3,"method":"resources/list"}`)
+			So(resp["result"], ShouldResemble, map[string]any{"resources": []any{
+				map[string]any{"name": "a__file", "uri": "file:///a"},
+				map[string]any{"name": "b__file", "uri": "file:///b"},
+			}})
+
+			resp = call(`{"jsonrpc":"2.0","id":3,"method":"resources/read","params":{"uri":"file:///b"}}`)
+			So(resp["result"].(map[string]any)["from"], ShouldEqual, "b")
+		})
+
+		Convey("Then ping should be answered directly", func() {
+			resp := call(`{"jsonrpc":"2.0","id":4,"method":"ping"}`)
+			So(resp["result"], ShouldResemble, map[string]any{})
+		})
+
+		Convey("Then notifications should be sent to all upstreams", func() {
+			stream.Stdin() <- []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+			So((<-upA.calls).Method, ShouldEqual, "notifications/initialized")
+			So((<-upB.calls).Method, ShouldEqual, "notifications/initialized")
+		})
+
+		Convey("Then the aggregate should exit when an upstream exits", func() {
+			exit, unregisterExit := stream.Exit()
+			defer unregisterExit()
+
+			upA.stream.exit <- fmt.Errorf("boom")
+
+			err := <-exit
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "upstream 'a' exited: boom")
+		})
+
+		Convey("Then upstream notifications should be forwarded", func() {
+			upB.stream.stdout <- []byte(`{"jsonrpc":"2.0","method":"notifications/tools/list_changed"}`)
+			So(string(<-out), ShouldEqual, `{"jsonrpc":"2.0","method":"notifications/tools/list_changed"}`)
+		})
+
+		Convey("Then upstream requests should be routed back with their responses", func() {
+			upB.stream.stdout <- []byte(`{"jsonrpc":"2.0","id":1,"method":"roots/list"}`)
+
+			req := map[string]any{}
+			So(elemental.Decode(elemental.EncodingTypeJSON, <-out, &req), ShouldBeNil)
+			So(req["id"], ShouldEqual, "b__1")
+
+			stream.Stdin() <- []byte(`{"jsonrpc":"2.0","id":"b__1","result":{"roots":[]}}`)
+			resp := <-upB.calls
+			So(resp.ID, ShouldEqual, 1)
+			So(resp.Result, ShouldResemble, map[string]any{"roots": []any{}})
+		})
+	})
+}
@@ -0,0 +1,133 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ErrOOMKilled is wrapped in the error sent to the exit channel
+// when the MCP server has been killed for exceeding its memory limit.
+var ErrOOMKilled = errors.New("mcp server has been killed for exceeding its memory limit")
+
+// ResourceLimits holds the limits applied to the cgroup
+// of an MCP server. Zero values mean no limit.
+type ResourceLimits struct {
+
+	// Memory is the maximum memory usage in bytes.
+	Memory int64
+
+	// CPU is the maximum number of CPUs to use. For
+	// instance, 0.5 means half of the time of one CPU.
+	CPU float64
+
+	// PIDs is the maximum number of processes and threads.
+	PIDs int64
+
+	// IO holds the limits for the block devices.
+	IO []IOLimit
+}
+
+// IsZero returns true if no limit is set.
+func (l ResourceLimits) IsZero() bool {
+	return l.Memory <= 0 && l.CPU <= 0 && l.PIDs <= 0 && len(l.IO) == 0
+}
+
+// IOLimit holds the IO limits for a block device.
+// Zero values mean no limit.
+type IOLimit struct {
+
+	// Device is the path of the block device, like /dev/sda.
+	Device string
+
+	ReadBPS   int64
+	WriteBPS  int64
+	ReadIOPS  int64
+	WriteIOPS int64
+}
+
+// ParseIOLimit parses an IOLimit in the form
+// <device>:<key>=<value>[,<key>=<value>...]
+// where key is one of rbps, wbps, riops or wiops.
+// Byte rates accept the units supported by ParseByteSize.
+// For instance: /dev/sda:rbps=10M,wiops=100.
+func ParseIOLimit(s string) (IOLimit, error) {
+
+	dev, params, ok := strings.Cut(s, ":")
+	if !ok || dev == "" || params == "" {
+		return IOLimit{}, fmt.Errorf("invalid io limit '%s': must be in the form <device>:<key>=<value>,...", s)
+	}
+
+	l := IOLimit{Device: dev}
+
+	for param := range strings.SplitSeq(params, ",") {
+
+		k, v, ok := strings.Cut(param, "=")
+		if !ok {
+			return IOLimit{}, fmt.Errorf("invalid io limit '%s': invalid parameter '%s'", s, param)
+		}
+
+		var err error
+
+		switch k {
+		case "rbps":
+			l.ReadBPS, err = ParseByteSize(v)
+		case "wbps":
+			l.WriteBPS, err = ParseByteSize(v)
+		case "riops":
+			l.ReadIOPS, err = strconv.ParseInt(v, 10, 64)
+		case "wiops":
+			l.WriteIOPS, err = strconv.ParseInt(v, 10, 64)
+		default:
+			return IOLimit{}, fmt.Errorf("invalid io limit '%s': unknown key '%s'", s, k)
+		}
+
+		if err != nil {
+			return IOLimit{}, fmt.Errorf("invalid io limit '%s': invalid value for '%s': %w", s, k, err)
+		}
+	}
+
+	return l, nil
+}
+
+// ParseByteSize parses a size in bytes with an optional
+// binary unit: K, M, G or T, optionally followed by i or iB.
+// For instance: 512M, 1.5Gi or 1024.
+func ParseByteSize(s string) (int64, error) {
+
+	str := strings.TrimSpace(s)
+	str = strings.TrimSuffix(str, "B")
+	str = strings.TrimSuffix(str, "i")
+
+	mult := int64(1)
+
+	if n := len(str); n > 0 {
+		switch str[n-1] {
+		case 'k', 'K':
+			mult = 1 << 10
+		case 'm', 'M':
+			mult = 1 << 20
+		case 'g', 'G':
+			mult = 1 << 30
+		case 't', 'T':
+			mult = 1 << 40
+		}
+		if mult > 1 {
+			str = str[:n-1]
+		}
+	}
+
+	v, err := strconv.ParseFloat(str, 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid size '%s'", s)
+	}
+
+	v *= float64(mult)
+	if v > math.MaxInt64 {
+		return 0, fmt.Errorf("invalid size '%s': overflows", s)
+	}
+
+	return int64(v), nil
+}
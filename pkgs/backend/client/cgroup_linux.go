@@ -0,0 +1,463 @@
+//go:build linux
+
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"go.acuvity.ai/minibridge/pkgs/metrics"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	cgroupRoot           = "/sys/fs/cgroup"
+	cgroupLeaf           = "minibridge"
+	cgroupCPUPeriod      = 100000
+	cgroupWatchInterval  = time.Second
+	cgroupRemoveAttempts = 20
+)
+
+var (
+	// cgroupParentLock protects the preparation of the parent cgroups.
+	cgroupParentLock sync.Mutex
+
+	// cgroupParents holds the controllers already
+	// enabled in the parent cgroups, keyed by path.
+	cgroupParents = map[string]map[string]struct{}{}
+
+	// ownCgroupOnce resolves the cgroup of minibridge before it is
+	// moved to its leaf cgroup, so the parent does not change.
+	ownCgroupOnce sync.Once
+	ownCgroupPath string
+	ownCgroupErr  error
+)
+
+// cgroup is the cgroup v2 created for an MCP server.
+type cgroup struct {
+	path    string
+	fd      int
+	server  string
+	metrics *metrics.Manager
+
+	oomKills      int64
+	throttled     int64
+	throttledUsec int64
+}
+
+// newCgroup creates a new cgroup with the given limits under
+// parent, which is a path relative to the cgroup v2 root.
+// If parent is empty, the cgroup of minibridge is used.
+func newCgroup(parent string, limits ResourceLimits, server string, mm *metrics.Manager) (*cgroup, error) {
+
+	st := unix.Statfs_t{}
+	if err := unix.Statfs(cgroupRoot, &st); err != nil || st.Type != unix.CGROUP2_SUPER_MAGIC {
+		return nil, fmt.Errorf("cgroup v2 is not mounted on %s", cgroupRoot)
+	}
+
+	if parent == "" {
+		ownCgroupOnce.Do(func() { ownCgroupPath, ownCgroupErr = ownCgroup() })
+		if ownCgroupErr != nil {
+			return nil, ownCgroupErr
+		}
+		parent = ownCgroupPath
+	}
+
+	ppath := filepath.Join(cgroupRoot, filepath.Clean("/"+parent))
+
+	if err := prepareCgroupParent(ppath, limits.controllers()); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(ppath, "mcp-"+uuid.Must(uuid.NewV7()).String())
+	if err := os.Mkdir(path, 0700); err != nil {
+		return nil, fmt.Errorf("unable to create cgroup: %w", err)
+	}
+
+	c := &cgroup{
+		path:    path,
+		fd:      -1,
+		server:  server,
+		metrics: mm,
+	}
+
+	if err := c.setLimits(limits); err != nil {
+		c.remove()
+		return nil, err
+	}
+
+	fd, err := unix.Open(path, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		c.remove()
+		return nil, fmt.Errorf("unable to open cgroup: %w", err)
+	}
+
+	c.fd = fd
+
+	return c, nil
+}
+
+// apply configures the command to be started in the cgroup.
+func (c *cgroup) apply(cmd *exec.Cmd) {
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = c.fd
+}
+
+func (c *cgroup) setLimits(limits ResourceLimits) error {
+
+	if limits.Memory > 0 {
+		if err := c.write("memory.max", strconv.FormatInt(limits.Memory, 10)); err != nil {
+			return err
+		}
+		// We don't want the server to use the swap to escape the limit.
+		if _, err := os.Stat(filepath.Join(c.path, "memory.swap.max")); err == nil {
+			if err := c.write("memory.swap.max", "0"); err != nil {
+				return err
+			}
+		}
+	}
+
+	if limits.CPU > 0 {
+		quota := max(int64(limits.CPU*cgroupCPUPeriod), 1000)
+		if err := c.write("cpu.max", fmt.Sprintf("%d %d", quota, cgroupCPUPeriod)); err != nil {
+			return err
+		}
+	}
+
+	if limits.PIDs > 0 {
+		if err := c.write("pids.max", strconv.FormatInt(limits.PIDs, 10)); err != nil {
+			return err
+		}
+	}
+
+	for _, l := range limits.IO {
+
+		st := unix.Stat_t{}
+		if err := unix.Stat(l.Device, &st); err != nil {
+			return fmt.Errorf("unable to stat io device '%s': %w", l.Device, err)
+		}
+
+		if st.Mode&unix.S_IFMT != unix.S_IFBLK {
+			return fmt.Errorf("io device '%s' is not a block device", l.Device)
+		}
+
+		line := fmt.Sprintf("%d:%d", unix.Major(st.Rdev), unix.Minor(st.Rdev))
+		for _, kv := range []struct {
+			k string
+			v int64
+		}{
+			{"rbps", l.ReadBPS},
+			{"wbps", l.WriteBPS},
+			{"riops", l.ReadIOPS},
+			{"wiops", l.WriteIOPS},
+		} {
+			if kv.v > 0 {
+				line += fmt.Sprintf(" %s=%d", kv.k, kv.v)
+			}
+		}
+
+		if err := c.write("io.max", line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// monitor starts reporting the OOM kills and CPU throttling of the
+// cgroup. It returns a function that must be called with the exit error
+// of the command once it has exited. It removes the cgroup and returns
+// the error to send to the exit channel.
+func (c *cgroup) monitor() func(error) error {
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		c.watch(done)
+	}()
+
+	return func(err error) error {
+		close(done)
+		<-stopped
+		err = c.exitReason(err)
+		c.remove()
+		return err
+	}
+}
+
+func (c *cgroup) watch(done chan struct{}) {
+
+	ticker := time.NewTicker(cgroupWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.collect()
+		case <-done:
+			return
+		}
+	}
+}
+
+// collect reads the cgroup statistics and reports
+// the changes since the last call to the metrics manager.
+func (c *cgroup) collect() {
+
+	if events, err := c.readKeyValues("memory.events"); err == nil {
+
+		if n := events["oom_kill"]; n > c.oomKills {
+
+			slog.Warn("MCP server process has been killed by the OOM killer",
+				"server", c.server,
+				"cgroup", c.path,
+				"kills", n-c.oomKills,
+			)
+
+			if c.metrics != nil {
+				c.metrics.RecordOOMKills(c.server, n-c.oomKills)
+			}
+
+			c.oomKills = n
+		}
+	}
+
+	if stats, err := c.readKeyValues("cpu.stat"); err == nil {
+
+		periods, usec := stats["nr_throttled"], stats["throttled_usec"]
+
+		if periods > c.throttled {
+
+			if c.metrics != nil {
+				c.metrics.RecordCPUThrottling(
+					c.server,
+					periods-c.throttled,
+					time.Duration(usec-c.throttledUsec)*time.Microsecond,
+				)
+			}
+
+			c.throttled = periods
+			c.throttledUsec = usec
+		}
+	}
+}
+
+// exitReason collects the statistics one last time, and
+// wraps the given exit error with ErrOOMKilled if the
+// MCP server has been killed by the OOM killer.
+func (c *cgroup) exitReason(err error) error {
+
+	c.collect()
+
+	if err == nil || c.oomKills == 0 {
+		return err
+	}
+
+	return fmt.Errorf("%w: %w", ErrOOMKilled, err)
+}
+
+// remove kills the remaining processes and removes the cgroup.
+func (c *cgroup) remove() {
+
+	if c.fd > -1 {
+		_ = unix.Close(c.fd)
+		c.fd = -1
+	}
+
+	// cgroup.kill is only available since linux 5.14.
+	_ = c.write("cgroup.kill", "1")
+
+	for range cgroupRemoveAttempts {
+
+		err := unix.Rmdir(c.path)
+		if err == nil || errors.Is(err, unix.ENOENT) {
+			return
+		}
+
+		if !errors.Is(err, unix.EBUSY) {
+			slog.Warn("Unable to remove MCP server cgroup", "cgroup", c.path, "err", err)
+			return
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	slog.Warn("Unable to remove MCP server cgroup: still in use", "cgroup", c.path)
+}
+
+func (c *cgroup) write(file string, value string) error {
+
+	if err := os.WriteFile(filepath.Join(c.path, file), []byte(value), 0); err != nil {
+		return fmt.Errorf("unable to write cgroup %s: %w", file, err)
+	}
+
+	return nil
+}
+
+func (c *cgroup) readKeyValues(file string) (map[string]int64, error) {
+
+	data, err := os.ReadFile(filepath.Join(c.path, file)) // #nosec: G304
+	if err != nil {
+		return nil, err
+	}
+
+	out := map[string]int64{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		k, v, ok := strings.Cut(scanner.Text(), " ")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			out[k] = n
+		}
+	}
+
+	return out, nil
+}
+
+// controllers returns the cgroup controllers needed to enforce the limits.
+func (l ResourceLimits) controllers() []string {
+
+	var ctrls []string
+
+	if l.Memory > 0 {
+		ctrls = append(ctrls, "memory")
+	}
+
+	if l.CPU > 0 {
+		ctrls = append(ctrls, "cpu")
+	}
+
+	if l.PIDs > 0 {
+		ctrls = append(ctrls, "pids")
+	}
+
+	if len(l.IO) > 0 {
+		ctrls = append(ctrls, "io")
+	}
+
+	return ctrls
+}
+
+// ownCgroup returns the cgroup v2 of the current
+// process, relative to the cgroup v2 root.
+func ownCgroup() (string, error) {
+
+	data, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", fmt.Errorf("unable to read own cgroup: %w", err)
+	}
+
+	for line := range strings.SplitSeq(string(data), "\n") {
+		if path, ok := strings.CutPrefix(line, "0::"); ok {
+			return path, nil
+		}
+	}
+
+	return "", fmt.Errorf("unable to find own cgroup v2")
+}
+
+// prepareCgroupParent enables the given controllers for the children of
+// the parent cgroup. As cgroup v2 does not allow a cgroup with enabled
+// controllers to contain processes, minibridge is moved to a leaf cgroup
+// if it is in the parent. Other processes are never moved: if the parent
+// contains some, a delegated cgroup must be given as parent instead.
+// The controllers enabled in each parent are cached.
+func prepareCgroupParent(parent string, ctrls []string) error {
+
+	if len(ctrls) == 0 {
+		return nil
+	}
+
+	cgroupParentLock.Lock()
+	defer cgroupParentLock.Unlock()
+
+	enabled := cgroupParents[parent]
+
+	enable := make([]string, 0, len(ctrls))
+	for _, ctrl := range ctrls {
+		if _, ok := enabled[ctrl]; !ok {
+			enable = append(enable, "+"+ctrl)
+		}
+	}
+
+	if len(enable) == 0 {
+		return nil
+	}
+
+	available, err := os.ReadFile(filepath.Join(parent, "cgroup.controllers")) // #nosec: G304
+	if err != nil {
+		return fmt.Errorf("unable to read available cgroup controllers: %w", err)
+	}
+
+	for _, ctrl := range enable {
+		if !strings.Contains(" "+strings.TrimSpace(string(available))+" ", " "+ctrl[1:]+" ") {
+			return fmt.Errorf("cgroup controller '%s' is not available in %s", ctrl[1:], parent)
+		}
+	}
+
+	control := filepath.Join(parent, "cgroup.subtree_control")
+
+	err = os.WriteFile(control, []byte(strings.Join(enable, " ")), 0)
+
+	if errors.Is(err, unix.EBUSY) {
+
+		leaf := filepath.Join(parent, cgroupLeaf)
+		if err := os.Mkdir(leaf, 0700); err != nil && !errors.Is(err, os.ErrExist) {
+			return fmt.Errorf("unable to create leaf cgroup: %w", err)
+		}
+
+		// Only minibridge itself is moved, and only if it is in
+		// the parent. Other processes are left where they are.
+		if procs, err := os.ReadFile(filepath.Join(parent, "cgroup.procs")); err == nil { // #nosec: G304
+			pid := strconv.Itoa(os.Getpid())
+			if slices.Contains(strings.Fields(string(procs)), pid) {
+				if err := os.WriteFile(filepath.Join(leaf, "cgroup.procs"), []byte(pid), 0); err != nil {
+					return fmt.Errorf("unable to move minibridge to leaf cgroup: %w", err)
+				}
+			}
+		}
+
+		err = os.WriteFile(control, []byte(strings.Join(enable, " ")), 0)
+		if errors.Is(err, unix.EBUSY) {
+			return fmt.Errorf("unable to enable cgroup controllers: %s contains other processes: use a delegated cgroup as parent", parent)
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("unable to enable cgroup controllers: %w", err)
+	}
+
+	if enabled == nil {
+		enabled = map[string]struct{}{}
+		cgroupParents[parent] = enabled
+	}
+
+	for _, ctrl := range enable {
+		enabled[ctrl[1:]] = struct{}{}
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+//go:build linux
+
+package client
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCgroup(t *testing.T) {
+
+	if _, err := runScript(t, "true", OptStdioResourceLimits(ResourceLimits{PIDs: 100, Memory: 64 << 20})); err != nil {
+		t.Skipf("cgroup v2 is not usable in this environment: %s", err)
+	}
+
+	Convey("Given I run a command with a pids limit", t, func() {
+
+		lines, err := runScript(t, `cat /sys/fs/cgroup$(grep ^0:: /proc/self/cgroup | cut -d: -f3)/pids.max`, OptStdioResourceLimits(ResourceLimits{PIDs: 10}))
+		So(err, ShouldBeNil)
+		So(lines, ShouldResemble, []string{"10"})
+	})
+
+	Convey("Given I run a command exceeding its memory limit", t, func() {
+
+		_, err := runScript(t, `head -c 256M /dev/zero | tail`, OptStdioResourceLimits(ResourceLimits{Memory: 16 << 20}))
+		So(err, ShouldNotBeNil)
+		So(errors.Is(err, ErrOOMKilled), ShouldBeTrue)
+	})
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package client
+
+import (
+	"fmt"
+	"os/exec"
+
+	"go.acuvity.ai/minibridge/pkgs/metrics"
+)
+
+type cgroup struct{}
+
+func newCgroup(string, ResourceLimits, string, *metrics.Manager) (*cgroup, error) {
+	return nil, fmt.Errorf("resource limits are only supported on linux")
+}
+
+func (c *cgroup) apply(*exec.Cmd)            {}
+func (c *cgroup) monitor() func(error) error { return func(err error) error { return err } }
+func (c *cgroup) remove()                    {}
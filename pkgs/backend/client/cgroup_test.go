@@ -0,0 +1,75 @@
+package client
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseByteSize(t *testing.T) {
+
+	tests := map[string]int64{
+		"1024":   1024,
+		"1K":     1 << 10,
+		"512M":   512 << 20,
+		"512Mi":  512 << 20,
+		"512MiB": 512 << 20,
+		"1.5G":   3 << 29,
+		"2t":     2 << 40,
+		"0":      0,
+	}
+
+	Convey("ParseByteSize should work", t, func() {
+		for in, expected := range tests {
+			v, err := ParseByteSize(in)
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, expected)
+		}
+	})
+
+	Convey("ParseByteSize should fail on invalid sizes", t, func() {
+		for _, in := range []string{"", "M", "-1M", "12X", "1PB"} {
+			_, err := ParseByteSize(in)
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
+
+func TestParseIOLimit(t *testing.T) {
+
+	Convey("ParseIOLimit should work", t, func() {
+
+		l, err := ParseIOLimit("/dev/sda:rbps=10M,wbps=1M,riops=100,wiops=50")
+		So(err, ShouldBeNil)
+		So(l, ShouldResemble, IOLimit{
+			Device:    "/dev/sda",
+			ReadBPS:   10 << 20,
+			WriteBPS:  1 << 20,
+			ReadIOPS:  100,
+			WriteIOPS: 50,
+		})
+	})
+
+	Convey("ParseIOLimit should fail on invalid limits", t, func() {
+
+		_, err := ParseIOLimit("/dev/sda")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "invalid io limit '/dev/sda': must be in the form <device>:<key>=<value>,...")
+
+		_, err = ParseIOLimit("/dev/sda:rbps")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "invalid io limit '/dev/sda:rbps': invalid parameter 'rbps'")
+
+		_, err = ParseIOLimit("/dev/sda:nope=1")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "invalid io limit '/dev/sda:nope=1': unknown key 'nope'")
+
+		_, err = ParseIOLimit("/dev/sda:riops=x")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("ResourceLimits.IsZero should work", t, func() {
+		So(ResourceLimits{}.IsZero(), ShouldBeTrue)
+		So(ResourceLimits{PIDs: 10}.IsZero(), ShouldBeFalse)
+	})
+}
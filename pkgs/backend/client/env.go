@@ -0,0 +1,119 @@
+package client
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/zalando/go-keyring"
+)
+
+var envRefRegexp = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// resolveEnv resolves the references contained in the values of the
+// given KEY=VALUE variables. A reference can be:
+//   - ${VAR}: the value of the variable VAR in minibridge environment.
+//   - ${file:/path}: the content of the file, without the trailing newline.
+//   - ${keyring:service/user}: the secret stored in the OS keyring.
+func resolveEnv(env []string) ([]string, error) {
+
+	resolved := make([]string, 0, len(env))
+
+	for _, e := range env {
+
+		k, v, _ := strings.Cut(e, "=")
+
+		if !envRefRegexp.MatchString(v) {
+			resolved = append(resolved, e)
+			continue
+		}
+
+		var rerr error
+		v = envRefRegexp.ReplaceAllStringFunc(v, func(ref string) string {
+			if rerr != nil {
+				return ""
+			}
+			var out string
+			out, rerr = resolveEnvRef(ref[2 : len(ref)-1])
+			return out
+		})
+
+		if rerr != nil {
+			return nil, fmt.Errorf("unable to resolve env '%s': %w", k, rerr)
+		}
+
+		resolved = append(resolved, k+"="+v)
+	}
+
+	return resolved, nil
+}
+
+// envNames returns the names of the given KEY=VALUE variables,
+// so they can be logged without leaking their values.
+func envNames(env []string) []string {
+
+	out := make([]string, 0, len(env))
+	for _, e := range env {
+		k, _, _ := strings.Cut(e, "=")
+		out = append(out, k)
+	}
+
+	return out
+}
+
+func resolveEnvRef(ref string) (string, error) {
+
+	if path, ok := strings.CutPrefix(ref, "file:"); ok {
+
+		data, err := os.ReadFile(path) // #nosec: G304
+		if err != nil {
+			return "", fmt.Errorf("unable to read secret file: %w", err)
+		}
+
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+
+	if item, ok := strings.CutPrefix(ref, "keyring:"); ok {
+
+		service, user, ok := strings.Cut(item, "/")
+		if !ok || service == "" || user == "" {
+			return "", fmt.Errorf("invalid keyring reference '%s': must be keyring:service/user", item)
+		}
+
+		secret, err := keyring.Get(service, user)
+		if err != nil {
+			return "", fmt.Errorf("unable to retrieve secret '%s' from keyring: %w", item, err)
+		}
+
+		return secret, nil
+	}
+
+	v, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", fmt.Errorf("variable '%s' is not set", ref)
+	}
+
+	return v, nil
+}
+
+// filterEnv returns the variables of env whose names match one
+// of the allowed names. A name ending with * matches a prefix.
+func filterEnv(env []string, allowed []string) []string {
+
+	out := make([]string, 0, len(allowed))
+
+	for _, e := range env {
+
+		k, _, _ := strings.Cut(e, "=")
+
+		for _, a := range allowed {
+			if p, ok := strings.CutSuffix(a, "*"); ok && strings.HasPrefix(k, p) || k == a {
+				out = append(out, e)
+				break
+			}
+		}
+	}
+
+	return out
+}
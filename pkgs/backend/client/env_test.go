@@ -0,0 +1,86 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/zalando/go-keyring"
+)
+
+func TestResolveEnv(t *testing.T) {
+
+	Convey("Given I have variables without references", t, func() {
+		resolved, err := resolveEnv([]string{"A=a", "B=$B", "C"})
+		So(err, ShouldBeNil)
+		So(resolved, ShouldResemble, []string{"A=a", "B=$B", "C"})
+	})
+
+	Convey("Given I have variables referencing the environment", t, func() {
+		t.Setenv("MINIBRIDGE_TEST_SECRET", "s3cr3t")
+		resolved, err := resolveEnv([]string{"A=a", "TOKEN=Bearer ${MINIBRIDGE_TEST_SECRET}"})
+		So(err, ShouldBeNil)
+		So(resolved, ShouldResemble, []string{"A=a", "TOKEN=Bearer s3cr3t"})
+	})
+
+	Convey("Given I have a variable referencing a missing variable", t, func() {
+		_, err := resolveEnv([]string{"TOKEN=${MINIBRIDGE_TEST_NOT_SET}"})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "unable to resolve env 'TOKEN': variable 'MINIBRIDGE_TEST_NOT_SET' is not set")
+	})
+
+	Convey("Given I have a variable referencing a file", t, func() {
+		path := filepath.Join(t.TempDir(), "secret")
+		So(os.WriteFile(path, []byte("s3cr3t\n"), 0600), ShouldBeNil)
+		resolved, err := resolveEnv([]string{"TOKEN=${file:" + path + "}"})
+		So(err, ShouldBeNil)
+		So(resolved, ShouldResemble, []string{"TOKEN=s3cr3t"})
+	})
+
+	Convey("Given I have a variable referencing a missing file", t, func() {
+		_, err := resolveEnv([]string{"TOKEN=${file:/not/here}"})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "unable to resolve env 'TOKEN': unable to read secret file: open /not/here: no such file or directory")
+	})
+
+	Convey("Given I have a variable referencing the keyring", t, func() {
+		keyring.MockInit()
+		So(keyring.Set("svc", "user", "s3cr3t"), ShouldBeNil)
+
+		resolved, err := resolveEnv([]string{"TOKEN=${keyring:svc/user}"})
+		So(err, ShouldBeNil)
+		So(resolved, ShouldResemble, []string{"TOKEN=s3cr3t"})
+
+		_, err = resolveEnv([]string{"TOKEN=${keyring:svc/other}"})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "unable to resolve env 'TOKEN': unable to retrieve secret 'svc/other' from keyring: secret not found in keyring")
+
+		_, err = resolveEnv([]string{"TOKEN=${keyring:svc}"})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "unable to resolve env 'TOKEN': invalid keyring reference 'svc': must be keyring:service/user")
+	})
+}
+
+func TestEnvNames(t *testing.T) {
+
+	Convey("Given I have variables", t, func() {
+		So(envNames([]string{"A=a", "TOKEN=ghp_s3cr3t", "C"}), ShouldResemble, []string{"A", "TOKEN", "C"})
+	})
+}
+
+func TestFilterEnv(t *testing.T) {
+
+	Convey("Given I have an environment", t, func() {
+
+		env := []string{"PATH=/bin", "HOME=/root", "LC_ALL=C", "LC_TIME=C", "AWS_SECRET_ACCESS_KEY=secret"}
+
+		Convey("Filtering it with names and prefixes should work", func() {
+			So(filterEnv(env, []string{"PATH", "LC_*"}), ShouldResemble, []string{"PATH=/bin", "LC_ALL=C", "LC_TIME=C"})
+		})
+
+		Convey("Filtering it with nothing should work", func() {
+			So(filterEnv(env, nil), ShouldBeEmpty)
+		})
+	})
+}
@@ -9,6 +9,7 @@ import (
 
 type cfg struct {
 	auth *auth.Auth
+	env  []string
 }
 
 type Option func(*cfg)
@@ -19,6 +20,15 @@ func OptionAuth(a *auth.Auth) Option {
 	}
 }
 
+// OptionEnv adds environment variables to the MCP server
+// command. It overrides the variables of MCPServer.Env.
+// It is ignored by remote clients.
+func OptionEnv(env ...string) Option {
+	return func(c *cfg) {
+		c.env = append(c.env, env...)
+	}
+}
+
 // A Client is the interface of object that can
 // act as a minibridge mcp Client.
 type Client interface {
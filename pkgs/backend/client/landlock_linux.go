@@ -0,0 +1,162 @@
+//go:build linux
+
+package client
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// landlockSpec describes the Landlock rules applied by the sandbox shim.
+type landlockSpec struct {
+	ABI       int      `json:"abi"`
+	ReadOnly  []string `json:"readOnly,omitempty"`
+	ReadWrite []string `json:"readWrite,omitempty"`
+}
+
+const (
+	landlockFileAccess = unix.LANDLOCK_ACCESS_FS_EXECUTE |
+		unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE |
+		unix.LANDLOCK_ACCESS_FS_TRUNCATE |
+		unix.LANDLOCK_ACCESS_FS_IOCTL_DEV
+
+	landlockReadAccess = unix.LANDLOCK_ACCESS_FS_EXECUTE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_DIR
+)
+
+// landlockABI returns the Landlock ABI version supported by the kernel.
+func landlockABI() (int, error) {
+
+	abi, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		if errors.Is(errno, unix.ENOSYS) || errors.Is(errno, unix.EOPNOTSUPP) {
+			return 0, fmt.Errorf("landlock is not supported or not enabled by the kernel")
+		}
+		return 0, fmt.Errorf("unable to get landlock abi version: %w", errno)
+	}
+
+	return int(abi), nil
+}
+
+// landlockHandledAccess returns the filesystem
+// accesses handled by the given ABI version.
+func landlockHandledAccess(abi int) uint64 {
+
+	access := uint64(unix.LANDLOCK_ACCESS_FS_EXECUTE |
+		unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_DIR |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_DIR |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_FILE |
+		unix.LANDLOCK_ACCESS_FS_MAKE_CHAR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_DIR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_REG |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_FIFO |
+		unix.LANDLOCK_ACCESS_FS_MAKE_BLOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SYM)
+
+	if abi >= 2 {
+		access |= unix.LANDLOCK_ACCESS_FS_REFER
+	}
+
+	if abi >= 3 {
+		access |= unix.LANDLOCK_ACCESS_FS_TRUNCATE
+	}
+
+	if abi >= 5 {
+		access |= unix.LANDLOCK_ACCESS_FS_IOCTL_DEV
+	}
+
+	return access
+}
+
+// applyLandlock restricts the calling thread and its future
+// children to the paths of the given spec. Paths that do
+// not exist are ignored. The caller must have locked its
+// OS thread.
+func applyLandlock(spec landlockSpec) error {
+
+	handled := landlockHandledAccess(spec.ABI)
+
+	attr := unix.LandlockRulesetAttr{Access_fs: handled}
+	rfd, _, errno := unix.Syscall(
+		unix.SYS_LANDLOCK_CREATE_RULESET,
+		uintptr(unsafe.Pointer(&attr)), // #nosec: G103
+		unsafe.Sizeof(attr),
+		0,
+	)
+	if errno != 0 {
+		return fmt.Errorf("unable to create landlock ruleset: %w", errno)
+	}
+	defer func() { _ = unix.Close(int(rfd)) }()
+
+	for _, rule := range []struct {
+		paths  []string
+		access uint64
+	}{
+		{spec.ReadOnly, handled & landlockReadAccess},
+		{spec.ReadWrite, handled},
+	} {
+		for _, path := range rule.paths {
+			if err := addLandlockRule(int(rfd), path, rule.access); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("unable to set no new privs: %w", err)
+	}
+
+	if _, _, errno := unix.Syscall(unix.SYS_LANDLOCK_RESTRICT_SELF, rfd, 0, 0); errno != 0 {
+		return fmt.Errorf("unable to enforce landlock ruleset: %w", errno)
+	}
+
+	return nil
+}
+
+func addLandlockRule(rfd int, path string, access uint64) error {
+
+	fd, err := unix.Open(path, unix.O_PATH|unix.O_CLOEXEC, 0)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("unable to open landlock path '%s': %w", path, err)
+	}
+	defer func() { _ = unix.Close(fd) }()
+
+	st := unix.Stat_t{}
+	if err := unix.Fstat(fd, &st); err != nil {
+		return fmt.Errorf("unable to stat landlock path '%s': %w", path, err)
+	}
+
+	// Directory accesses cannot be granted on files.
+	if st.Mode&unix.S_IFMT != unix.S_IFDIR {
+		access &= landlockFileAccess
+	}
+
+	attr := unix.LandlockPathBeneathAttr{
+		Allowed_access: access,
+		Parent_fd:      int32(fd), // #nosec: G115
+	}
+
+	if _, _, errno := unix.Syscall6(
+		unix.SYS_LANDLOCK_ADD_RULE,
+		uintptr(rfd),
+		unix.LANDLOCK_RULE_PATH_BENEATH,
+		uintptr(unsafe.Pointer(&attr)), // #nosec: G103
+		0, 0, 0,
+	); errno != 0 {
+		return fmt.Errorf("unable to add landlock rule for '%s': %w", path, errno)
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+//go:build linux
+
+package client
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLandlock(t *testing.T) {
+
+	if _, err := landlockABI(); err != nil {
+		t.Skipf("landlock is not supported in this environment: %s", err)
+	}
+
+	ro := []string{"/bin", "/sbin", "/usr", "/lib", "/lib64", "/etc"}
+	rw := []string{"/dev/null"}
+
+	Convey("Given I run a command restricted by landlock", t, func() {
+
+		lines, err := runScript(t,
+			`cat /etc/passwd >/dev/null && echo read; touch /etc/minibridge-test 2>/dev/null || echo ro; ls /var 2>/dev/null || echo hidden; touch testfile && echo rw`,
+			OptStdioLandlock(ro, rw),
+			OptStdioUseTempDir(true),
+		)
+		So(err, ShouldBeNil)
+		So(lines, ShouldResemble, []string{"read", "ro", "hidden", "rw"})
+	})
+
+	Convey("Given I run a command restricted by landlock with missing paths", t, func() {
+
+		lines, err := runScript(t, `echo ok`, OptStdioLandlock(append(ro, "/does/not/exist"), rw))
+		So(err, ShouldBeNil)
+		So(lines, ShouldResemble, []string{"ok"})
+	})
+
+	Convey("Given I run a command restricted by landlock without access to the command", t, func() {
+
+		_, err := runScript(t, `echo ok`, OptStdioLandlock(nil, nil))
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "exit status 126")
+	})
+
+	Convey("Given I run a command restricted by landlock and seccomp in the sandbox", t, func() {
+
+		if _, err := runSandboxed(t, "true"); err != nil {
+			SkipConvey("sandbox is not supported in this environment", func() {})
+			return
+		}
+
+		p, _ := GetSeccompProfile("default")
+
+		lines, err := runSandboxed(t, `ls /var 2>/dev/null || echo hidden; echo $PPID`, OptStdioLandlock(ro, rw), OptStdioSeccomp(p))
+		So(err, ShouldBeNil)
+		So(lines, ShouldResemble, []string{"hidden", "1"})
+	})
+}
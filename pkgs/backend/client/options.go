@@ -3,6 +3,9 @@ package client
 import (
 	"fmt"
 	"math"
+	"time"
+
+	"go.acuvity.ai/minibridge/pkgs/metrics"
 )
 
 type creds struct {
@@ -12,8 +15,31 @@ type creds struct {
 }
 
 type stdioCfg struct {
-	useTempDir bool
-	creds      *creds
+	useTempDir     bool
+	creds          *creds
+	sandbox        bool
+	seccomp        *SeccompProfile
+	landlock       *landlockPaths
+	limits         ResourceLimits
+	cgroupParent   string
+	metricsManager *metrics.Manager
+	cleanEnv       bool
+	envAllow       []string
+	resolveEnv     bool
+	poolSize       int
+	poolMax        int
+}
+
+func (c stdioCfg) seccompName() string {
+	if c.seccomp == nil {
+		return ""
+	}
+	return c.seccomp.Name
+}
+
+type landlockPaths struct {
+	readOnly  []string
+	readWrite []string
 }
 
 func newStdioCfg() stdioCfg {
@@ -76,3 +102,187 @@ func OptStdioCredentials(uid int, gid int, groups []int) StdioOption {
 		}
 	}
 }
+
+// OptStdioSandbox runs the command in new user, mount, pid,
+// ipc and uts namespaces, with a read-only view of the root
+// filesystem and a private /tmp. The working dir is writable
+// only when OptStdioUseTempDir is also set.
+// This is only supported on linux and cannot be used with
+// OptStdioCredentials.
+func OptStdioSandbox(enabled bool) StdioOption {
+	return func(c *stdioCfg) {
+		c.sandbox = enabled
+	}
+}
+
+// OptStdioSeccomp installs a seccomp filter on the command
+// using the given profile. Blocked syscalls fail with EPERM
+// and are logged and counted if a metrics manager is set.
+// See GetSeccompProfile and LoadSeccompProfile.
+// This is only supported on linux, on amd64 and arm64.
+func OptStdioSeccomp(profile SeccompProfile) StdioOption {
+	return func(c *stdioCfg) {
+		c.seccomp = &profile
+	}
+}
+
+// OptStdioLandlock restricts the filesystem access of the command
+// using Landlock. The command can read and execute the readOnly paths,
+// and has full access to the readWrite paths and to its working dir.
+// Any other path is inaccessible. Paths that do not exist are ignored.
+// Unlike OptStdioSandbox, this does not require any privilege.
+// This is only supported on linux 5.13+ with Landlock enabled.
+func OptStdioLandlock(readOnly []string, readWrite []string) StdioOption {
+	return func(c *stdioCfg) {
+		c.landlock = &landlockPaths{
+			readOnly:  readOnly,
+			readWrite: readWrite,
+		}
+	}
+}
+
+// OptStdioResourceLimits runs each command in its own
+// cgroup v2 with the given memory, cpu, pids and io limits.
+// OOM kills are reported in the error sent to the exit
+// channel, wrapping ErrOOMKilled.
+// This is only supported on linux, with cgroup v2.
+func OptStdioResourceLimits(limits ResourceLimits) StdioOption {
+	return func(c *stdioCfg) {
+		c.limits = limits
+	}
+}
+
+// OptStdioCgroupParent sets the cgroup, relative to the cgroup v2
+// root, in which the cgroups of the commands are created when using
+// OptStdioResourceLimits. By default, the cgroup of minibridge is used
+// and minibridge moves itself in a leaf cgroup named "minibridge".
+// Minibridge must be allowed to manage the given cgroup.
+func OptStdioCgroupParent(parent string) StdioOption {
+	return func(c *stdioCfg) {
+		c.cgroupParent = parent
+	}
+}
+
+// OptStdioMetricsManager sets the metric manager used to report
+// the syscalls blocked by seccomp, OOM kills and cpu throttling.
+func OptStdioMetricsManager(m *metrics.Manager) StdioOption {
+	return func(c *stdioCfg) {
+		c.metricsManager = m
+	}
+}
+
+// OptStdioCleanEnv starts the command with a clean environment,
+// only inheriting the variables of minibridge whose names are in
+// allowed. A name ending with * matches all the variables starting
+// with it, like LC_*. MCPServer.Env is always passed.
+func OptStdioCleanEnv(allowed ...string) StdioOption {
+	return func(c *stdioCfg) {
+		c.cleanEnv = true
+		c.envAllow = allowed
+	}
+}
+
+// OptStdioResolveEnv resolves the references contained in the values
+// of MCPServer.Env when the command starts: ${VAR}, ${file:/path} and
+// ${keyring:service/user}. It must only be used when MCPServer.Env is
+// trusted, as references give access to the secrets of minibridge.
+func OptStdioResolveEnv() StdioOption {
+	return func(c *stdioCfg) {
+		c.resolveEnv = true
+	}
+}
+
+// OptStdioPool keeps size started and idle instances of the command
+// ready to be handed to new sessions, removing their startup time.
+// The idle instances are initialized with the MCP handshake, and the
+// initialize request of the agent is answered with the cached result
+// if it requests the same protocol version. Otherwise, the instance is
+// replaced by a new one the agent initializes itself. The pool is refilled in the background until the client is closed.
+// If maxAlive is greater than 0, it caps the number of instances alive
+// at once, including the ones used by sessions, and Start returns
+// ErrPoolExhausted once reached. Start returns ErrPoolEnv when given
+// OptionEnv, as the environment of the instances is set in advance.
+func OptStdioPool(size int, maxAlive int) StdioOption {
+	return func(c *stdioCfg) {
+		c.poolSize = size
+		c.poolMax = maxAlive
+	}
+}
+
+type supervisorCfg struct {
+	maxRestarts    int
+	backoffMin     time.Duration
+	backoffMax     time.Duration
+	metricsManager *metrics.Manager
+}
+
+func newSupervisorCfg() supervisorCfg {
+	return supervisorCfg{
+		maxRestarts: 5,
+		backoffMin:  500 * time.Millisecond,
+		backoffMax:  30 * time.Second,
+	}
+}
+
+// backoff returns the time to wait before the given restart attempt.
+func (c supervisorCfg) backoff(attempt int) time.Duration {
+
+	d := c.backoffMin
+	for i := 1; i < attempt && d < c.backoffMax; i++ {
+		d *= 2
+	}
+
+	return min(d, c.backoffMax)
+}
+
+// A SupervisorOption can be passed to NewSupervisor.
+type SupervisorOption func(*supervisorCfg)
+
+// OptSupervisorMaxRestarts sets the maximum number of consecutive
+// restarts before giving up. The count is reset once the server
+// has been running for a minute. The default is 5.
+func OptSupervisorMaxRestarts(n int) SupervisorOption {
+	return func(c *supervisorCfg) {
+		c.maxRestarts = n
+	}
+}
+
+// OptSupervisorBackoff sets the minimum and maximum time to wait before
+// restarting the server. The time doubles after each consecutive restart.
+// The defaults are 500ms and 30s.
+func OptSupervisorBackoff(minBackoff time.Duration, maxBackoff time.Duration) SupervisorOption {
+	return func(c *supervisorCfg) {
+		c.backoffMin = minBackoff
+		c.backoffMax = maxBackoff
+	}
+}
+
+// OptSupervisorMetricsManager sets the metric manager
+// used to report the restarts of the servers.
+func OptSupervisorMetricsManager(m *metrics.Manager) SupervisorOption {
+	return func(c *supervisorCfg) {
+		c.metricsManager = m
+	}
+}
+
+type aggregateCfg struct {
+	separator string
+}
+
+func newAggregateCfg() aggregateCfg {
+	return aggregateCfg{
+		separator: "__",
+	}
+}
+
+// An AggregateOption can be passed to NewAggregate.
+type AggregateOption func(*aggregateCfg)
+
+// OptAggregateSeparator sets the separator between the name of
+// an upstream and the name of its tools, prompts and resources.
+// The default is "__", giving names like github__create_issue.
+func OptAggregateSeparator(sep string) AggregateOption {
+	return func(c *aggregateCfg) {
+		c.separator = sep
+	}
+}
@@ -3,8 +3,10 @@ package client
 import (
 	"math"
 	"testing"
+	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
+	"go.acuvity.ai/minibridge/pkgs/metrics"
 )
 
 func TestOptions(t *testing.T) {
@@ -15,6 +17,81 @@ func TestOptions(t *testing.T) {
 		So(cfg.useTempDir, ShouldBeTrue)
 	})
 
+	Convey("OptStdioSandbox should work", t, func() {
+		cfg := newStdioCfg()
+		OptStdioSandbox(true)(&cfg)
+		So(cfg.sandbox, ShouldBeTrue)
+	})
+
+	Convey("OptStdioSeccomp should work", t, func() {
+		cfg := newStdioCfg()
+		OptStdioSeccomp(SeccompProfile{Name: "test"})(&cfg)
+		So(cfg.seccomp, ShouldNotBeNil)
+		So(cfg.seccompName(), ShouldEqual, "test")
+	})
+
+	Convey("OptStdioLandlock should work", t, func() {
+		cfg := newStdioCfg()
+		OptStdioLandlock([]string{"/usr"}, []string{"/dev/null"})(&cfg)
+		So(cfg.landlock.readOnly, ShouldResemble, []string{"/usr"})
+		So(cfg.landlock.readWrite, ShouldResemble, []string{"/dev/null"})
+	})
+
+	Convey("OptStdioResourceLimits should work", t, func() {
+		cfg := newStdioCfg()
+		OptStdioResourceLimits(ResourceLimits{PIDs: 10})(&cfg)
+		So(cfg.limits.PIDs, ShouldEqual, 10)
+	})
+
+	Convey("OptStdioCgroupParent should work", t, func() {
+		cfg := newStdioCfg()
+		OptStdioCgroupParent("/minibridge.slice")(&cfg)
+		So(cfg.cgroupParent, ShouldEqual, "/minibridge.slice")
+	})
+
+	Convey("OptStdioMetricsManager should work", t, func() {
+		cfg := newStdioCfg()
+		m := &metrics.Manager{}
+		OptStdioMetricsManager(m)(&cfg)
+		So(cfg.metricsManager, ShouldEqual, m)
+	})
+
+	Convey("OptStdioCleanEnv should work", t, func() {
+		cfg := newStdioCfg()
+		OptStdioCleanEnv("PATH", "LC_*")(&cfg)
+		So(cfg.cleanEnv, ShouldBeTrue)
+		So(cfg.envAllow, ShouldResemble, []string{"PATH", "LC_*"})
+	})
+
+	Convey("OptStdioResolveEnv should work", t, func() {
+		cfg := newStdioCfg()
+		OptStdioResolveEnv()(&cfg)
+		So(cfg.resolveEnv, ShouldBeTrue)
+	})
+
+	Convey("OptStdioPool should work", t, func() {
+		cfg := newStdioCfg()
+		OptStdioPool(2, 10)(&cfg)
+		So(cfg.poolSize, ShouldEqual, 2)
+		So(cfg.poolMax, ShouldEqual, 10)
+	})
+
+	Convey("Supervisor options should work", t, func() {
+		cfg := newSupervisorCfg()
+		m := &metrics.Manager{}
+		OptSupervisorMaxRestarts(3)(&cfg)
+		OptSupervisorBackoff(time.Second, 5*time.Second)(&cfg)
+		OptSupervisorMetricsManager(m)(&cfg)
+		So(cfg.maxRestarts, ShouldEqual, 3)
+		So(cfg.backoffMin, ShouldEqual, time.Second)
+		So(cfg.backoffMax, ShouldEqual, 5*time.Second)
+		So(cfg.metricsManager, ShouldEqual, m)
+		So(cfg.backoff(1), ShouldEqual, time.Second)
+		So(cfg.backoff(2), ShouldEqual, 2*time.Second)
+		So(cfg.backoff(3), ShouldEqual, 4*time.Second)
+		So(cfg.backoff(4), ShouldEqual, 5*time.Second)
+	})
+
 	Convey("OptCredentials should work", t, func() {
 		cfg := newStdioCfg()
 		OptStdioCredentials(1000, 1001, []int{2001, 2002})(&cfg)
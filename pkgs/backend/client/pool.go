@@ -0,0 +1,414 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.acuvity.ai/elemental"
+	"go.acuvity.ai/minibridge/pkgs/mcp"
+)
+
+const (
+	// stdioPoolRefillInterval is the interval at which the pool
+	// checks if it needs to start new instances, in addition to
+	// when an idle instance is handed to a session.
+	stdioPoolRefillInterval = time.Second
+
+	// stdioPoolWarmTimeout is the maximum time to wait for
+	// a new instance to complete the initialize handshake.
+	stdioPoolWarmTimeout = 30 * time.Second
+)
+
+// ErrPoolExhausted is returned by Start when the maximum
+// number of instances alive at once has been reached.
+var ErrPoolExhausted = errors.New("mcp server pool exhausted")
+
+// ErrPoolEnv is returned by Start when given OptionEnv while using a pool,
+// as the environment of the instances is set before they are handed out.
+var ErrPoolEnv = errors.New("mcp server pool cannot be used with a per session environment")
+
+// pooledSession is a session an idle instance is handed to.
+type pooledSession struct {
+	ctx    context.Context
+	stream *MCPStream
+}
+
+// pooledServer is an instance of the command started by the pool.
+type pooledServer struct {
+	inner   *watchedStream
+	cancel  context.CancelFunc
+	init    map[string]any
+	version string
+	handoff chan pooledSession
+}
+
+// stdioPool keeps started and initialized instances
+// of the command of a stdioClient ready to be used.
+type stdioPool struct {
+	client   *stdioClient
+	size     int
+	max      int
+	idle     []*pooledServer
+	alive    int
+	refillCh chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	sync.Mutex
+}
+
+func newStdioPool(client *stdioClient, size int, maxAlive int) *stdioPool {
+
+	if maxAlive > 0 {
+		size = min(size, maxAlive)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &stdioPool{
+		client:   client,
+		size:     size,
+		max:      maxAlive,
+		refillCh: make(chan struct{}, 1),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// get hands an idle instance to the session using the given
+// context, or starts a new one if there is none available.
+func (p *stdioPool) get(ctx context.Context, cfg cfg) (*MCPStream, error) {
+
+	if len(cfg.env) > 0 {
+		return nil, ErrPoolEnv
+	}
+
+	p.Lock()
+	var srv *pooledServer
+	if len(p.idle) > 0 {
+		srv, p.idle = p.idle[0], p.idle[1:]
+	}
+	p.Unlock()
+
+	if srv != nil {
+		stream := NewMCPStream(ctx)
+		srv.handoff <- pooledSession{ctx: ctx, stream: stream}
+		p.refill()
+		return stream, nil
+	}
+
+	if !p.acquire() {
+		return nil, ErrPoolExhausted
+	}
+
+	stream, err := p.client.start(ctx, cfg)
+	if err != nil {
+		p.release()
+		return nil, err
+	}
+
+	return stream, nil
+}
+
+// run keeps the pool filled until it is closed.
+func (p *stdioPool) run() {
+
+	ticker := time.NewTicker(stdioPoolRefillInterval)
+	defer ticker.Stop()
+
+	for {
+
+		p.fill()
+
+		select {
+		case <-p.refillCh:
+		case <-ticker.C:
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+// close stops filling the pool and stops the instances it started.
+func (p *stdioPool) close() {
+	p.cancel()
+}
+
+// fill starts instances until there are enough idle ones,
+// or until the maximum number of instances alive is reached.
+func (p *stdioPool) fill() {
+
+	for p.ctx.Err() == nil {
+
+		p.Lock()
+		need := len(p.idle) < p.size && (p.max <= 0 || p.alive < p.max)
+		if need {
+			p.alive++
+		}
+		p.Unlock()
+
+		if !need {
+			return
+		}
+
+		ctx, cancel := context.WithCancel(p.ctx)
+
+		stream, err := p.client.start(ctx, cfg{})
+		if err != nil {
+			cancel()
+			p.release()
+			slog.Error("Unable to start pooled MCP server", "server", p.client.Server(), "err", err)
+			return
+		}
+
+		srv := &pooledServer{
+			inner:   watchStream(stream),
+			cancel:  cancel,
+			handoff: make(chan pooledSession, 1),
+		}
+
+		// The instance is released once it exits.
+		if err := srv.warm(ctx); err != nil {
+			cancel()
+			srv.inner.unregister()
+			if p.ctx.Err() != nil {
+				return
+			}
+			slog.Error("Unable to initialize pooled MCP server", "server", p.client.Server(), "err", err)
+			return
+		}
+
+		p.Lock()
+		p.idle = append(p.idle, srv)
+		p.Unlock()
+
+		go p.serve(srv)
+	}
+}
+
+// warm performs the initialize handshake with the instance
+// and keeps its result for the session it is handed to.
+func (srv *pooledServer) warm(ctx context.Context) error {
+
+	init := mcp.NewInitMessage(mcp.ProtocolVersion20250326)
+
+	initData, err := elemental.Encode(elemental.EncodingTypeJSON, init)
+	if err != nil {
+		return err
+	}
+
+	initializedData, err := elemental.Encode(elemental.EncodingTypeJSON, mcp.NewNotification("notifications/initialized"))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, stdioPoolWarmTimeout)
+	defer cancel()
+
+	resp, err := handshake(ctx, srv.inner, initData, init.ID, initializedData)
+	if err != nil {
+		return err
+	}
+
+	srv.init = resp.Result
+	srv.version, _ = resp.Result["protocolVersion"].(string)
+
+	return nil
+}
+
+// serve owns the channels registered on the instance for its whole
+// life, so its exit is never missed. While idle, the instance is
+// removed from the pool if it exits. Once handed to a session, it
+// forwards the messages between the session and the instance until
+// the instance exits. It is stopped when the session ends. If the agent
+// requests another protocol version than the one the instance has been
+// initialized with, the instance is replaced by a new one the agent
+// initializes itself.
+func (p *stdioPool) serve(srv *pooledServer) {
+
+	defer srv.inner.unregister()
+
+	var s pooledSession
+
+	for s.stream == nil {
+
+		select {
+
+		case s = <-srv.handoff:
+
+		case <-srv.inner.out:
+		case <-srv.inner.errs:
+
+		case err := <-srv.inner.exit:
+
+			if p.remove(srv) {
+				srv.cancel()
+				slog.Warn("Pooled MCP server exited while idle", "server", p.client.Server(), "err", err)
+				return
+			}
+
+			// It exited while being handed out.
+			s = <-srv.handoff
+			sendExit(s.stream, err)
+			return
+		}
+	}
+
+	context.AfterFunc(s.ctx, srv.cancel)
+
+	inner := srv.inner
+	defer func() { inner.unregister() }()
+
+	answered, notified := false, false
+
+	for {
+
+		select {
+
+		case data := <-s.stream.stdin:
+
+			msg := mcp.Message{}
+			_ = elemental.Decode(elemental.EncodingTypeJSON, data, &msg)
+
+			switch {
+
+			case !answered && msg.Method == "initialize" && msg.ID != nil:
+
+				answered = true
+
+				if version, _ := msg.Params["protocolVersion"].(string); version != srv.version {
+
+					cold, err := p.coldStart(s)
+					if err != nil {
+						slog.Error("Unable to start MCP server replacing pooled one", "server", p.client.Server(), "version", version, "err", err)
+						sendExit(s.stream, err)
+						return
+					}
+
+					srv.cancel()
+					srv.inner.unregister()
+
+					inner, notified = cold, true
+					break
+				}
+
+				resp := mcp.NewMessage("")
+				resp.ID = msg.ID
+				resp.Result = srv.init
+
+				data, err := elemental.Encode(elemental.EncodingTypeJSON, resp)
+				if err != nil {
+					slog.Error("Unable to encode pooled initialize response", "err", err)
+					continue
+				}
+
+				select {
+				case s.stream.stdout <- data:
+				case <-s.ctx.Done():
+				}
+
+				continue
+
+			case !notified && msg.Method == "notifications/initialized":
+
+				notified = true
+				continue
+			}
+
+			select {
+			case inner.Stdin() <- data:
+			case err := <-inner.exit:
+				sendExit(s.stream, err)
+				return
+			}
+
+		case data := <-inner.out:
+
+			select {
+			case s.stream.stdout <- data:
+			case <-s.ctx.Done():
+			}
+
+		case data := <-inner.errs:
+
+			select {
+			case s.stream.stderr <- data:
+			case <-s.ctx.Done():
+			}
+
+		case err := <-inner.exit:
+			sendExit(s.stream, err)
+			return
+		}
+	}
+}
+
+// coldStart starts a new instance for the given session, replacing the
+// pooled one handed to it. It takes over the place of the replaced
+// instance, which is released once stopped, so it is not limited by
+// the maximum number of instances alive.
+func (p *stdioPool) coldStart(s pooledSession) (*watchedStream, error) {
+
+	p.Lock()
+	p.alive++
+	p.Unlock()
+
+	stream, err := p.client.start(s.ctx, cfg{})
+	if err != nil {
+		p.release()
+		return nil, err
+	}
+
+	return watchStream(stream), nil
+}
+
+// remove removes the given instance from the idle ones.
+// It returns false if it has already been handed out.
+func (p *stdioPool) remove(srv *pooledServer) bool {
+
+	p.Lock()
+	defer p.Unlock()
+
+	for i, s := range p.idle {
+		if s == srv {
+			p.idle = append(p.idle[:i], p.idle[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *stdioPool) acquire() bool {
+
+	p.Lock()
+	defer p.Unlock()
+
+	if p.max > 0 && p.alive >= p.max {
+		return false
+	}
+
+	p.alive++
+
+	return true
+}
+
+// release is called when an instance exits or fails to start.
+// The pool is not refilled right away, so a command that keeps
+// crashing is restarted at most every stdioPoolRefillInterval.
+func (p *stdioPool) release() {
+	p.Lock()
+	p.alive--
+	p.Unlock()
+}
+
+func (p *stdioPool) refill() {
+	select {
+	case p.refillCh <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,179 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func idleCount(p *stdioPool) int {
+	p.Lock()
+	defer p.Unlock()
+	return len(p.idle)
+}
+
+func waitIdle(p *stdioPool, n int) bool {
+	for range 50 {
+		if idleCount(p) == n {
+			return true
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return false
+}
+
+func TestPool(t *testing.T) {
+
+	Convey("Given I have a client with a pool", t, func() {
+
+		cl := NewStdio(MCPServer{Command: "cat"}, OptStdioPool(2, 0)).(*stdioClient)
+		So(waitIdle(cl.pool, 2), ShouldBeTrue)
+
+		Convey("When I start a session", func() {
+
+			ctx, cancel := context.WithCancel(t.Context())
+			defer cancel()
+
+			stream, err := cl.Start(ctx)
+			So(err, ShouldBeNil)
+
+			out, unregisterOut := stream.Stdout()
+			defer unregisterOut()
+
+			exit, unregisterExit := stream.Exit()
+			defer unregisterExit()
+
+			Convey("Then the server should work and the pool should be refilled", func() {
+
+				stream.Stdin() <- []byte("hello")
+				So(string(<-out), ShouldEqual, "hello")
+
+				So(waitIdle(cl.pool, 2), ShouldBeTrue)
+			})
+
+			Convey("Then the server should be stopped when the session ends", func() {
+
+				cancel()
+
+				select {
+				case err := <-exit:
+					So(err.Error(), ShouldEqual, "signal: terminated")
+				case <-time.After(3 * time.Second):
+					So("timeout waiting for exit", ShouldBeEmpty)
+				}
+			})
+		})
+
+		Convey("When I start a session with env", func() {
+
+			ctx, cancel := context.WithCancel(t.Context())
+			defer cancel()
+
+			_, err := cl.Start(ctx, OptionEnv("A=B"))
+
+			Convey("Then it should fail", func() {
+				So(err, ShouldEqual, ErrPoolEnv)
+				So(idleCount(cl.pool), ShouldEqual, 2)
+			})
+		})
+
+		Convey("When I close the client", func() {
+
+			So(cl.Close(), ShouldBeNil)
+
+			Convey("Then the idle servers should be stopped", func() {
+				So(waitIdle(cl.pool, 0), ShouldBeTrue)
+			})
+		})
+	})
+
+	Convey("Given I have a client with a pool of MCP servers", t, func() {
+
+		script := `while read -r l; do
+case "$l" in
+*'"2024-11-05"'*) echo '{"jsonrpc":"2.0","id":7,"result":{"protocolVersion":"2024-11-05","serverInfo":{"name":"cold"}}}' ;;
+*'"method":"initialize"'*) echo '{"jsonrpc":"2.0","id":0,"result":{"protocolVersion":"2025-03-26","serverInfo":{"name":"warm"}}}' ;;
+*'"method":"notifications/initialized"'*) echo '{"jsonrpc":"2.0","method":"notifications/message"}' ;;
+*) echo "$l" ;;
+esac
+done`
+
+		cl := NewStdio(MCPServer{Command: "sh", Args: []string{"-c", script}}, OptStdioPool(1, 0)).(*stdioClient)
+		defer func() { _ = cl.Close() }()
+
+		So(waitIdle(cl.pool, 1), ShouldBeTrue)
+
+		ctx, cancel := context.WithCancel(t.Context())
+		defer cancel()
+
+		stream, err := cl.Start(ctx)
+		So(err, ShouldBeNil)
+
+		out, unregisterOut := stream.Stdout()
+		defer unregisterOut()
+
+		Convey("Then the initialize request should be answered with the cached result", func() {
+
+			stream.Stdin() <- []byte(`{"jsonrpc":"2.0","id":7,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`)
+			So(string(<-out), ShouldEqual, `{"id":7,"jsonrpc":"2.0","result":{"protocolVersion":"2025-03-26","serverInfo":{"name":"warm"}}}`)
+
+			stream.Stdin() <- []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+			stream.Stdin() <- []byte(`{"jsonrpc":"2.0","id":8,"method":"ping"}`)
+			So(string(<-out), ShouldEqual, `{"jsonrpc":"2.0","id":8,"method":"ping"}`)
+		})
+
+		Convey("Then an initialize request for another version should start a new server", func() {
+
+			stream.Stdin() <- []byte(`{"jsonrpc":"2.0","id":7,"method":"initialize","params":{"protocolVersion":"2024-11-05"}}`)
+			So(string(<-out), ShouldEqual, `{"jsonrpc":"2.0","id":7,"result":{"protocolVersion":"2024-11-05","serverInfo":{"name":"cold"}}}`)
+
+			stream.Stdin() <- []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+			So(string(<-out), ShouldEqual, `{"jsonrpc":"2.0","method":"notifications/message"}`)
+
+			stream.Stdin() <- []byte(`{"jsonrpc":"2.0","id":8,"method":"ping"}`)
+			So(string(<-out), ShouldEqual, `{"jsonrpc":"2.0","id":8,"method":"ping"}`)
+		})
+	})
+
+	Convey("Given I have a client with a pool with a maximum of servers", t, func() {
+
+		cl := NewStdio(MCPServer{Command: "cat"}, OptStdioPool(1, 2)).(*stdioClient)
+		So(waitIdle(cl.pool, 1), ShouldBeTrue)
+
+		ctx1, cancel1 := context.WithCancel(t.Context())
+		defer cancel1()
+
+		ctx2, cancel2 := context.WithCancel(t.Context())
+		defer cancel2()
+
+		_, err := cl.Start(ctx1)
+		So(err, ShouldBeNil)
+
+		stream2, err := cl.Start(ctx2)
+		So(err, ShouldBeNil)
+
+		exit2, unregisterExit2 := stream2.Exit()
+		defer unregisterExit2()
+
+		Convey("Then starting another session should fail", func() {
+
+			_, err := cl.Start(t.Context())
+			So(err, ShouldEqual, ErrPoolExhausted)
+			So(idleCount(cl.pool), ShouldEqual, 0)
+
+			Convey("Then it should work once a session ended", func() {
+
+				cancel2()
+				<-exit2
+
+				ctx3, cancel3 := context.WithCancel(t.Context())
+				defer cancel3()
+
+				_, err := cl.Start(ctx3)
+				So(err, ShouldBeNil)
+			})
+		})
+	})
+}
@@ -0,0 +1,384 @@
+//go:build linux
+
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// sandboxEnv is the environment variable used to pass the
+// sandboxSpec to the re-executed minibridge binary.
+const sandboxEnv = "_MINIBRIDGE_SANDBOX_"
+
+// sandboxSpec describes the command to run in the sandbox.
+type sandboxSpec struct {
+	Path       string            `json:"path"`
+	Args       []string          `json:"args"`
+	Dir        string            `json:"dir"`
+	Namespaces bool              `json:"namespaces"`
+	Writable   bool              `json:"writable"`
+	Seccomp    []unix.SockFilter `json:"seccomp,omitempty"`
+	SeccompFD  int               `json:"seccompFD,omitempty"`
+	Landlock   *landlockSpec     `json:"landlock,omitempty"`
+}
+
+// When minibridge is re-executed as a sandbox shim, we
+// prepare the sandbox and run the MCP server instead
+// of running the actual program.
+func init() {
+
+	data, ok := os.LookupEnv(sandboxEnv)
+	if !ok {
+		return
+	}
+
+	spec := sandboxSpec{}
+	if err := json.Unmarshal([]byte(data), &spec); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "minibridge sandbox: unable to decode spec: %s\n", err)
+		os.Exit(126)
+	}
+
+	if err := runSandbox(spec); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "minibridge sandbox: %s\n", err)
+		os.Exit(126)
+	}
+}
+
+// setSandbox modifies the given command so it runs through the
+// sandbox shim. If cfg.sandbox is set, the command runs in new user,
+// mount, pid, ipc and uts namespaces. If cfg.landlock is set, the shim
+// restricts the filesystem access of the command. If cfg.seccomp is set,
+// the shim installs the seccomp filter and the returned seccompMonitor
+// must be started along with the command. It must be called after setCaps.
+func setSandbox(cmd *exec.Cmd, server string, cfg stdioCfg) (*seccompMonitor, error) {
+
+	if cmd.Err != nil {
+		return nil, nil
+	}
+
+	spec := sandboxSpec{
+		Path:       cmd.Path,
+		Args:       cmd.Args,
+		Dir:        cmd.Dir,
+		Namespaces: cfg.sandbox,
+		Writable:   cfg.sandbox && cfg.useTempDir,
+	}
+
+	if cfg.landlock != nil {
+
+		abi, err := landlockABI()
+		if err != nil {
+			return nil, err
+		}
+
+		spec.Landlock = &landlockSpec{
+			ABI:       abi,
+			ReadOnly:  cfg.landlock.readOnly,
+			ReadWrite: append([]string{cmd.Dir}, cfg.landlock.readWrite...),
+		}
+	}
+
+	var monitor *seccompMonitor
+
+	if cfg.seccomp != nil {
+
+		filter, err := compileSeccompFilter(*cfg.seccomp)
+		if err != nil {
+			return nil, fmt.Errorf("unable to compile seccomp profile: %w", err)
+		}
+
+		if monitor, err = newSeccompMonitor(*cfg.seccomp, server, cfg.metricsManager); err != nil {
+			return nil, err
+		}
+
+		cmd.ExtraFiles = append(cmd.ExtraFiles, monitor.remote)
+
+		spec.Seccomp = filter
+		spec.SeccompFD = 2 + len(cmd.ExtraFiles)
+	}
+
+	data, err := json.Marshal(spec)
+	if err != nil {
+		if monitor != nil {
+			monitor.close()
+		}
+		return nil, fmt.Errorf("unable to encode sandbox spec: %w", err)
+	}
+
+	cmd.Args = []string{filepath.Base(cmd.Path)}
+	cmd.Path = "/proc/self/exe"
+	cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", sandboxEnv, data))
+
+	if !cfg.sandbox {
+		return monitor, nil
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWUSER |
+		syscall.CLONE_NEWNS |
+		syscall.CLONE_NEWPID |
+		syscall.CLONE_NEWIPC |
+		syscall.CLONE_NEWUTS
+
+	cmd.SysProcAttr.UidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}}
+	cmd.SysProcAttr.GidMappings = []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}}
+
+	return monitor, nil
+}
+
+// runSandbox prepares the sandbox, then runs the command.
+// It only returns in case of error.
+func runSandbox(spec sandboxSpec) error {
+
+	if spec.Namespaces {
+		if err := enterSandboxRoot(spec); err != nil {
+			return err
+		}
+	}
+
+	// Landlock and seccomp only apply to the current thread
+	// and the processes it starts, so we must stay on this thread.
+	runtime.LockOSThread()
+
+	if spec.Landlock != nil {
+		if err := applyLandlock(*spec.Landlock); err != nil {
+			return err
+		}
+	}
+
+	if len(spec.Seccomp) > 0 {
+		if err := installSeccompFilter(spec.Seccomp, spec.SeccompFD); err != nil {
+			return err
+		}
+	}
+
+	if !spec.Namespaces {
+		if err := syscall.Exec(spec.Path, spec.Args, sandboxEnviron()); err != nil {
+			return fmt.Errorf("unable to exec command: %w", err)
+		}
+	}
+
+	return runSandboxInit(spec)
+}
+
+// enterSandboxRoot prepares the filesystem of the sandbox
+// and makes it the root of the current mount namespace.
+func enterSandboxRoot(spec sandboxSpec) error {
+
+	if err := prepareSandboxRoot(spec, "/tmp/root"); err != nil {
+		return err
+	}
+
+	if err := unix.Chdir("/tmp/root"); err != nil {
+		return fmt.Errorf("unable to chdir to new root: %w", err)
+	}
+
+	if err := unix.PivotRoot(".", "."); err != nil {
+		return fmt.Errorf("unable to pivot root: %w", err)
+	}
+
+	if err := unix.Unmount(".", unix.MNT_DETACH); err != nil {
+		return fmt.Errorf("unable to detach old root: %w", err)
+	}
+
+	if err := unix.Sethostname([]byte("minibridge")); err != nil {
+		return fmt.Errorf("unable to set hostname: %w", err)
+	}
+
+	if err := unix.Chdir(spec.Dir); err != nil {
+		return fmt.Errorf("unable to chdir to working dir: %w", err)
+	}
+
+	return dropCapabilities()
+}
+
+// prepareSandboxRoot prepares the new root filesystem in the given
+// dir: a recursive, read-only bind mount of the original root, with
+// a new /proc, a private /tmp and optionally a writable working dir.
+func prepareSandboxRoot(spec sandboxSpec, root string) error {
+
+	// We keep a handle on the working dir as it
+	// may be hidden by the tmpfs we mount later.
+	wfd, err := unix.Open(spec.Dir, unix.O_PATH|unix.O_CLOEXEC|unix.O_DIRECTORY, 0)
+	if err != nil {
+		return fmt.Errorf("unable to open working dir: %w", err)
+	}
+	defer func() { _ = unix.Close(wfd) }()
+
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("unable to make mounts private: %w", err)
+	}
+
+	// We use a tmpfs as a staging area to bind the original root.
+	if err := unix.Mount("tmpfs", filepath.Dir(root), "tmpfs", 0, ""); err != nil {
+		return fmt.Errorf("unable to mount staging tmpfs: %w", err)
+	}
+
+	if err := os.Mkdir(root, 0700); err != nil {
+		return fmt.Errorf("unable to create staging root: %w", err)
+	}
+
+	if err := unix.Mount("/", root, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+		return fmt.Errorf("unable to bind mount root: %w", err)
+	}
+
+	if err := makeReadOnly(root); err != nil {
+		return err
+	}
+
+	if err := unix.Mount("proc", filepath.Join(root, "proc"), "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, ""); err != nil {
+		// This happens when running in containers masking parts of /proc.
+		// In that case, we keep the read-only view of the original /proc.
+		_, _ = fmt.Fprintf(os.Stderr, "minibridge sandbox: unable to mount /proc, keeping the host one: %s\n", err)
+	}
+
+	for _, p := range []string{"/tmp", "/dev/shm"} {
+
+		p = filepath.Join(root, p)
+		if _, err := os.Stat(p); err != nil {
+			continue
+		}
+
+		if err := unix.Mount("tmpfs", p, "tmpfs", unix.MS_NOSUID|unix.MS_NODEV, "mode=1777"); err != nil {
+			return fmt.Errorf("unable to mount tmpfs on %s: %w", p, err)
+		}
+	}
+
+	if !spec.Writable {
+		return nil
+	}
+
+	target := filepath.Join(root, spec.Dir)
+
+	if err := os.MkdirAll(target, 0700); err != nil {
+		return fmt.Errorf("unable to create working dir: %w", err)
+	}
+
+	if err := unix.Mount(fmt.Sprintf("/proc/self/fd/%d", wfd), target, "", unix.MS_BIND, ""); err != nil {
+		return fmt.Errorf("unable to bind mount working dir: %w", err)
+	}
+
+	return nil
+}
+
+// makeReadOnly makes the given mount and all its submounts read-only.
+func makeReadOnly(path string) error {
+
+	err := unix.MountSetattr(unix.AT_FDCWD, path, unix.AT_RECURSIVE, &unix.MountAttr{Attr_set: unix.MOUNT_ATTR_RDONLY})
+	if err == nil {
+		return nil
+	}
+
+	if !errors.Is(err, unix.ENOSYS) {
+		return fmt.Errorf("unable to make root read-only: %w", err)
+	}
+
+	// Older kernels cannot change mount attributes recursively,
+	// so we only remount the root, keeping the locked flags.
+	st := unix.Statfs_t{}
+	if err := unix.Statfs(path, &st); err != nil {
+		return fmt.Errorf("unable to stat root: %w", err)
+	}
+
+	flags := uintptr(st.Flags) & (unix.MS_NOSUID | unix.MS_NODEV | unix.MS_NOEXEC | unix.MS_NOATIME | unix.MS_NODIRATIME | unix.MS_RELATIME) // #nosec: G115
+	if err := unix.Mount("", path, "", unix.MS_BIND|unix.MS_REMOUNT|unix.MS_RDONLY|flags, ""); err != nil {
+		return fmt.Errorf("unable to remount root read-only: %w", err)
+	}
+
+	return nil
+}
+
+// dropCapabilities empties the bounding set so the command
+// does not get any capability in the sandbox namespaces.
+func dropCapabilities() error {
+
+	for c := 0; ; c++ {
+		if err := unix.Prctl(unix.PR_CAPBSET_DROP, uintptr(c), 0, 0, 0); err != nil {
+			if errors.Is(err, unix.EINVAL) {
+				break
+			}
+			return fmt.Errorf("unable to drop capability %d: %w", c, err)
+		}
+	}
+
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("unable to set no new privs: %w", err)
+	}
+
+	return nil
+}
+
+// runSandboxInit starts the command and acts as the init process of
+// the pid namespace: it forwards signals and reaps orphans. It exits
+// with the same code as the command.
+func runSandboxInit(spec sandboxSpec) error {
+
+	sigs := make(chan os.Signal, 8)
+	signal.Notify(sigs, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP, syscall.SIGQUIT)
+
+	pid, err := syscall.ForkExec(spec.Path, spec.Args, &syscall.ProcAttr{
+		Dir:   spec.Dir,
+		Env:   sandboxEnviron(),
+		Files: []uintptr{0, 1, 2},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to start command: %w", err)
+	}
+
+	go func() {
+		for sig := range sigs {
+			_ = syscall.Kill(pid, sig.(syscall.Signal))
+		}
+	}()
+
+	for {
+
+		var ws syscall.WaitStatus
+
+		wpid, err := syscall.Wait4(-1, &ws, 0, nil)
+		if err != nil {
+			if errors.Is(err, syscall.EINTR) {
+				continue
+			}
+			return fmt.Errorf("unable to wait for command: %w", err)
+		}
+
+		if wpid != pid {
+			continue
+		}
+
+		if ws.Signaled() {
+			os.Exit(128 + int(ws.Signal()))
+		}
+
+		os.Exit(ws.ExitStatus())
+	}
+}
+
+// sandboxEnviron returns the environment
+// without the sandbox spec.
+func sandboxEnviron() []string {
+
+	env := make([]string, 0, len(os.Environ()))
+	for _, e := range os.Environ() {
+		if !strings.HasPrefix(e, sandboxEnv+"=") {
+			env = append(env, e)
+		}
+	}
+
+	return env
+}
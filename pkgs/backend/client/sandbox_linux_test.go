@@ -0,0 +1,95 @@
+//go:build linux
+
+package client
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func runSandboxed(t *testing.T, script string, opts ...StdioOption) ([]string, error) {
+	return runScript(t, script, append(opts, OptStdioSandbox(true))...)
+}
+
+func runScript(t *testing.T, script string, opts ...StdioOption) ([]string, error) {
+
+	cl := NewStdio(MCPServer{Command: "sh", Args: []string{"-c", script}}, opts...)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+
+	stream, err := cl.Start(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out, unregisterOut := stream.Stdout()
+	defer unregisterOut()
+
+	exit, unregisterExit := stream.Exit()
+	defer unregisterExit()
+
+	var lines []string
+	for {
+		select {
+		case data := <-out:
+			lines = append(lines, strings.TrimSpace(string(data)))
+		case err := <-exit:
+			// The last lines can be broadcast after the exit.
+			for {
+				select {
+				case data := <-out:
+					lines = append(lines, strings.TrimSpace(string(data)))
+				case <-time.After(100 * time.Millisecond):
+					return lines, err
+				}
+			}
+		}
+	}
+}
+
+func TestSandbox(t *testing.T) {
+
+	if _, err := runSandboxed(t, "true"); err != nil {
+		t.Skipf("sandbox is not supported in this environment: %s", err)
+	}
+
+	Convey("Given I run a command in the sandbox", t, func() {
+
+		lines, err := runSandboxed(t, `hostname; echo $PPID; touch /usr/minibridge-test 2>/dev/null || echo ro; touch /tmp/test && echo tmp`)
+		So(err, ShouldBeNil)
+		So(lines, ShouldResemble, []string{"minibridge", "1", "ro", "tmp"})
+	})
+
+	Convey("Given I run a command in the sandbox without a temp dir", t, func() {
+
+		lines, err := runSandboxed(t, `touch testfile 2>/dev/null || echo ro`)
+		So(err, ShouldBeNil)
+		So(lines, ShouldResemble, []string{"ro"})
+	})
+
+	Convey("Given I run a command in the sandbox with a temp dir", t, func() {
+
+		lines, err := runSandboxed(t, `touch testfile && echo ok`, OptStdioUseTempDir(true))
+		So(err, ShouldBeNil)
+		So(lines, ShouldResemble, []string{"ok"})
+	})
+
+	Convey("Given I run a command that exits with an error in the sandbox", t, func() {
+
+		_, err := runSandboxed(t, `exit 3`)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "exit status 3")
+	})
+
+	Convey("Given I run a command in the sandbox with credentials", t, func() {
+
+		_, err := runSandboxed(t, `true`, OptStdioCredentials(1000, 1000, nil))
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "unable to use credentials with the sandbox")
+	})
+}
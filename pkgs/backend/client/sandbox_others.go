@@ -0,0 +1,12 @@
+//go:build !linux
+
+package client
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+func setSandbox(*exec.Cmd, string, stdioCfg) (*seccompMonitor, error) {
+	return nil, fmt.Errorf("sandbox, seccomp and landlock are only supported on linux")
+}
@@ -0,0 +1,187 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+)
+
+// SeccompProfile describes the syscalls an MCP server is not allowed to
+// use. Blocked syscalls fail with EPERM and are reported by minibridge.
+type SeccompProfile struct {
+
+	// Name is the name of the profile, used in logs and metrics.
+	Name string `json:"name"`
+
+	// Extends is the optional name of a built-in profile
+	// this profile adds its restrictions to.
+	Extends string `json:"extends,omitempty"`
+
+	// Deny is the list of syscall names to block.
+	Deny []string `json:"deny,omitempty"`
+
+	// DenyNetwork blocks the creation of any non unix socket.
+	DenyNetwork bool `json:"denyNetwork,omitempty"`
+
+	// DenyExecAfterStart blocks execve and execveat once
+	// the MCP server command has been executed.
+	DenyExecAfterStart bool `json:"denyExecAfterStart,omitempty"`
+
+	// DenyUserNamespaces blocks clone with CLONE_NEWUSER. As the
+	// flags of clone3 cannot be inspected, clone3 fails with ENOSYS
+	// so the libc falls back to clone.
+	DenyUserNamespaces bool `json:"denyUserNamespaces,omitempty"`
+}
+
+// seccompDangerousSyscalls are the syscalls denied by the default profile,
+// and so by all the built-in profiles. They are not needed by MCP servers
+// and are commonly used to escape containers or to tamper with the host.
+// io_uring is denied as its operations are not seen by seccomp.
+var seccompDangerousSyscalls = []string{
+	"acct",
+	"add_key",
+	"adjtimex",
+	"bpf",
+	"chroot",
+	"clock_adjtime",
+	"clock_settime",
+	"delete_module",
+	"fanotify_init",
+	"finit_module",
+	"fsconfig",
+	"fsmount",
+	"fsopen",
+	"fspick",
+	"init_module",
+	"io_uring_enter",
+	"io_uring_register",
+	"io_uring_setup",
+	"kexec_file_load",
+	"kexec_load",
+	"keyctl",
+	"mount",
+	"mount_setattr",
+	"move_mount",
+	"name_to_handle_at",
+	"open_by_handle_at",
+	"open_tree",
+	"perf_event_open",
+	"pivot_root",
+	"process_vm_readv",
+	"process_vm_writev",
+	"ptrace",
+	"quotactl",
+	"reboot",
+	"request_key",
+	"setns",
+	"settimeofday",
+	"swapoff",
+	"swapon",
+	"syslog",
+	"umount2",
+	"unshare",
+	"userfaultfd",
+}
+
+var builtinSeccompProfiles = map[string]SeccompProfile{
+	"default": {
+		Name:               "default",
+		Deny:               seccompDangerousSyscalls,
+		DenyUserNamespaces: true,
+	},
+	"no-network": {
+		Name:        "no-network",
+		Extends:     "default",
+		DenyNetwork: true,
+	},
+	"no-exec-after-start": {
+		Name:               "no-exec-after-start",
+		Extends:            "default",
+		DenyExecAfterStart: true,
+	},
+}
+
+// SeccompProfiles returns the names of the built-in seccomp profiles.
+func SeccompProfiles() []string {
+
+	names := make([]string, 0, len(builtinSeccompProfiles))
+	for n := range builtinSeccompProfiles {
+		names = append(names, n)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// GetSeccompProfile returns the built-in profile with the given name.
+func GetSeccompProfile(name string) (SeccompProfile, error) {
+
+	p, ok := builtinSeccompProfiles[name]
+	if !ok {
+		return SeccompProfile{}, fmt.Errorf("unknown seccomp profile '%s'", name)
+	}
+
+	return p.resolve()
+}
+
+// LoadSeccompProfile loads a seccomp profile from the JSON file at the
+// given path. If the profile has no name, the path is used instead.
+func LoadSeccompProfile(path string) (SeccompProfile, error) {
+
+	data, err := os.ReadFile(path) // #nosec: G304
+	if err != nil {
+		return SeccompProfile{}, fmt.Errorf("unable to read seccomp profile: %w", err)
+	}
+
+	p := SeccompProfile{}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return SeccompProfile{}, fmt.Errorf("unable to decode seccomp profile: %w", err)
+	}
+
+	if p.Name == "" {
+		p.Name = path
+	}
+
+	return p.resolve()
+}
+
+// resolve merges the profile with the
+// built-in profile it extends, if any.
+func (p SeccompProfile) resolve() (SeccompProfile, error) {
+
+	out := SeccompProfile{
+		Name:               p.Name,
+		Deny:               slices.Clone(p.Deny),
+		DenyNetwork:        p.DenyNetwork,
+		DenyExecAfterStart: p.DenyExecAfterStart,
+		DenyUserNamespaces: p.DenyUserNamespaces,
+	}
+
+	if p.Extends != "" {
+
+		base, ok := builtinSeccompProfiles[p.Extends]
+		if !ok {
+			return SeccompProfile{}, fmt.Errorf("seccomp profile '%s' extends unknown profile '%s'", p.Name, p.Extends)
+		}
+
+		if base.Extends != "" {
+			var err error
+			if base, err = base.resolve(); err != nil {
+				return SeccompProfile{}, err
+			}
+		}
+
+		out.Deny = append(out.Deny, base.Deny...)
+		out.DenyNetwork = out.DenyNetwork || base.DenyNetwork
+		out.DenyExecAfterStart = out.DenyExecAfterStart || base.DenyExecAfterStart
+		out.DenyUserNamespaces = out.DenyUserNamespaces || base.DenyUserNamespaces
+	}
+
+	sort.Strings(out.Deny)
+	out.Deny = slices.Compact(out.Deny)
+
+	return out, nil
+}
@@ -0,0 +1,341 @@
+//go:build linux
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"unsafe"
+
+	"go.acuvity.ai/minibridge/pkgs/metrics"
+	"golang.org/x/sys/unix"
+)
+
+// Offsets in struct seccomp_data.
+const (
+	seccompDataNr   = 0
+	seccompDataArch = 4
+	seccompDataArg0 = 16
+)
+
+// seccompData mirrors struct seccomp_data.
+type seccompData struct {
+	Nr   int32
+	Arch uint32
+	IP   uint64
+	Args [6]uint64
+}
+
+// seccompNotif mirrors struct seccomp_notif.
+type seccompNotif struct {
+	ID    uint64
+	Pid   uint32
+	Flags uint32
+	Data  seccompData
+}
+
+// seccompNotifResp mirrors struct seccomp_notif_resp.
+type seccompNotifResp struct {
+	ID    uint64
+	Val   int64
+	Error int32
+	Flags uint32
+}
+
+// compileSeccompFilter builds the BPF program for the given profile.
+// Syscalls to block are sent to the seccompMonitor through a user
+// notification, so they can be reported before being denied.
+func compileSeccompFilter(p SeccompProfile) ([]unix.SockFilter, error) {
+
+	if seccompAuditArch == 0 {
+		return nil, fmt.Errorf("seccomp is not supported on this architecture")
+	}
+
+	stmt := func(code uint16, k uint32) unix.SockFilter {
+		return unix.SockFilter{Code: code, K: k}
+	}
+
+	jump := func(code uint16, k uint32, jt uint8, jf uint8) unix.SockFilter {
+		return unix.SockFilter{Code: code, Jt: jt, Jf: jf, K: k}
+	}
+
+	filter := []unix.SockFilter{
+		stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, seccompDataArch),
+		jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, seccompAuditArch, 1, 0),
+		stmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_KILL_PROCESS),
+		stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, seccompDataNr),
+	}
+
+	// The x32 ABI uses the same audit arch as x86_64, with
+	// a flag in the syscall number. We never allow it.
+	if seccompAuditArch == unix.AUDIT_ARCH_X86_64 {
+		filter = append(filter,
+			jump(unix.BPF_JMP|unix.BPF_JSET|unix.BPF_K, 0x40000000, 0, 1),
+			stmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_ERRNO|uint32(unix.ENOSYS)),
+		)
+	}
+
+	notify := func(name string) error {
+
+		nr, ok := seccompSyscalls[name]
+		if !ok {
+			return fmt.Errorf("unknown syscall '%s' in seccomp profile '%s'", name, p.Name)
+		}
+
+		filter = append(filter,
+			jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, uint32(nr), 0, 1), // #nosec: G115
+			stmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_USER_NOTIF),
+		)
+
+		return nil
+	}
+
+	for _, name := range p.Deny {
+
+		// The sandbox shim needs it to send the notification fd.
+		if name == "sendmsg" {
+			return nil, fmt.Errorf("seccomp profile '%s' cannot deny sendmsg", p.Name)
+		}
+
+		if err := notify(name); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.DenyExecAfterStart {
+		for _, name := range []string{"execve", "execveat"} {
+			if err := notify(name); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if p.DenyUserNamespaces {
+		filter = append(filter,
+			jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, uint32(seccompSyscalls["clone3"]), 0, 1), // #nosec: G115
+			stmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_ERRNO|uint32(unix.ENOSYS)),
+			jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, uint32(seccompSyscalls["clone"]), 0, 4), // #nosec: G115
+			stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, seccompDataArg0),
+			jump(unix.BPF_JMP|unix.BPF_JSET|unix.BPF_K, unix.CLONE_NEWUSER, 0, 1),
+			stmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_USER_NOTIF),
+			stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, seccompDataNr),
+		)
+	}
+
+	if p.DenyNetwork {
+		filter = append(filter,
+			jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, uint32(seccompSyscalls["socket"]), 0, 4), // #nosec: G115
+			stmt(unix.BPF_LD|unix.BPF_W|unix.BPF_ABS, seccompDataArg0),
+			jump(unix.BPF_JMP|unix.BPF_JEQ|unix.BPF_K, unix.AF_UNIX, 0, 1),
+			stmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_ALLOW),
+			stmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_USER_NOTIF),
+		)
+	}
+
+	filter = append(filter, stmt(unix.BPF_RET|unix.BPF_K, unix.SECCOMP_RET_ALLOW))
+
+	if len(filter) > unix.BPF_MAXINSNS {
+		return nil, fmt.Errorf("seccomp profile '%s' is too large", p.Name)
+	}
+
+	return filter, nil
+}
+
+// installSeccompFilter installs the given filter on the calling thread
+// and sends the resulting notification fd through the unix socket sock.
+// The caller must have locked its OS thread.
+func installSeccompFilter(filter []unix.SockFilter, sock int) error {
+
+	defer func() { _ = unix.Close(sock) }()
+
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("unable to set no new privs: %w", err)
+	}
+
+	prog := unix.SockFprog{
+		Len:    uint16(len(filter)), // #nosec: G115
+		Filter: &filter[0],
+	}
+
+	fd, _, errno := unix.Syscall(
+		unix.SYS_SECCOMP,
+		unix.SECCOMP_SET_MODE_FILTER,
+		unix.SECCOMP_FILTER_FLAG_NEW_LISTENER,
+		uintptr(unsafe.Pointer(&prog)), // #nosec: G103
+	)
+	if errno != 0 {
+		return fmt.Errorf("unable to install seccomp filter: %w", errno)
+	}
+
+	defer func() { _ = unix.Close(int(fd)) }()
+
+	if err := unix.Sendmsg(sock, []byte{0}, unix.UnixRights(int(fd)), nil, 0); err != nil {
+		return fmt.Errorf("unable to send seccomp notification fd: %w", err)
+	}
+
+	return nil
+}
+
+// seccompMonitor receives the notifications of the seccomp filter
+// installed in the MCP server. It logs and denies the blocked syscalls,
+// except for the initial execution of the MCP server command.
+type seccompMonitor struct {
+	profile SeccompProfile
+	server  string
+	metrics *metrics.Manager
+	names   map[int32]string
+
+	local  *os.File
+	remote *os.File
+}
+
+func newSeccompMonitor(profile SeccompProfile, server string, mm *metrics.Manager) (*seccompMonitor, error) {
+
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_SEQPACKET|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create seccomp socket pair: %w", err)
+	}
+
+	names := make(map[int32]string, len(seccompSyscalls))
+	for name, nr := range seccompSyscalls {
+		names[int32(nr)] = name // #nosec: G115
+	}
+
+	return &seccompMonitor{
+		profile: profile,
+		server:  server,
+		metrics: mm,
+		names:   names,
+		local:   os.NewFile(uintptr(fds[0]), "seccomp-local"),
+		remote:  os.NewFile(uintptr(fds[1]), "seccomp-remote"),
+	}, nil
+}
+
+// close releases the sockets. It must be
+// called if the command could not be started.
+func (m *seccompMonitor) close() {
+	_ = m.local.Close()
+	_ = m.remote.Close()
+}
+
+// run receives the notification fd from the sandbox shim, then
+// handles the notifications until the MCP server exits.
+// It must be called once the command has been started.
+func (m *seccompMonitor) run(ctx context.Context) {
+
+	_ = m.remote.Close()
+	defer func() { _ = m.local.Close() }()
+
+	fd, err := m.receive()
+	if err != nil {
+		slog.Error("Unable to receive seccomp notification fd", "server", m.server, "err", err)
+		return
+	}
+	defer func() { _ = unix.Close(fd) }()
+
+	started := false
+	pfds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}} // #nosec: G115
+
+	for ctx.Err() == nil {
+
+		n, err := unix.Poll(pfds, 500)
+		if err != nil {
+			if errors.Is(err, unix.EINTR) {
+				continue
+			}
+			slog.Error("Unable to poll seccomp notifications", "server", m.server, "err", err)
+			return
+		}
+
+		if n == 0 {
+			continue
+		}
+
+		if pfds[0].Revents&unix.POLLIN == 0 {
+			// POLLHUP: all the processes using the filter have exited.
+			return
+		}
+
+		notif := seccompNotif{}
+		if err := seccompIoctl(fd, unix.SECCOMP_IOCTL_NOTIF_RECV, unsafe.Pointer(&notif)); err != nil { // #nosec: G103
+			if errors.Is(err, unix.EINTR) || errors.Is(err, unix.ENOENT) {
+				continue
+			}
+			slog.Error("Unable to receive seccomp notification", "server", m.server, "err", err)
+			return
+		}
+
+		name, ok := m.names[notif.Data.Nr]
+		if !ok {
+			name = fmt.Sprintf("%d", notif.Data.Nr)
+		}
+
+		resp := seccompNotifResp{ID: notif.ID}
+
+		if !started && (name == "execve" || name == "execveat") {
+			started = true
+			resp.Flags = unix.SECCOMP_USER_NOTIF_FLAG_CONTINUE
+		} else {
+			resp.Error = -int32(unix.EPERM)
+			m.report(name, notif.Pid)
+		}
+
+		if err := seccompIoctl(fd, unix.SECCOMP_IOCTL_NOTIF_SEND, unsafe.Pointer(&resp)); err != nil { // #nosec: G103
+			if errors.Is(err, unix.ENOENT) {
+				// The process has been interrupted or has exited.
+				continue
+			}
+			slog.Error("Unable to send seccomp notification response", "server", m.server, "err", err)
+			return
+		}
+	}
+}
+
+func (m *seccompMonitor) receive() (int, error) {
+
+	buf := make([]byte, 1)
+	oob := make([]byte, unix.CmsgSpace(4))
+
+	_, oobn, _, _, err := unix.Recvmsg(int(m.local.Fd()), buf, oob, unix.MSG_CMSG_CLOEXEC) // #nosec: G115
+	if err != nil {
+		return -1, fmt.Errorf("unable to read from seccomp socket: %w", err)
+	}
+
+	msgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(msgs) != 1 {
+		return -1, fmt.Errorf("sandbox shim exited before sending the seccomp notification fd")
+	}
+
+	fds, err := unix.ParseUnixRights(&msgs[0])
+	if err != nil || len(fds) != 1 {
+		return -1, fmt.Errorf("invalid seccomp notification fd message")
+	}
+
+	return fds[0], nil
+}
+
+func (m *seccompMonitor) report(name string, pid uint32) {
+
+	slog.Warn("Seccomp blocked syscall",
+		"server", m.server,
+		"profile", m.profile.Name,
+		"syscall", name,
+		"pid", pid,
+	)
+
+	if m.metrics != nil {
+		m.metrics.RecordSeccompBlock(m.profile.Name, name)
+	}
+}
+
+func seccompIoctl(fd int, req uint, arg unsafe.Pointer) error {
+
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), uintptr(req), uintptr(arg)); errno != 0 { // #nosec: G115
+		return errno
+	}
+
+	return nil
+}
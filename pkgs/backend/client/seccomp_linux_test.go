@@ -0,0 +1,73 @@
+//go:build linux && (amd64 || arm64)
+
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSeccomp(t *testing.T) {
+
+	deny, _ := GetSeccompProfile("default")
+	deny.Deny = []string{"mkdir", "mkdirat"}
+
+	if _, err := runScript(t, "true", OptStdioSeccomp(deny)); err != nil {
+		t.Skipf("seccomp is not supported in this environment: %s", err)
+	}
+
+	Convey("Given I run a command with a profile denying mkdir", t, func() {
+
+		dir := t.TempDir()
+
+		lines, err := runScript(t, `mkdir `+filepath.Join(dir, "a")+` 2>/dev/null || echo denied; echo ok`, OptStdioSeccomp(deny))
+		So(err, ShouldBeNil)
+		So(lines, ShouldResemble, []string{"denied", "ok"})
+
+		_, err = os.Stat(filepath.Join(dir, "a"))
+		So(os.IsNotExist(err), ShouldBeTrue)
+	})
+
+	Convey("Given I run a command with the no-exec-after-start profile", t, func() {
+
+		p, _ := GetSeccompProfile("no-exec-after-start")
+
+		lines, err := runScript(t, `echo start; /bin/true 2>/dev/null || echo denied`, OptStdioSeccomp(p))
+		So(err, ShouldBeNil)
+		So(lines, ShouldResemble, []string{"start", "denied"})
+	})
+
+	Convey("Given I run a command with the seccomp profile and the sandbox", t, func() {
+
+		if _, err := runSandboxed(t, "true"); err != nil {
+			SkipConvey("sandbox is not supported in this environment", func() {})
+			return
+		}
+
+		p, _ := GetSeccompProfile("no-exec-after-start")
+
+		lines, err := runSandboxed(t, `echo $PPID; /bin/true 2>/dev/null || echo denied`, OptStdioSeccomp(p))
+		So(err, ShouldBeNil)
+		So(lines, ShouldResemble, []string{"1", "denied"})
+	})
+
+	Convey("Given I run a command with a profile denying an unknown syscall", t, func() {
+
+		_, err := runScript(t, `true`, OptStdioSeccomp(SeccompProfile{Name: "bad", Deny: []string{"not_a_syscall"}}))
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "unable to configure sandbox: unable to compile seccomp profile: unknown syscall 'not_a_syscall' in seccomp profile 'bad'")
+	})
+
+	Convey("All built-in profiles should compile", t, func() {
+
+		for _, name := range SeccompProfiles() {
+			p, err := GetSeccompProfile(name)
+			So(err, ShouldBeNil)
+			_, err = compileSeccompFilter(p)
+			So(err, ShouldBeNil)
+		}
+	})
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package client
+
+import (
+	"context"
+)
+
+type seccompMonitor struct{}
+
+func (m *seccompMonitor) close()              {}
+func (m *seccompMonitor) run(context.Context) {}
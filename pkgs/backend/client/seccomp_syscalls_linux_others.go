@@ -0,0 +1,8 @@
+//go:build linux && !amd64 && !arm64
+
+package client
+
+// seccomp profiles are only supported on amd64 and arm64.
+const seccompAuditArch = 0
+
+var seccompSyscalls = map[string]uintptr{}
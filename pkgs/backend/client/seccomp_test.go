@@ -0,0 +1,79 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSeccompProfiles(t *testing.T) {
+
+	Convey("SeccompProfiles should return the built-in profiles", t, func() {
+		So(SeccompProfiles(), ShouldResemble, []string{"default", "no-exec-after-start", "no-network"})
+	})
+
+	Convey("GetSeccompProfile should resolve the extended profile", t, func() {
+
+		p, err := GetSeccompProfile("no-network")
+		So(err, ShouldBeNil)
+		So(p.Name, ShouldEqual, "no-network")
+		So(p.Extends, ShouldBeEmpty)
+		So(p.DenyNetwork, ShouldBeTrue)
+		So(p.Deny, ShouldContain, "ptrace")
+	})
+
+	Convey("All built-in profiles should deny io_uring and user namespaces", t, func() {
+
+		for _, name := range SeccompProfiles() {
+			p, err := GetSeccompProfile(name)
+			So(err, ShouldBeNil)
+			So(p.Deny, ShouldContain, "io_uring_setup")
+			So(p.Deny, ShouldContain, "io_uring_enter")
+			So(p.Deny, ShouldContain, "io_uring_register")
+			So(p.Deny, ShouldContain, "unshare")
+			So(p.DenyUserNamespaces, ShouldBeTrue)
+		}
+	})
+
+	Convey("GetSeccompProfile with an unknown name should fail", t, func() {
+
+		_, err := GetSeccompProfile("nope")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "unknown seccomp profile 'nope'")
+	})
+
+	Convey("LoadSeccompProfile should work", t, func() {
+
+		path := filepath.Join(t.TempDir(), "profile.json")
+		So(os.WriteFile(path, []byte(`{"extends":"no-exec-after-start","deny":["ptrace","chmod"]}`), 0600), ShouldBeNil)
+
+		p, err := LoadSeccompProfile(path)
+		So(err, ShouldBeNil)
+		So(p.Name, ShouldEqual, path)
+		So(p.DenyExecAfterStart, ShouldBeTrue)
+		So(p.Deny, ShouldContain, "chmod")
+		So(p.Deny, ShouldContain, "mount")
+		So(len(p.Deny), ShouldEqual, len(seccompDangerousSyscalls)+1)
+	})
+
+	Convey("LoadSeccompProfile extending an unknown profile should fail", t, func() {
+
+		path := filepath.Join(t.TempDir(), "profile.json")
+		So(os.WriteFile(path, []byte(`{"name":"custom","extends":"nope"}`), 0600), ShouldBeNil)
+
+		_, err := LoadSeccompProfile(path)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "seccomp profile 'custom' extends unknown profile 'nope'")
+	})
+
+	Convey("LoadSeccompProfile with an invalid file should fail", t, func() {
+
+		path := filepath.Join(t.TempDir(), "profile.json")
+		So(os.WriteFile(path, []byte(`{`), 0600), ShouldBeNil)
+
+		_, err := LoadSeccompProfile(path)
+		So(err, ShouldNotBeNil)
+	})
+}
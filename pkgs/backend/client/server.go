@@ -10,7 +10,12 @@ import (
 type MCPServer struct {
 	Command string
 	Args    []string
-	Env     []string
+
+	// Env contains KEY=VALUE variables passed to the command.
+	// If the client uses OptStdioResolveEnv, values can reference
+	// ${VAR}, ${file:/path} or ${keyring:service/user}, resolved
+	// when the command starts. Only the names are logged.
+	Env []string
 }
 
 // NewMCPServer returtns a new MCPServer. Returns an error is the given cmd path
@@ -12,15 +12,23 @@ import (
 	"os/exec"
 	"strings"
 	"syscall"
+	"time"
 
 	"go.acuvity.ai/minibridge/pkgs/internal/sanitize"
 )
 
+// stdioWaitDelay is the maximum time to wait for the output of the
+// command once it has exited, or for the command to exit once it has
+// been asked to terminate, before killing it.
+const stdioWaitDelay = 5 * time.Second
+
 var _ Client = (*stdioClient)(nil)
+var _ io.Closer = (*stdioClient)(nil)
 
 type stdioClient struct {
-	srv MCPServer
-	cfg stdioCfg
+	srv  MCPServer
+	cfg  stdioCfg
+	pool *stdioPool
 }
 
 // NewStdio returns a Client communicating through stdio.
@@ -31,10 +39,17 @@ func NewStdio(srv MCPServer, options ...StdioOption) Client {
 		o(&cfg)
 	}
 
-	return &stdioClient{
+	c := &stdioClient{
 		srv: srv,
 		cfg: cfg,
 	}
+
+	if cfg.poolSize > 0 {
+		c.pool = newStdioPool(c, cfg.poolSize, cfg.poolMax)
+		go c.pool.run()
+	}
+
+	return c
 }
 
 func (c *stdioClient) Type() string {
@@ -43,7 +58,43 @@ func (c *stdioClient) Type() string {
 
 func (c *stdioClient) Server() string { return c.srv.Command }
 
-func (c *stdioClient) Start(ctx context.Context, _ ...Option) (pipe *MCPStream, err error) {
+// Close stops the pool, if any, and its idle instances.
+// The instances handed to sessions are stopped as well.
+func (c *stdioClient) Close() error {
+
+	if c.pool != nil {
+		c.pool.close()
+	}
+
+	return nil
+}
+
+func (c *stdioClient) Start(ctx context.Context, opts ...Option) (pipe *MCPStream, err error) {
+
+	cfg := cfg{}
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	if c.pool != nil {
+		return c.pool.get(ctx, cfg)
+	}
+
+	return c.start(ctx, cfg)
+}
+
+func (c *stdioClient) start(ctx context.Context, cfg cfg) (pipe *MCPStream, err error) {
+
+	if c.cfg.sandbox && c.cfg.creds != nil {
+		return nil, fmt.Errorf("unable to use credentials with the sandbox")
+	}
+
+	env := c.srv.Env
+	if c.cfg.resolveEnv {
+		if env, err = resolveEnv(c.srv.Env); err != nil {
+			return nil, fmt.Errorf("unable to resolve mcp server env: %w", err)
+		}
+	}
 
 	dir, err := os.Getwd()
 	if err != nil {
@@ -57,8 +108,13 @@ func (c *stdioClient) Start(ctx context.Context, _ ...Option) (pipe *MCPStream,
 		}
 	}
 
+	baseEnv := os.Environ()
+	if c.cfg.cleanEnv {
+		baseEnv = filterEnv(baseEnv, c.cfg.envAllow)
+	}
+
 	cmd := exec.CommandContext(ctx, c.srv.Command, c.srv.Args...) // #nosec: G204
-	cmd.Env = append(os.Environ(), c.srv.Env...)
+	cmd.Env = append(append(baseEnv, env...), cfg.env...)
 	for i, e := range cmd.Env {
 		cmd.Env[i] = strings.ReplaceAll(e, "_MINIBRIDGE_PREFIX_", dir)
 	}
@@ -82,23 +138,55 @@ func (c *stdioClient) Start(ctx context.Context, _ ...Option) (pipe *MCPStream,
 		"path", cmd.Path,
 		"dir", cmd.Dir,
 		"creds", c.cfg.creds,
+		"env", envNames(c.srv.Env),
+		"clean-env", c.cfg.cleanEnv,
+		"sandbox", c.cfg.sandbox,
+		"seccomp", c.cfg.seccompName(),
+		"landlock", c.cfg.landlock != nil,
+		"limits", !c.cfg.limits.IsZero(),
 	)
 
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		return nil, fmt.Errorf("unable to create stdin pipe: %w", err)
+	var monitor *seccompMonitor
+	if c.cfg.sandbox || c.cfg.seccomp != nil || c.cfg.landlock != nil {
+		if monitor, err = setSandbox(cmd, c.srv.Command, c.cfg); err != nil {
+			return nil, fmt.Errorf("unable to configure sandbox: %w", err)
+		}
 	}
 
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return nil, fmt.Errorf("unable to create stdout pipe: %w", err)
+	var cg *cgroup
+	if !c.cfg.limits.IsZero() {
+		if cg, err = newCgroup(c.cfg.cgroupParent, c.cfg.limits, c.srv.Command, c.cfg.metricsManager); err != nil {
+			if monitor != nil {
+				monitor.close()
+			}
+			return nil, fmt.Errorf("unable to configure resource limits: %w", err)
+		}
+		cg.apply(cmd)
 	}
 
-	stderr, err := cmd.StderrPipe()
+	stdin, err := cmd.StdinPipe()
 	if err != nil {
-		return nil, fmt.Errorf("unable to create stderr pipe: %w", err)
+		if monitor != nil {
+			monitor.close()
+		}
+		if cg != nil {
+			cg.remove()
+		}
+		return nil, fmt.Errorf("unable to create stdin pipe: %w", err)
 	}
 
+	// We don't use cmd.StdoutPipe and cmd.StderrPipe as cmd.Wait closes
+	// them as soon as the command exits, possibly before we read its
+	// last messages. With io.Pipe, cmd.Wait returns once we have read
+	// everything, up to stdioWaitDelay.
+	stdout, stdoutW := io.Pipe()
+	cmd.Stdout = stdoutW
+
+	stderr, stderrW := io.Pipe()
+	cmd.Stderr = stderrW
+
+	cmd.WaitDelay = stdioWaitDelay
+
 	stream := NewMCPStream(ctx)
 
 	go c.readRequests(ctx, stdin, stream.stdin)
@@ -106,10 +194,35 @@ func (c *stdioClient) Start(ctx context.Context, _ ...Option) (pipe *MCPStream,
 	go c.readErrors(ctx, stderr, stream.stderr)
 
 	if err := cmd.Start(); err != nil {
+		_ = stdoutW.Close()
+		_ = stderrW.Close()
+		if monitor != nil {
+			monitor.close()
+		}
+		if cg != nil {
+			cg.remove()
+		}
 		return nil, fmt.Errorf("unable to start command: %w", err)
 	}
 
-	go func() { stream.exit <- cmd.Wait() }()
+	if monitor != nil {
+		go monitor.run(ctx)
+	}
+
+	exitReason := func(err error) error { return err }
+	if cg != nil {
+		exitReason = cg.monitor()
+	}
+
+	go func() {
+		err := exitReason(cmd.Wait())
+		_ = stdoutW.Close()
+		_ = stderrW.Close()
+		if c.pool != nil {
+			c.pool.release()
+		}
+		stream.exit <- err
+	}()
 
 	return stream, nil
 }
@@ -131,6 +244,8 @@ func (c *stdioClient) readRequests(ctx context.Context, stdin io.WriteCloser, ch
 
 func (c *stdioClient) readResponses(ctx context.Context, stdout io.ReadCloser, ch chan []byte) {
 
+	defer func() { _ = stdout.Close() }()
+
 	bstdout := bufio.NewReader(stdout)
 	for {
 		data, err := bstdout.ReadBytes('\n')
@@ -151,6 +266,8 @@ func (c *stdioClient) readResponses(ctx context.Context, stdout io.ReadCloser, c
 
 func (c *stdioClient) readErrors(ctx context.Context, stderr io.ReadCloser, ch chan []byte) {
 
+	defer func() { _ = stderr.Close() }()
+
 	bstderr := bufio.NewReader(stderr)
 	for {
 		data, err := bstderr.ReadBytes('\n')
@@ -69,6 +69,95 @@ func TestStdioClient(t *testing.T) {
 		So(err, ShouldBeNil)
 	})
 
+	Convey("Given I have a client with env and I start it with additional env", t, func() {
+
+		srv := MCPServer{
+			Command: "sh",
+			Args:    []string{"-c", "echo $MTEST-$MTEST2"},
+			Env:     []string{"MTEST=HELLO", "MTEST2=A"},
+		}
+		cl := NewStdio(srv)
+
+		ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+		defer cancel()
+
+		stream, err := cl.Start(ctx, OptionEnv("MTEST2=WORLD"))
+		So(err, ShouldBeNil)
+		So(stream, ShouldNotBeNil)
+
+		out, unregisterOut := stream.Stdout()
+		defer unregisterOut()
+
+		data := <-out
+		So(string(data), ShouldEqual, "HELLO-WORLD")
+	})
+
+	Convey("Given I have a client with a clean env and env references", t, func() {
+
+		t.Setenv("MINIBRIDGE_LEAK", "leak")
+
+		srv := MCPServer{
+			Command: "sh",
+			Args:    []string{"-c", "read _; echo ${MINIBRIDGE_LEAK:-none}-$MTEST"},
+			Env:     []string{"MTEST=${MINIBRIDGE_LEAK}"},
+		}
+		cl := NewStdio(srv, OptStdioCleanEnv("PATH"), OptStdioResolveEnv())
+
+		ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+		defer cancel()
+
+		stream, err := cl.Start(ctx)
+		So(err, ShouldBeNil)
+		So(stream, ShouldNotBeNil)
+
+		out, unregisterOut := stream.Stdout()
+		defer unregisterOut()
+
+		stream.Stdin() <- []byte("go")
+
+		data := <-out
+		So(string(data), ShouldEqual, "none-leak")
+	})
+
+	Convey("Given I have a client with env references it does not resolve", t, func() {
+
+		t.Setenv("MINIBRIDGE_LEAK", "leak")
+
+		srv := MCPServer{
+			Command: "sh",
+			Args:    []string{"-c", "echo \"$MTEST\""},
+			Env:     []string{"MTEST=${MINIBRIDGE_LEAK}"},
+		}
+		cl := NewStdio(srv)
+
+		ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+		defer cancel()
+
+		stream, err := cl.Start(ctx)
+		So(err, ShouldBeNil)
+		So(stream, ShouldNotBeNil)
+
+		out, unregisterOut := stream.Stdout()
+		defer unregisterOut()
+
+		data := <-out
+		So(string(data), ShouldEqual, "${MINIBRIDGE_LEAK}")
+	})
+
+	Convey("Given I have a client with an unresolvable env reference", t, func() {
+
+		srv := MCPServer{
+			Command: "sh",
+			Env:     []string{"MTEST=${file:/not/here}"},
+		}
+		cl := NewStdio(srv, OptStdioResolveEnv())
+
+		stream, err := cl.Start(t.Context())
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "unable to resolve mcp server env: unable to resolve env 'MTEST': unable to read secret file: open /not/here: no such file or directory")
+		So(stream, ShouldBeNil)
+	})
+
 	Convey("Given I have a client to which I give an invalid server", t, func() {
 
 		srv := MCPServer{
@@ -0,0 +1,586 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go.acuvity.ai/minibridge/pkgs/auth"
+	"go.acuvity.ai/minibridge/pkgs/internal/sanitize"
+)
+
+const (
+	// streamableProbeTimeout bounds the initial probe of the endpoint.
+	streamableProbeTimeout = 10 * time.Second
+
+	// streamableMaxPosts is the maximum number of
+	// messages posted concurrently in a session.
+	streamableMaxPosts = 16
+)
+
+var _ Client = (*streamableClient)(nil)
+var _ RemoteClient = (*streamableClient)(nil)
+
+type streamableClient struct {
+	u        *url.URL
+	endpoint string
+	client   *http.Client
+}
+
+// NewStreamableHTTP returns a Client communicating with a remote MCP server
+// using the Streamable HTTP transport (protocol 2025-03-26). All messages are
+// posted to the given endpoint.
+func NewStreamableHTTP(endpoint string, tlsConfig *tls.Config) Client {
+
+	client := &http.Client{
+		// There is no response header timeout, as the
+		// server can reply to a tool call only once it
+		// is done, which can take a long time.
+		Transport: &http.Transport{
+			TLSClientConfig: tlsConfig,
+		},
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		panic(err)
+	}
+
+	return &streamableClient{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		client:   client,
+		u:        u,
+	}
+}
+
+func (c *streamableClient) Type() string { return "http" }
+
+func (c *streamableClient) Server() string { return c.BaseURL() }
+
+func (c *streamableClient) BaseURL() string { return fmt.Sprintf("%s://%s", c.u.Scheme, c.u.Host) }
+
+func (c *streamableClient) HTTPClient() *http.Client { return c.client }
+
+func (c *streamableClient) Start(ctx context.Context, opts ...Option) (pipe *MCPStream, err error) {
+
+	cfg := cfg{}
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	// The session only exists once the server replied to initialize,
+	// so we probe the endpoint to find out early if the server requires
+	// authorization. This allows the caller to perform the oauth dance
+	// before the agent starts to talk.
+	pctx, cancel := context.WithTimeout(ctx, streamableProbeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(pctx, http.MethodGet, c.endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initiate request: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if cfg.auth != nil {
+		req.Header.Set("Authorization", cfg.auth.Encode())
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to send initial request (%s): %w", req.URL.String(), err)
+	}
+	_ = resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, ErrAuthRequired
+	}
+
+	stream := NewMCPStream(ctx)
+
+	s := &streamableSession{
+		endpoint: c.endpoint,
+		client:   c.client,
+		auth:     cfg.auth,
+		stdout:   stream.stdout,
+		exit:     stream.exit,
+	}
+
+	go s.readRequests(ctx, stream.stdin)
+
+	return stream, nil
+}
+
+// streamableSession holds the state of a single MCP
+// session established using the Streamable HTTP transport.
+type streamableSession struct {
+	endpoint  string
+	client    *http.Client
+	auth      *auth.Auth
+	stdout    chan []byte
+	exit      chan error
+	sid       string
+	listening bool
+
+	protocolVersion string
+	sync.RWMutex
+}
+
+// readRequests posts the messages sent by the agent. The messages are
+// posted one at a time and in order until notifications/initialized has
+// been sent, as the session is being established. Then the requests are
+// posted concurrently, up to streamableMaxPosts at a time, so a long tool
+// call does not hold the rest of the session, while the notifications
+// and responses are still posted in order.
+func (s *streamableSession) readRequests(ctx context.Context, ch chan []byte) {
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	wg := sync.WaitGroup{}
+	sem := make(chan struct{}, streamableMaxPosts)
+	initialized := false
+
+	defer func() {
+		cancel()
+		wg.Wait()
+		s.terminate()
+	}()
+
+	for {
+
+		select {
+
+		case <-ctx.Done():
+			return
+
+		case data := <-ch:
+
+			data = sanitize.Data(data)
+			method, request := summarizeMessage(data)
+
+			if !initialized || !request {
+				if err := s.post(ctx, data); err != nil {
+					s.fail(ctx, err)
+					return
+				}
+				initialized = initialized || method == "notifications/initialized"
+				continue
+			}
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+
+			wg.Add(1)
+			go func() {
+				defer func() { <-sem; wg.Done() }()
+
+				if err := s.post(ctx, data); err != nil {
+					s.fail(ctx, err)
+					cancel()
+				}
+			}()
+		}
+	}
+}
+
+func (s *streamableSession) post(ctx context.Context, data []byte) error {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewBuffer(data))
+	if err != nil {
+		return fmt.Errorf("unable to make post request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	s.setHeaders(req)
+
+	// the body is closed either here or by readEvents
+	// when the server decides to reply with a stream.
+	resp, err := s.client.Do(req) // nolint
+	if err != nil {
+		return fmt.Errorf("unable to send post request: %w", err)
+	}
+
+	switch resp.StatusCode {
+
+	case http.StatusOK:
+
+	case http.StatusAccepted:
+		_ = resp.Body.Close()
+		s.listen(ctx, data)
+		return nil
+
+	case http.StatusUnauthorized:
+		_ = resp.Body.Close()
+		return ErrAuthRequired
+
+	case http.StatusNotFound:
+		_ = resp.Body.Close()
+		if sid := s.sessionID(); sid != "" {
+			return fmt.Errorf("mcp session '%s' has been terminated by the server", sid)
+		}
+		return fmt.Errorf("invalid mcp server response status: %s", resp.Status)
+
+	default:
+		if resp.StatusCode >= http.StatusBadRequest && resp.StatusCode < http.StatusInternalServerError && s.rejected(ctx, data, resp) {
+			return nil
+		}
+		_ = resp.Body.Close()
+		return fmt.Errorf("invalid mcp server response status: %s", resp.Status)
+	}
+
+	if sid := resp.Header.Get("Mcp-Session-Id"); sid != "" {
+		s.Lock()
+		if s.sid == "" {
+			s.sid = sid
+			slog.Debug("Streamable Client: session established", "sid", sid)
+		}
+		s.Unlock()
+	}
+
+	ct, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+
+	switch ct {
+
+	case "text/event-stream":
+		go s.readEvents(ctx, resp.Body, true)
+
+	case "application/json":
+		defer func() { _ = resp.Body.Close() }()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("unable to read response body: %w", err)
+		}
+
+		if err := s.dispatch(ctx, body); err != nil {
+			return err
+		}
+
+	default:
+		_ = resp.Body.Close()
+		return fmt.Errorf("invalid mcp server response content type: '%s'", ct)
+	}
+
+	return nil
+}
+
+// rejected handles a client error response to the given message. If its
+// body holds a JSON-RPC error, the error is sent to the agent as the
+// response to the message, as the server refused it but the session is
+// still valid, and rejected returns true. The body is closed.
+func (s *streamableSession) rejected(ctx context.Context, data []byte, resp *http.Response) bool {
+
+	defer func() { _ = resp.Body.Close() }()
+
+	if ct, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); ct != "application/json" {
+		return false
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if err != nil {
+		return false
+	}
+
+	rerr := struct {
+		Error json.RawMessage `json:"error"`
+	}{}
+
+	if err := json.Unmarshal(body, &rerr); err != nil || len(rerr.Error) == 0 || string(rerr.Error) == "null" {
+		return false
+	}
+
+	// The error of a notification or a response has
+	// nothing to answer to, so it can only be logged.
+	id := messageID(data)
+	if id == nil {
+		slog.Warn("Streamable Client: message rejected by the server", "status", resp.Status, "error", string(rerr.Error))
+		return true
+	}
+
+	// The server may not give the id, as it
+	// could have been unable to decode it.
+	msg, err := json.Marshal(struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Error   json.RawMessage `json:"error"`
+	}{"2.0", id, rerr.Error})
+	if err != nil {
+		return false
+	}
+
+	return s.dispatch(ctx, msg) == nil
+}
+
+// listen opens the optional GET stream the server can use to
+// send requests and notifications outside of any agent request.
+// It is opened once the agent sent notifications/initialized.
+func (s *streamableSession) listen(ctx context.Context, data []byte) {
+
+	summary := struct {
+		Method string `json:"method"`
+	}{}
+
+	if err := json.Unmarshal(data, &summary); err != nil || summary.Method != "notifications/initialized" {
+		return
+	}
+
+	s.Lock()
+	if s.listening || s.sid == "" {
+		s.Unlock()
+		return
+	}
+	s.listening = true
+	s.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.endpoint, nil)
+	if err != nil {
+		slog.Error("Streamable Client: unable to make listen request", "err", err)
+		return
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	s.setHeaders(req)
+
+	go func() {
+
+		// the body is closed by readEvents.
+		resp, err := s.client.Do(req) // nolint
+		if err != nil {
+			slog.Error("Streamable Client: unable to open listen stream", "err", err)
+			return
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			_ = resp.Body.Close()
+			slog.Debug("Streamable Client: server does not offer a listen stream", "status", resp.Status)
+			return
+		}
+
+		s.readEvents(ctx, resp.Body, false)
+	}()
+}
+
+// readEvents reads the server sent events from the given body and
+// forwards their data to the stream stdout.
+// If failOnError is true, read errors will terminate the stream.
+func (s *streamableSession) readEvents(ctx context.Context, r io.ReadCloser, failOnError bool) {
+
+	defer func() { _ = r.Close() }()
+
+	scan := bufio.NewScanner(r)
+	scan.Split(split)
+	scan.Buffer(make([]byte, 1024), 5*1024*1024)
+
+	for scan.Scan() {
+
+		event, data := parseSSEEvent(scan.Bytes())
+		if len(data) == 0 || (event != "" && event != "message") {
+			continue
+		}
+
+		if err := s.dispatch(ctx, data); err != nil {
+			s.fail(ctx, err)
+			return
+		}
+	}
+
+	if err := scan.Err(); err != nil && ctx.Err() == nil {
+		if failOnError {
+			s.fail(ctx, fmt.Errorf("streamable http stream closed: %w", err))
+			return
+		}
+		slog.Debug("Streamable Client: listen stream closed", "err", err)
+	}
+}
+
+// dispatch sends the given JSON-RPC message or batch
+// of messages to the stream stdout.
+func (s *streamableSession) dispatch(ctx context.Context, data []byte) error {
+
+	data = bytes.TrimSpace(data)
+
+	msgs := []json.RawMessage{data}
+	if bytes.HasPrefix(data, []byte("[")) {
+		if err := json.Unmarshal(data, &msgs); err != nil {
+			return fmt.Errorf("unable to decode json-rpc batch: %w", err)
+		}
+	}
+
+	for _, msg := range msgs {
+
+		// messages are newline delimited downstream, so
+		// multiline payloads must be compacted.
+		if bytes.ContainsAny(msg, "\r\n") {
+			buf := &bytes.Buffer{}
+			if err := json.Compact(buf, msg); err != nil {
+				return fmt.Errorf("unable to compact json-rpc message: %w", err)
+			}
+			msg = buf.Bytes()
+		}
+
+		s.observe(msg)
+
+		select {
+		case s.stdout <- sanitize.Data(msg):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// observe looks for the initialize result to
+// learn about the negotiated protocol version.
+func (s *streamableSession) observe(data []byte) {
+
+	s.RLock()
+	known := s.protocolVersion != ""
+	s.RUnlock()
+
+	if known {
+		return
+	}
+
+	summary := struct {
+		Result struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		} `json:"result"`
+	}{}
+
+	if err := json.Unmarshal(data, &summary); err != nil || summary.Result.ProtocolVersion == "" {
+		return
+	}
+
+	s.Lock()
+	s.protocolVersion = summary.Result.ProtocolVersion
+	s.Unlock()
+}
+
+// terminate explicitly ends the MCP session
+// on the server, if any.
+func (s *streamableSession) terminate() {
+
+	sid := s.sessionID()
+	if sid == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.endpoint, nil)
+	if err != nil {
+		slog.Error("Streamable Client: unable to make delete request", "err", err)
+		return
+	}
+	s.setHeaders(req)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		slog.Debug("Streamable Client: unable to terminate session", "sid", sid, "err", err)
+		return
+	}
+	_ = resp.Body.Close()
+
+	slog.Debug("Streamable Client: session terminated", "sid", sid, "status", resp.Status)
+}
+
+// sessionID returns the ID of the session
+// given by the server, if any.
+func (s *streamableSession) sessionID() string {
+
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.sid
+}
+
+// summarizeMessage returns the method of the given JSON-RPC message,
+// and whether it is a request expecting a response. Batches are not
+// considered as requests.
+func summarizeMessage(data []byte) (string, bool) {
+
+	summary := struct {
+		Method string `json:"method"`
+	}{}
+
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return "", false
+	}
+
+	return summary.Method, summary.Method != "" && messageID(data) != nil
+}
+
+// messageID returns the id of the given JSON-RPC message,
+// or nil if it has none, like a notification.
+func messageID(data []byte) json.RawMessage {
+
+	summary := struct {
+		ID json.RawMessage `json:"id"`
+	}{}
+
+	if err := json.Unmarshal(data, &summary); err != nil || len(summary.ID) == 0 || string(summary.ID) == "null" {
+		return nil
+	}
+
+	return summary.ID
+}
+
+func (s *streamableSession) setHeaders(req *http.Request) {
+
+	s.RLock()
+	if s.sid != "" {
+		req.Header.Set("Mcp-Session-Id", s.sid)
+	}
+	if s.protocolVersion != "" {
+		req.Header.Set("Mcp-Protocol-Version", s.protocolVersion)
+	}
+	s.RUnlock()
+
+	if s.auth != nil {
+		req.Header.Set("Authorization", s.auth.Encode())
+	}
+}
+
+func (s *streamableSession) fail(ctx context.Context, err error) {
+	select {
+	case s.exit <- err:
+	case <-ctx.Done():
+	}
+}
+
+// parseSSEEvent parses a single server sent event and returns
+// its type and data. Multiple data lines are joined with '\n'.
+func parseSSEEvent(raw []byte) (event string, data []byte) {
+
+	var lines [][]byte
+
+	for line := range bytes.Lines(raw) {
+
+		line = bytes.TrimRight(line, "\r\n")
+
+		field, value, _ := bytes.Cut(line, []byte(":"))
+		value = bytes.TrimPrefix(value, []byte(" "))
+
+		switch string(field) {
+		case "event":
+			event = string(value)
+		case "data":
+			lines = append(lines, value)
+		}
+	}
+
+	return event, bytes.Join(lines, []byte{'\n'})
+}
@@ -0,0 +1,373 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStreamableClient(t *testing.T) {
+
+	Convey("Type is correct", t, func() {
+		cl := NewStreamableHTTP("https://127.0.0.1/mcp", nil)
+		So(cl.Type(), ShouldEqual, "http")
+		So(cl.Server(), ShouldEqual, "https://127.0.0.1")
+	})
+
+	Convey("Server does not respond", t, func() {
+
+		cl := NewStreamableHTTP("http://789.11.22.11/mcp", nil)
+
+		ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+		defer cancel()
+
+		pipe, err := cl.Start(ctx)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldStartWith, "unable to send initial request (http://789.11.22.11/mcp): ")
+		So(pipe, ShouldBeNil)
+	})
+
+	Convey("Server requires authorization", t, func() {
+
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer ts.Close()
+
+		cl := NewStreamableHTTP(ts.URL+"/mcp", nil)
+
+		pipe, err := cl.Start(t.Context())
+		So(err, ShouldEqual, ErrAuthRequired)
+		So(pipe, ShouldBeNil)
+	})
+
+	Convey("Server responds with json", t, func() {
+
+		var l sync.Mutex
+		var sids []string
+		var deleted string
+
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+			l.Lock()
+			defer l.Unlock()
+
+			switch req.Method {
+			case http.MethodGet:
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			case http.MethodDelete:
+				deleted = req.Header.Get("Mcp-Session-Id")
+			case http.MethodPost:
+				sids = append(sids, req.Header.Get("Mcp-Session-Id"))
+				data, _ := io.ReadAll(req.Body)
+				w.Header().Set("Mcp-Session-Id", "abcd")
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = fmt.Fprintf(w, `[%s, {"jsonrpc":"2.0","method":"notifications/hello"}]`, data)
+			}
+		}))
+		defer ts.Close()
+
+		cl := NewStreamableHTTP(ts.URL+"/mcp", nil)
+
+		ctx, cancel := context.WithCancel(t.Context())
+
+		pipe, err := cl.Start(ctx)
+		So(err, ShouldBeNil)
+		So(pipe, ShouldNotBeNil)
+
+		out, unregister := pipe.Stdout()
+		defer unregister()
+
+		pipe.Stdin() <- []byte(`{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+		So(string(<-out), ShouldEqual, `{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+		So(string(<-out), ShouldEqual, `{"jsonrpc":"2.0","method":"notifications/hello"}`)
+
+		pipe.Stdin() <- []byte(`{"jsonrpc":"2.0","id":2,"method":"ping"}`)
+		So(string(<-out), ShouldEqual, `{"jsonrpc":"2.0","id":2,"method":"ping"}`)
+		So(string(<-out), ShouldEqual, `{"jsonrpc":"2.0","method":"notifications/hello"}`)
+
+		cancel()
+		time.Sleep(300 * time.Millisecond)
+
+		l.Lock()
+		defer l.Unlock()
+		So(sids, ShouldResemble, []string{"", "abcd"})
+		So(deleted, ShouldEqual, "abcd")
+	})
+
+	Convey("Server takes time to respond to a call", t, func() {
+
+		release := make(chan struct{})
+
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+			if req.Method != http.MethodPost {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+
+			data, _ := io.ReadAll(req.Body)
+
+			switch {
+			case !strings.Contains(string(data), `"id"`):
+				w.WriteHeader(http.StatusAccepted)
+				return
+			case strings.Contains(string(data), `"slow"`):
+				select {
+				case <-release:
+				case <-req.Context().Done():
+				}
+			case strings.Contains(string(data), `"fast"`):
+				close(release)
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(data)
+		}))
+		defer ts.Close()
+
+		cl := NewStreamableHTTP(ts.URL, nil)
+
+		ctx, cancel := context.WithCancel(t.Context())
+		defer cancel()
+
+		pipe, err := cl.Start(ctx)
+		So(err, ShouldBeNil)
+
+		out, unregister := pipe.Stdout()
+		defer unregister()
+
+		pipe.Stdin() <- []byte(`{"jsonrpc":"2.0","id":1,"method":"initialize"}`)
+		So(string(<-out), ShouldEqual, `{"jsonrpc":"2.0","id":1,"method":"initialize"}`)
+
+		pipe.Stdin() <- []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+		pipe.Stdin() <- []byte(`{"jsonrpc":"2.0","id":2,"method":"slow"}`)
+		pipe.Stdin() <- []byte(`{"jsonrpc":"2.0","id":3,"method":"fast"}`)
+
+		// The slow call only returns once the fast one has been received.
+		var replies []string
+		for range 2 {
+			select {
+			case data := <-out:
+				replies = append(replies, string(data))
+			case <-time.After(5 * time.Second):
+			}
+		}
+		So(replies, ShouldHaveLength, 2)
+		So(replies, ShouldContain, `{"jsonrpc":"2.0","id":2,"method":"slow"}`)
+		So(replies, ShouldContain, `{"jsonrpc":"2.0","id":3,"method":"fast"}`)
+	})
+
+	Convey("Server takes time to accept the initialized notification", t, func() {
+
+		var l sync.Mutex
+		var methods []string
+
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+			if req.Method != http.MethodPost {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+
+			data, _ := io.ReadAll(req.Body)
+			method, _ := summarizeMessage(data)
+
+			if method == "notifications/initialized" {
+				time.Sleep(100 * time.Millisecond)
+			}
+
+			l.Lock()
+			methods = append(methods, method)
+			l.Unlock()
+
+			if messageID(data) == nil {
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(data)
+		}))
+		defer ts.Close()
+
+		cl := NewStreamableHTTP(ts.URL, nil)
+
+		ctx, cancel := context.WithCancel(t.Context())
+		defer cancel()
+
+		pipe, err := cl.Start(ctx)
+		So(err, ShouldBeNil)
+
+		out, unregister := pipe.Stdout()
+		defer unregister()
+
+		pipe.Stdin() <- []byte(`{"jsonrpc":"2.0","id":1,"method":"initialize"}`)
+		pipe.Stdin() <- []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+		pipe.Stdin() <- []byte(`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+		pipe.Stdin() <- []byte(`{"jsonrpc":"2.0","method":"notifications/roots/list_changed"}`)
+		pipe.Stdin() <- []byte(`{"jsonrpc":"2.0","method":"notifications/cancelled"}`)
+
+		So(string(<-out), ShouldEqual, `{"jsonrpc":"2.0","id":1,"method":"initialize"}`)
+		So(string(<-out), ShouldEqual, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			l.Lock()
+			n := len(methods)
+			l.Unlock()
+			if n == 5 {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		l.Lock()
+		defer l.Unlock()
+		So(methods, ShouldHaveLength, 5)
+		So(methods[:2], ShouldResemble, []string{"initialize", "notifications/initialized"})
+		So(methods[2:], ShouldContain, "tools/list")
+		So(
+			slices.Index(methods, "notifications/roots/list_changed"),
+			ShouldBeLessThan,
+			slices.Index(methods, "notifications/cancelled"),
+		)
+	})
+
+	Convey("Server rejects a message with a JSON-RPC error", t, func() {
+
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+			if req.Method != http.MethodPost {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+
+			data, _ := io.ReadAll(req.Body)
+			w.Header().Set("Content-Type", "application/json")
+
+			if strings.Contains(string(data), `"bad"`) {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":null,"error":{"code":-32602,"message":"invalid params"}}`))
+				return
+			}
+
+			_, _ = w.Write(data)
+		}))
+		defer ts.Close()
+
+		cl := NewStreamableHTTP(ts.URL, nil)
+
+		ctx, cancel := context.WithCancel(t.Context())
+		defer cancel()
+
+		pipe, err := cl.Start(ctx)
+		So(err, ShouldBeNil)
+
+		out, unregister := pipe.Stdout()
+		defer unregister()
+
+		pipe.Stdin() <- []byte(`{"jsonrpc":"2.0","id":1,"method":"bad"}`)
+		So(string(<-out), ShouldEqual, `{"jsonrpc":"2.0","id":1,"error":{"code":-32602,"message":"invalid params"}}`)
+
+		pipe.Stdin() <- []byte(`{"jsonrpc":"2.0","id":2,"method":"ping"}`)
+		So(string(<-out), ShouldEqual, `{"jsonrpc":"2.0","id":2,"method":"ping"}`)
+	})
+
+	Convey("Server responds with an event stream", t, func() {
+
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+
+			if req.Method != http.MethodPost {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = fmt.Fprint(w, ": comment\n\n")
+			_, _ = fmt.Fprint(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\ndata: \"method\":\"notifications/progress\"}\n\n")
+			_, _ = fmt.Fprint(w, "id: 1\ndata: {\"jsonrpc\":\"2.0\",\"id\":1,\"result\":{}}\n\n")
+		}))
+		defer ts.Close()
+
+		cl := NewStreamableHTTP(ts.URL, nil)
+
+		ctx, cancel := context.WithCancel(t.Context())
+		defer cancel()
+
+		pipe, err := cl.Start(ctx)
+		So(err, ShouldBeNil)
+
+		out, unregister := pipe.Stdout()
+		defer unregister()
+
+		pipe.Stdin() <- []byte(`{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+		So(string(<-out), ShouldEqual, `{"jsonrpc":"2.0","method":"notifications/progress"}`)
+		So(string(<-out), ShouldEqual, `{"jsonrpc":"2.0","id":1,"result":{}}`)
+	})
+
+	Convey("Server responds with an invalid status code", t, func() {
+
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.Method == http.MethodPost {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		}))
+		defer ts.Close()
+
+		cl := NewStreamableHTTP(ts.URL, nil)
+
+		ctx, cancel := context.WithCancel(t.Context())
+		defer cancel()
+
+		pipe, err := cl.Start(ctx)
+		So(err, ShouldBeNil)
+
+		exit, unregister := pipe.Exit()
+		defer unregister()
+
+		pipe.Stdin() <- []byte(`{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+
+		err = <-exit
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "invalid mcp server response status: 400 Bad Request")
+	})
+}
+
+func TestParseSSEEvent(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		raw       string
+		wantEvent string
+		wantData  string
+	}{
+		{"simple data", "data: hello", "", "hello"},
+		{"data without space", "data:hello", "", "hello"},
+		{"event and data", "event: message\ndata: hello", "message", "hello"},
+		{"multiline data", "data: a\ndata: b\r\n", "", "a\nb"},
+		{"comment only", ": ping", "", ""},
+		{"id is ignored", "id: 3\ndata: x", "", "x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, data := parseSSEEvent([]byte(tt.raw))
+			if event != tt.wantEvent {
+				t.Errorf("parseSSEEvent() event = %v, want %v", event, tt.wantEvent)
+			}
+			if string(data) != tt.wantData {
+				t.Errorf("parseSSEEvent() data = %v, want %v", string(data), tt.wantData)
+			}
+		})
+	}
+}
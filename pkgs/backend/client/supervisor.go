@@ -0,0 +1,471 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"go.acuvity.ai/elemental"
+	"go.acuvity.ai/minibridge/pkgs/mcp"
+)
+
+const (
+	// supervisorStableAfter is the time after which a running
+	// MCP server is considered stable and its restarts are reset.
+	supervisorStableAfter = time.Minute
+
+	// supervisorReplayTimeout is the maximum time to wait for
+	// the response to the replayed initialize request.
+	supervisorReplayTimeout = 30 * time.Second
+)
+
+var _ Client = (*supervisor)(nil)
+var _ io.Closer = (*supervisor)(nil)
+
+type supervisor struct {
+	client Client
+	cfg    supervisorCfg
+}
+
+// NewSupervisor returns a Client that restarts the MCP server started by
+// the given Client when it exits unexpectedly, with an exponential backoff.
+//
+// Once restarted, the initialize request and the initialized notification
+// sent by the agent are replayed, so the agent keeps its session. The
+// requests that were in flight when the server exited, or sent while it
+// is restarting, receive a JSON-RPC error.
+//
+// The returned MCPStream only sends to its exit channel when the
+// context is canceled or when the server cannot be restarted anymore.
+func NewSupervisor(cl Client, options ...SupervisorOption) Client {
+
+	cfg := newSupervisorCfg()
+	for _, o := range options {
+		o(&cfg)
+	}
+
+	return &supervisor{
+		client: cl,
+		cfg:    cfg,
+	}
+}
+
+func (s *supervisor) Type() string { return s.client.Type() }
+
+func (s *supervisor) Server() string { return s.client.Server() }
+
+// Close closes the supervised Client, if it is an io.Closer.
+func (s *supervisor) Close() error {
+
+	if c, ok := s.client.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+func (s *supervisor) Start(ctx context.Context, opts ...Option) (*MCPStream, error) {
+
+	ictx, cancel := context.WithCancel(ctx)
+
+	inner, err := s.client.Start(ictx, opts...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	stream := NewMCPStream(ctx)
+
+	go s.supervise(ctx, stream, watchStream(inner), cancel, opts)
+
+	return stream, nil
+}
+
+func (s *supervisor) supervise(ctx context.Context, stream *MCPStream, inner *watchedStream, cancel context.CancelFunc, opts []Option) {
+
+	st := &supervision{
+		inflight: map[string]any{},
+	}
+
+	restarts := 0
+
+	for {
+
+		started := time.Now()
+
+		err := s.forward(ctx, stream, inner, st)
+		inner.unregister()
+		cancel()
+
+		if ctx.Err() != nil {
+			sendExit(stream, err)
+			return
+		}
+
+		st.failInflight(ctx, stream, err)
+
+		if time.Since(started) > supervisorStableAfter {
+			restarts = 0
+		}
+
+		for {
+
+			if restarts >= s.cfg.maxRestarts {
+				slog.Error("MCP server exited too many times: giving up",
+					"server", s.client.Server(),
+					"restarts", restarts,
+					"err", err,
+				)
+				sendExit(stream, err)
+				return
+			}
+
+			restarts++
+
+			backoff := s.cfg.backoff(restarts)
+
+			slog.Warn("MCP server exited unexpectedly: restarting",
+				"server", s.client.Server(),
+				"attempt", restarts,
+				"backoff", backoff,
+				"err", err,
+			)
+
+			if !st.drain(ctx, stream, time.After(backoff)) {
+				sendExit(stream, ctx.Err())
+				return
+			}
+
+			if s.cfg.metricsManager != nil {
+				s.cfg.metricsManager.RecordRestart(s.client.Server())
+			}
+
+			var ictx context.Context
+			ictx, cancel = context.WithCancel(ctx)
+
+			if inner, err = s.restart(ictx, opts, st); err == nil {
+				break
+			}
+
+			cancel()
+		}
+	}
+}
+
+// forward forwards the messages between the outer stream
+// and the current MCP server until the server exits.
+// It returns the exit error of the server.
+func (s *supervisor) forward(ctx context.Context, stream *MCPStream, inner *watchedStream, st *supervision) error {
+
+	out, errs, exit := inner.out, inner.errs, inner.exit
+
+	for {
+		select {
+
+		case data := <-stream.stdin:
+
+			st.outbound(data)
+
+			select {
+			case inner.Stdin() <- data:
+			case err := <-exit:
+				return err
+			case <-ctx.Done():
+				return waitExit(ctx, exit)
+			}
+
+		case data := <-out:
+
+			st.inbound(data)
+
+			select {
+			case stream.stdout <- data:
+			case <-ctx.Done():
+				return waitExit(ctx, exit)
+			}
+
+		case data := <-errs:
+
+			select {
+			case stream.stderr <- data:
+			case <-ctx.Done():
+				return waitExit(ctx, exit)
+			}
+
+		case err := <-exit:
+			return err
+
+		case <-ctx.Done():
+			return waitExit(ctx, exit)
+		}
+	}
+}
+
+// restart starts a new MCP server and replays the handshake of the agent.
+func (s *supervisor) restart(ctx context.Context, opts []Option, st *supervision) (inner *watchedStream, err error) {
+
+	started, err := s.client.Start(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to restart mcp server: %w", err)
+	}
+
+	inner = watchStream(started)
+	defer func() {
+		if err != nil {
+			inner.unregister()
+		}
+	}()
+
+	if st.init == nil {
+		return inner, nil
+	}
+
+	tctx, cancel := context.WithTimeout(ctx, supervisorReplayTimeout)
+	defer cancel()
+
+	if _, err := handshake(tctx, inner, st.init, st.initID, st.initialized); err != nil {
+		return nil, err
+	}
+
+	slog.Info("MCP server restarted", "server", s.client.Server())
+
+	return inner, nil
+}
+
+// handshake sends the given initialize request to the MCP server, waits
+// for its response, then sends the given initialized notification, if any.
+// It returns the response to the initialize request.
+func handshake(ctx context.Context, inner *watchedStream, init []byte, initID any, initialized []byte) (mcp.Message, error) {
+
+	out, exit := inner.out, inner.exit
+
+	select {
+	case inner.Stdin() <- init:
+	case err := <-exit:
+		return mcp.Message{}, fmt.Errorf("mcp server exited during initialization: %w", err)
+	case <-ctx.Done():
+		return mcp.Message{}, fmt.Errorf("unable to send initialize request: %w", ctx.Err())
+	}
+
+	var resp mcp.Message
+
+	for {
+
+		var data []byte
+
+		select {
+		case data = <-out:
+		case err := <-exit:
+			return mcp.Message{}, fmt.Errorf("mcp server exited during initialization: %w", err)
+		case <-ctx.Done():
+			return mcp.Message{}, fmt.Errorf("unable to get initialize response: %w", ctx.Err())
+		}
+
+		resp = mcp.Message{}
+		if err := elemental.Decode(elemental.EncodingTypeJSON, data, &resp); err != nil {
+			continue
+		}
+
+		if !mcp.RelatedIDs(initID, resp.ID) {
+			continue
+		}
+
+		if resp.Error != nil {
+			return mcp.Message{}, fmt.Errorf("mcp server refused initialize request: %s", resp.Error.Message)
+		}
+
+		break
+	}
+
+	if initialized != nil {
+		select {
+		case inner.Stdin() <- initialized:
+		case err := <-exit:
+			return mcp.Message{}, fmt.Errorf("mcp server exited during initialization: %w", err)
+		case <-ctx.Done():
+			return mcp.Message{}, fmt.Errorf("unable to send initialized notification: %w", ctx.Err())
+		}
+	}
+
+	return resp, nil
+}
+
+// sendExit sends the given error to the exit channel of the stream.
+func sendExit(stream *MCPStream, err error) {
+	select {
+	case stream.exit <- err:
+	case <-time.After(time.Second):
+	}
+}
+
+// watchedStream holds the channels registered on the stream of an MCP
+// server. They are registered as soon as the server is started, so
+// the supervisor does not miss its exit.
+type watchedStream struct {
+	*MCPStream
+	out  chan []byte
+	errs chan []byte
+	exit chan error
+
+	unregisters []func()
+}
+
+func watchStream(stream *MCPStream) *watchedStream {
+
+	w := &watchedStream{MCPStream: stream}
+
+	var unregisterOut, unregisterErr, unregisterExit func()
+
+	w.exit, unregisterExit = stream.Exit()
+	w.out, unregisterOut = stream.Stdout()
+	w.errs, unregisterErr = stream.Stderr()
+
+	w.unregisters = []func(){unregisterOut, unregisterErr, unregisterExit}
+
+	return w
+}
+
+func (w *watchedStream) unregister() {
+	for _, f := range w.unregisters {
+		f()
+	}
+}
+
+// supervision holds the state of the agent session
+// needed to restart the MCP server.
+type supervision struct {
+	init        []byte
+	initID      any
+	initialized []byte
+	inflight    map[string]any
+}
+
+func (st *supervision) outbound(data []byte) {
+
+	msg := mcp.Message{}
+	if err := elemental.Decode(elemental.EncodingTypeJSON, data, &msg); err != nil {
+		return
+	}
+
+	switch {
+
+	case msg.Method == "initialize" && msg.ID != nil:
+		st.init = bytes.Clone(data)
+		st.initID = msg.ID
+		st.inflight[msg.IDString()] = msg.ID
+
+	case msg.Method == "notifications/initialized":
+		st.initialized = bytes.Clone(data)
+
+	case msg.Method != "" && msg.ID != nil:
+		st.inflight[msg.IDString()] = msg.ID
+	}
+}
+
+func (st *supervision) inbound(data []byte) {
+
+	if len(st.inflight) == 0 {
+		return
+	}
+
+	msg := mcp.Message{}
+	if err := elemental.Decode(elemental.EncodingTypeJSON, data, &msg); err != nil {
+		return
+	}
+
+	if msg.Method == "" && msg.ID != nil {
+		delete(st.inflight, msg.IDString())
+	}
+}
+
+// failInflight sends an error to the agent
+// for each request that was in flight.
+func (st *supervision) failInflight(ctx context.Context, stream *MCPStream, exitErr error) {
+
+	reason := fmt.Errorf("mcp server exited unexpectedly")
+	if exitErr != nil {
+		reason = fmt.Errorf("mcp server exited unexpectedly: %w", exitErr)
+	}
+
+	for k, id := range st.inflight {
+
+		if !failRequest(ctx, stream, id, reason) {
+			return
+		}
+
+		delete(st.inflight, k)
+	}
+}
+
+// drain reads the messages of the agent until the given channel
+// receives, so the agent connection does not stall while the MCP
+// server is down. Requests receive a JSON-RPC error, and the
+// initialized notification is kept to be replayed. It returns
+// false if the context is canceled.
+func (st *supervision) drain(ctx context.Context, stream *MCPStream, until <-chan time.Time) bool {
+
+	for {
+		select {
+
+		case data := <-stream.stdin:
+
+			msg := mcp.Message{}
+			if err := elemental.Decode(elemental.EncodingTypeJSON, data, &msg); err != nil {
+				continue
+			}
+
+			switch {
+
+			case msg.Method == "notifications/initialized":
+				st.initialized = bytes.Clone(data)
+
+			case msg.Method != "" && msg.ID != nil:
+				if !failRequest(ctx, stream, msg.ID, fmt.Errorf("mcp server is restarting")) {
+					return false
+				}
+			}
+
+		case <-until:
+			return true
+
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// failRequest sends an error to the agent for the request with
+// the given ID. It returns false if the context is canceled.
+func failRequest(ctx context.Context, stream *MCPStream, id any, reason error) bool {
+
+	msg := mcp.NewMessage("")
+	msg.ID = id
+	msg.Error = mcp.NewError(reason)
+
+	data, err := elemental.Encode(elemental.EncodingTypeJSON, msg)
+	if err != nil {
+		slog.Error("Unable to encode request error", "err", err)
+		return true
+	}
+
+	select {
+	case stream.stdout <- data:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// waitExit waits for the MCP server to exit after
+// the context has been canceled.
+func waitExit(ctx context.Context, exit chan error) error {
+	select {
+	case err := <-exit:
+		return err
+	case <-time.After(time.Second):
+		return ctx.Err()
+	}
+}
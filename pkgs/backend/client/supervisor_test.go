@@ -0,0 +1,158 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const supervisorTestServer = `
+while read -r line; do
+	case "$line" in
+		*'"initialize"'*) echo init >> "$INIT_LOG"; echo '{"jsonrpc":"2.0","id":1,"result":{}}' ;;
+		*'"notifications/initialized"'*) echo initialized >> "$INIT_LOG" ;;
+		*'"crash"'*) exit 1 ;;
+		*'"ping"'*) echo '{"jsonrpc":"2.0","id":2,"result":{}}' ;;
+	esac
+done
+`
+
+func TestSupervisor(t *testing.T) {
+
+	Convey("Given I have a supervised server that crashes", t, func() {
+
+		initLog := filepath.Join(t.TempDir(), "init.log")
+
+		srv := MCPServer{
+			Command: "sh",
+			Args:    []string{"-c", supervisorTestServer},
+			Env:     []string{"INIT_LOG=" + initLog},
+		}
+
+		cl := NewSupervisor(
+			NewStdio(srv),
+			OptSupervisorBackoff(10*time.Millisecond, 10*time.Millisecond),
+		)
+
+		So(cl.Type(), ShouldEqual, "stdio")
+		So(cl.Server(), ShouldEqual, "sh")
+
+		stream, err := cl.Start(t.Context())
+		So(err, ShouldBeNil)
+
+		out, unregisterOut := stream.Stdout()
+		defer unregisterOut()
+
+		exit, unregisterExit := stream.Exit()
+		defer unregisterExit()
+
+		stream.Stdin() <- []byte(`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+		So(string(<-out), ShouldEqual, `{"jsonrpc":"2.0","id":1,"result":{}}`)
+
+		stream.Stdin() <- []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+
+		Convey("When I send a request that crashes the server", func() {
+
+			stream.Stdin() <- []byte(`{"jsonrpc":"2.0","id":3,"method":"crash"}`)
+
+			Convey("Then I should get an error for the request in flight", func() {
+				So(string(<-out), ShouldEqual, `{"error":{"code":500,"message":"mcp server exited unexpectedly: exit status 1"},"id":3,"jsonrpc":"2.0"}`)
+			})
+
+			Convey("Then the server should be restarted and usable without initializing again", func() {
+				<-out
+
+				// Requests sent during the restart are refused,
+				// so wait for the handshake to be replayed.
+				var replayed []string
+				for deadline := time.Now().Add(5 * time.Second); len(replayed) < 4 && time.Now().Before(deadline); {
+					time.Sleep(10 * time.Millisecond)
+					data, _ := os.ReadFile(initLog)
+					replayed = strings.Fields(string(data))
+				}
+				So(replayed, ShouldResemble, []string{"init", "initialized", "init", "initialized"})
+
+				stream.Stdin() <- []byte(`{"jsonrpc":"2.0","id":2,"method":"ping"}`)
+				So(string(<-out), ShouldEqual, `{"jsonrpc":"2.0","id":2,"result":{}}`)
+
+				select {
+				case err := <-exit:
+					So(err, ShouldBeNil)
+				default:
+				}
+			})
+		})
+	})
+
+	Convey("Given I have a supervised server that crashes and restarts slowly", t, func() {
+
+		srv := MCPServer{
+			Command: "sh",
+			Args:    []string{"-c", supervisorTestServer},
+			Env:     []string{"INIT_LOG=" + filepath.Join(t.TempDir(), "init.log")},
+		}
+
+		cl := NewSupervisor(
+			NewStdio(srv),
+			OptSupervisorBackoff(time.Second, time.Second),
+		)
+
+		stream, err := cl.Start(t.Context())
+		So(err, ShouldBeNil)
+
+		out, unregisterOut := stream.Stdout()
+		defer unregisterOut()
+
+		stream.Stdin() <- []byte(`{"jsonrpc":"2.0","id":3,"method":"crash"}`)
+		So(string(<-out), ShouldEqual, `{"error":{"code":500,"message":"mcp server exited unexpectedly: exit status 1"},"id":3,"jsonrpc":"2.0"}`)
+
+		Convey("When I send a request while the server is restarting", func() {
+
+			stream.Stdin() <- []byte(`{"jsonrpc":"2.0","method":"notifications/cancelled"}`)
+			stream.Stdin() <- []byte(`{"jsonrpc":"2.0","id":4,"method":"ping"}`)
+
+			Convey("Then I should get an error right away", func() {
+				select {
+				case data := <-out:
+					So(string(data), ShouldEqual, `{"error":{"code":500,"message":"mcp server is restarting"},"id":4,"jsonrpc":"2.0"}`)
+				case <-time.After(500 * time.Millisecond):
+					So("timeout waiting for error", ShouldBeEmpty)
+				}
+			})
+		})
+	})
+
+	Convey("Given I have a supervised server that keeps crashing", t, func() {
+
+		srv := MCPServer{
+			Command: "sh",
+			Args:    []string{"-c", "exit 1"},
+		}
+
+		cl := NewSupervisor(
+			NewStdio(srv),
+			OptSupervisorMaxRestarts(2),
+			OptSupervisorBackoff(10*time.Millisecond, 10*time.Millisecond),
+		)
+
+		stream, err := cl.Start(t.Context())
+		So(err, ShouldBeNil)
+
+		exit, unregisterExit := stream.Exit()
+		defer unregisterExit()
+
+		Convey("Then the exit channel should receive the error once the restarts are exhausted", func() {
+			select {
+			case err := <-exit:
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "exit status 1")
+			case <-time.After(5 * time.Second):
+				So("timeout waiting for exit", ShouldBeEmpty)
+			}
+		})
+	})
+}
@@ -0,0 +1,91 @@
+package backend
+
+import (
+	"encoding/json"
+
+	"go.acuvity.ai/minibridge/pkgs/egress"
+)
+
+// egressTracker tracks the tool calls of a session, so the
+// egress proxy can log the calls responsible for the traffic.
+// All methods can be called on a nil egressTracker.
+type egressTracker struct {
+	proxy   *egress.Proxy
+	session string
+	calls   map[string]func()
+}
+
+func newEgressTracker(proxy *egress.Proxy, session string) *egressTracker {
+
+	if proxy == nil {
+		return nil
+	}
+
+	return &egressTracker{
+		proxy:   proxy,
+		session: session,
+		calls:   map[string]func(){},
+	}
+}
+
+// outbound tracks the tool calls sent by the agent.
+func (t *egressTracker) outbound(data []byte) {
+
+	if t == nil {
+		return
+	}
+
+	msg := struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+		Params struct {
+			Name string `json:"name"`
+		} `json:"params"`
+	}{}
+
+	if err := json.Unmarshal(data, &msg); err != nil || msg.Method != "tools/call" || len(msg.ID) == 0 {
+		return
+	}
+
+	if done, ok := t.calls[string(msg.ID)]; ok {
+		done()
+	}
+
+	t.calls[string(msg.ID)] = t.proxy.TrackCall(t.session, msg.Params.Name)
+}
+
+// inbound stops tracking the tool calls the MCP server responded to.
+func (t *egressTracker) inbound(data []byte) {
+
+	if t == nil || len(t.calls) == 0 {
+		return
+	}
+
+	msg := struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+	}{}
+
+	if err := json.Unmarshal(data, &msg); err != nil || msg.Method != "" || len(msg.ID) == 0 {
+		return
+	}
+
+	if done, ok := t.calls[string(msg.ID)]; ok {
+		done()
+		delete(t.calls, string(msg.ID))
+	}
+}
+
+// close stops tracking all the tool calls.
+func (t *egressTracker) close() {
+
+	if t == nil {
+		return
+	}
+
+	for _, done := range t.calls {
+		done()
+	}
+
+	clear(t.calls)
+}
@@ -0,0 +1,55 @@
+package backend
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.acuvity.ai/minibridge/pkgs/egress"
+)
+
+func TestEgressTracker(t *testing.T) {
+
+	Convey("Given I have an egress tracker without proxy", t, func() {
+
+		tracker := newEgressTracker(nil, "sid")
+		So(tracker, ShouldBeNil)
+
+		So(func() {
+			tracker.outbound([]byte(`{"id":1,"method":"tools/call","params":{"name":"fetch"}}`))
+			tracker.inbound([]byte(`{"id":1,"result":{}}`))
+			tracker.close()
+		}, ShouldNotPanic)
+	})
+
+	Convey("Given I have an egress tracker", t, func() {
+
+		a, _ := egress.NewAllowlist()
+		proxy := egress.NewProxy(a, "sid")
+		tracker := newEgressTracker(proxy, "sid")
+
+		Convey("When I send tool calls", func() {
+
+			tracker.outbound([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"fetch"}}`))
+			tracker.outbound([]byte(`{"jsonrpc":"2.0","id":"2","method":"tools/call","params":{"name":"search"}}`))
+			tracker.outbound([]byte(`{"jsonrpc":"2.0","id":3,"method":"tools/list"}`))
+			tracker.outbound([]byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`))
+
+			So(proxy.Calls(), ShouldResemble, []string{"fetch@sid", "search@sid"})
+
+			Convey("Then responses should stop tracking the calls", func() {
+
+				tracker.inbound([]byte(`{"jsonrpc":"2.0","id":3,"result":{}}`))
+				tracker.inbound([]byte(`{"jsonrpc":"2.0","id":1,"method":"sampling/createMessage"}`))
+				So(proxy.Calls(), ShouldResemble, []string{"fetch@sid", "search@sid"})
+
+				tracker.inbound([]byte(`{"jsonrpc":"2.0","id":1,"result":{}}`))
+				So(proxy.Calls(), ShouldResemble, []string{"search@sid"})
+			})
+
+			Convey("Then closing the tracker should stop tracking the calls", func() {
+				tracker.close()
+				So(proxy.Calls(), ShouldBeEmpty)
+			})
+		})
+	})
+}
@@ -0,0 +1,151 @@
+package backend
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.acuvity.ai/minibridge/pkgs/backend/client"
+	"go.acuvity.ai/minibridge/pkgs/internal/cors"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+var _ Backend = (*gatewayBackend)(nil)
+
+var gatewayNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// GatewayServer is a named MCP server hosted by a gateway backend.
+type GatewayServer struct {
+	// Name is the name of the server, used in the
+	// paths to reach it.
+	Name string
+
+	// Client is the client used to start the server.
+	Client client.Client
+
+	// Options are the options applied to this server only,
+	// after the ones given to NewGateway.
+	Options []Option
+}
+
+type gatewayBackend struct {
+	cfg       wsCfg
+	server    *http.Server
+	backends  map[string]*wsBackend
+	listen    string
+	tlsConfig *tls.Config
+}
+
+// NewGateway returns a new backend.Backend hosting several named MCP servers
+// behind a single listener. Each server is reachable with the following paths:
+//
+//	/ws/{server}
+//	/_info/{server}
+//	/oauth2/{server}/...
+//
+// The given options apply to every server, and are used for the CORS policy,
+// the listener and the metrics of the connections. Each server can override
+// them, to use its own policer or SBOM for instance.
+func NewGateway(listen string, tlsConfig *tls.Config, servers []GatewayServer, opts ...Option) (Backend, error) {
+
+	if len(servers) == 0 {
+		return nil, fmt.Errorf("at least one server must be given")
+	}
+
+	cfg := newWSCfg()
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	p := &gatewayBackend{
+		cfg:       cfg,
+		backends:  make(map[string]*wsBackend, len(servers)),
+		listen:    listen,
+		tlsConfig: tlsConfig,
+	}
+
+	for _, srv := range servers {
+
+		if !gatewayNameRegexp.MatchString(srv.Name) {
+			return nil, fmt.Errorf("invalid server name '%s': must match %s", srv.Name, gatewayNameRegexp)
+		}
+
+		if _, ok := p.backends[srv.Name]; ok {
+			return nil, fmt.Errorf("duplicate server name '%s'", srv.Name)
+		}
+
+		if srv.Client == nil {
+			return nil, fmt.Errorf("server '%s' has no client", srv.Name)
+		}
+
+		scfg := newWSCfg()
+		for _, o := range opts {
+			o(&scfg)
+		}
+		for _, o := range srv.Options {
+			o(&scfg)
+		}
+
+		p.backends[srv.Name] = &wsBackend{
+			client: srv.Client,
+			cfg:    scfg,
+		}
+	}
+
+	p.server = &http.Server{
+		Handler:           otelhttp.NewHandler(http.HandlerFunc(p.ServeHTTP), "gateway"),
+		ReadHeaderTimeout: time.Second,
+	}
+
+	return p, nil
+}
+
+// Start starts the server and will block until the given
+// context is canceled.
+func (p *gatewayBackend) Start(ctx context.Context) error {
+	return serve(ctx, p.server, p.listen, p.tlsConfig, p.cfg, p.init)
+}
+
+func (p *gatewayBackend) init(ctx context.Context) {
+	for _, b := range p.backends {
+		b.init(ctx)
+	}
+}
+
+func (p *gatewayBackend) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+
+	if !cors.HandleCORS(w, req, p.cfg.corsPolicy) {
+		return
+	}
+
+	root, rest, _ := strings.Cut(strings.TrimPrefix(req.URL.Path, "/"), "/")
+
+	var name, path string
+
+	switch root {
+
+	case "ws", "_info":
+		name, path = rest, "/"+root
+
+	case "oauth2":
+		var sub string
+		name, sub, _ = strings.Cut(rest, "/")
+		path = "/oauth2/" + sub
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	b, ok := p.backends[name]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	b.route(w, req, path)
+}
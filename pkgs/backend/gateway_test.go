@@ -0,0 +1,116 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.acuvity.ai/minibridge/pkgs/backend/client"
+	"go.acuvity.ai/minibridge/pkgs/frontend"
+)
+
+func TestNewGateway(t *testing.T) {
+
+	cl := client.NewStdio(client.MCPServer{Command: "cat"})
+
+	Convey("Given I create a gateway without servers", t, func() {
+		_, err := NewGateway("127.0.0.1:0", nil, nil)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "at least one server must be given")
+	})
+
+	Convey("Given I create a gateway with an invalid server name", t, func() {
+		_, err := NewGateway("127.0.0.1:0", nil, []GatewayServer{{Name: "a/b", Client: cl}})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "invalid server name 'a/b': must match ^[a-zA-Z0-9_.-]+$")
+	})
+
+	Convey("Given I create a gateway with duplicate server names", t, func() {
+		_, err := NewGateway("127.0.0.1:0", nil, []GatewayServer{{Name: "a", Client: cl}, {Name: "a", Client: cl}})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "duplicate server name 'a'")
+	})
+
+	Convey("Given I create a gateway with a server without client", t, func() {
+		_, err := NewGateway("127.0.0.1:0", nil, []GatewayServer{{Name: "a"}})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "server 'a' has no client")
+	})
+
+	Convey("Given I create a gateway with per server options", t, func() {
+		b, err := NewGateway("127.0.0.1:0", nil, []GatewayServer{
+			{Name: "a", Client: cl},
+			{Name: "b", Client: cl, Options: []Option{OptDumpStderrOnError(false)}},
+		}, OptDumpStderrOnError(true))
+		So(err, ShouldBeNil)
+
+		g := b.(*gatewayBackend)
+		So(g.backends["a"].cfg.dumpStderr, ShouldBeTrue)
+		So(g.backends["b"].cfg.dumpStderr, ShouldBeFalse)
+	})
+}
+
+func TestGateway(t *testing.T) {
+
+	Convey("Given a gateway with two servers", t, func() {
+
+		ctx, cancel := context.WithCancel(t.Context())
+		defer cancel()
+
+		listen := fmt.Sprintf("127.0.0.1:%d", freePort())
+
+		b, err := NewGateway(listen, nil, []GatewayServer{
+			{Name: "a", Client: client.NewStdio(client.MCPServer{Command: "cat"})},
+			{Name: "b", Client: client.NewStdio(client.MCPServer{Command: "tee"})},
+		})
+		So(err, ShouldBeNil)
+
+		go func() { _ = b.Start(ctx) }()
+		<-time.After(time.Second)
+
+		get := func(path string) (int, string) {
+			resp, err := http.Get(fmt.Sprintf("http://%s%s", listen, path))
+			So(err, ShouldBeNil)
+			defer func() { _ = resp.Body.Close() }()
+			data, _ := io.ReadAll(resp.Body)
+			return resp.StatusCode, string(data)
+		}
+
+		Convey("Then the info of each server should be correct", func() {
+			code, body := get("/_info/a")
+			So(code, ShouldEqual, http.StatusOK)
+			So(body, ShouldContainSubstring, `"server":"cat"`)
+
+			code, body = get("/_info/b")
+			So(code, ShouldEqual, http.StatusOK)
+			So(body, ShouldContainSubstring, `"server":"tee"`)
+		})
+
+		Convey("Then unknown paths should return 404", func() {
+			for _, path := range []string{"/_info/c", "/_info", "/ws", "/ws/a/b", "/oauth2/a/token", "/other"} {
+				code, _ := get(path)
+				So(code, ShouldEqual, http.StatusNotFound)
+			}
+		})
+
+		Convey("Then I should be able to talk to a server", func() {
+			ws, err := frontend.Connect(ctx, nil, fmt.Sprintf("ws://%s/ws/a", listen), nil, frontend.AgentInfo{UserAgent: "go-test"})
+			So(err, ShouldBeNil)
+
+			echo := `{"hello": "world"}`
+			ws.Write([]byte(echo))
+
+			var data []byte
+			select {
+			case data = <-ws.Read():
+			case <-time.After(time.Second):
+			}
+
+			So(string(data), ShouldEqual, echo)
+		})
+	})
+}
@@ -4,6 +4,8 @@ import (
 	"net"
 
 	"go.acuvity.ai/bahamut"
+	"go.acuvity.ai/minibridge/pkgs/approvals"
+	"go.acuvity.ai/minibridge/pkgs/egress"
 	"go.acuvity.ai/minibridge/pkgs/metrics"
 	"go.acuvity.ai/minibridge/pkgs/policer"
 	"go.acuvity.ai/minibridge/pkgs/scan"
@@ -12,13 +14,17 @@ import (
 )
 
 type wsCfg struct {
+	approvals       *approvals.Manager
 	corsPolicy      *bahamut.CORSPolicy
 	dumpStderr      bool
+	egressAllowlist *egress.Allowlist
 	listener        net.Listener
 	metricsManager  *metrics.Manager
 	policer         policer.Policer
 	policerEnforced bool
 	sbom            scan.SBOM
+	sharedServer    bool
+	toolTags        ToolTags
 	tracer          trace.Tracer
 }
 
@@ -96,3 +102,45 @@ func OptListener(listener net.Listener) Option {
 		cfg.listener = listener
 	}
 }
+
+// OptSharedServer makes all websocket sessions share a single
+// MCP server instance. Request IDs are rewritten per session and
+// responses are routed back to their owner. The MCP server is
+// started on the first connection without agent credentials,
+// so this is meant to be used with local MCP servers.
+func OptSharedServer(shared bool) Option {
+	return func(cfg *wsCfg) {
+		cfg.sharedServer = shared
+	}
+}
+
+// OptEgressAllowlist runs an egress proxy for each MCP server,
+// only allowing the destinations of the given allowlist. The
+// proxy is passed to the MCP server using the HTTP_PROXY and
+// HTTPS_PROXY environment variables, and every request is logged
+// with the session and the tool calls in flight.
+// This is ignored when using a remote MCP server.
+func OptEgressAllowlist(allowlist *egress.Allowlist) Option {
+	return func(cfg *wsCfg) {
+		cfg.egressAllowlist = allowlist
+	}
+}
+
+// OptToolTags sets the tags of the tools, used to track the
+// sessions tainted by sensitive data. Once a tool tagged
+// ToolTagSensitive returned content in a session, the tools
+// tagged ToolTagExternal are refused for the rest of it.
+func OptToolTags(tags ToolTags) Option {
+	return func(cfg *wsCfg) {
+		cfg.toolTags = tags
+	}
+}
+
+// OptApprovals sets the approvals.Manager used to hold the tool
+// calls pending approval until a human approves or denies them.
+// If not set, the calls pending approval are denied.
+func OptApprovals(manager *approvals.Manager) Option {
+	return func(cfg *wsCfg) {
+		cfg.approvals = manager
+	}
+}
@@ -7,6 +7,8 @@ import (
 
 	. "github.com/smartystreets/goconvey/convey"
 	"go.acuvity.ai/bahamut"
+	"go.acuvity.ai/minibridge/pkgs/approvals"
+	"go.acuvity.ai/minibridge/pkgs/egress"
 	"go.acuvity.ai/minibridge/pkgs/mcp"
 	"go.acuvity.ai/minibridge/pkgs/metrics"
 	"go.acuvity.ai/minibridge/pkgs/policer/api"
@@ -52,6 +54,13 @@ func TestOptions(t *testing.T) {
 		So(cfg.sbom, ShouldEqual, s)
 	})
 
+	Convey("OptEgressAllowlist should work", t, func() {
+		cfg := newWSCfg()
+		a, _ := egress.NewAllowlist("example.com")
+		OptEgressAllowlist(a)(&cfg)
+		So(cfg.egressAllowlist, ShouldEqual, a)
+	})
+
 	Convey("OptMetricsManager should work", t, func() {
 		cfg := newWSCfg()
 		mm := &metrics.Manager{}
@@ -85,4 +94,17 @@ func TestOptions(t *testing.T) {
 		OptPolicerEnforce(false)(&cfg)
 		So(cfg.policerEnforced, ShouldBeFalse)
 	})
+
+	Convey("OptApprovals should work", t, func() {
+		cfg := newWSCfg()
+		m := approvals.NewManager("127.0.0.1:0")
+		OptApprovals(m)(&cfg)
+		So(cfg.approvals, ShouldEqual, m)
+	})
+
+	Convey("OptSharedServer should work", t, func() {
+		cfg := newWSCfg()
+		OptSharedServer(true)(&cfg)
+		So(cfg.sharedServer, ShouldBeTrue)
+	})
 }
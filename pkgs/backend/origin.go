@@ -0,0 +1,66 @@
+package backend
+
+import (
+	"time"
+
+	"github.com/karlseguin/ccache/v3"
+	"go.acuvity.ai/minibridge/pkgs/mcp"
+	"go.acuvity.ai/minibridge/pkgs/policer/api"
+)
+
+const (
+	// originMaxSize is the maximum number of requests
+	// in flight tracked in each direction of a session.
+	originMaxSize = 1024
+
+	// originTTL is the time after which a request without
+	// response is not tracked anymore.
+	originTTL = 30 * time.Minute
+)
+
+// originTracker keeps track of the requests in flight in both directions
+// of a session, so the responses can be policed along with their request.
+type originTracker struct {
+	agent  *ccache.Cache[mcp.Message]
+	server *ccache.Cache[mcp.Message]
+}
+
+func newOriginTracker() *originTracker {
+	return &originTracker{
+		agent:  ccache.New(ccache.Configure[mcp.Message]().MaxSize(originMaxSize)),
+		server: ccache.New(ccache.Configure[mcp.Message]().MaxSize(originMaxSize)),
+	}
+}
+
+// observe records the given message if it is a request. If it is
+// a response, it returns the request it responds to, if known.
+func (t *originTracker) observe(rtype api.CallType, msg mcp.Message) (mcp.Message, bool) {
+
+	if msg.ID == nil {
+		return mcp.Message{}, false
+	}
+
+	requests, responded := t.agent, t.server
+	if rtype == api.CallTypeResponse {
+		requests, responded = t.server, t.agent
+	}
+
+	if msg.Method != "" {
+		requests.Set(msg.IDString(), msg, originTTL)
+		return mcp.Message{}, false
+	}
+
+	item := responded.Get(msg.IDString())
+	if item == nil || item.Expired() {
+		return mcp.Message{}, false
+	}
+
+	responded.Delete(msg.IDString())
+
+	return item.Value(), true
+}
+
+func (t *originTracker) close() {
+	t.agent.Stop()
+	t.server.Stop()
+}
@@ -0,0 +1,64 @@
+package backend
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.acuvity.ai/minibridge/pkgs/mcp"
+	"go.acuvity.ai/minibridge/pkgs/policer/api"
+)
+
+func TestOriginTracker(t *testing.T) {
+
+	Convey("Given I have an origin tracker", t, func() {
+
+		tracker := newOriginTracker()
+		defer tracker.close()
+
+		call := mcp.NewMessage(1)
+		call.Method = "tools/call"
+		call.Params = map[string]any{"name": "hello"}
+
+		resp := mcp.NewMessage(1)
+		resp.Result = map[string]any{}
+
+		Convey("When I observe a request from the agent", func() {
+
+			_, ok := tracker.observe(api.CallTypeRequest, call)
+			So(ok, ShouldBeFalse)
+
+			Convey("Then the response from the server should have the request as origin", func() {
+				origin, ok := tracker.observe(api.CallTypeResponse, resp)
+				So(ok, ShouldBeTrue)
+				So(origin, ShouldResemble, call)
+
+				_, ok = tracker.observe(api.CallTypeResponse, resp)
+				So(ok, ShouldBeFalse)
+			})
+
+			Convey("Then a response from the agent should not have an origin", func() {
+				_, ok := tracker.observe(api.CallTypeRequest, resp)
+				So(ok, ShouldBeFalse)
+			})
+		})
+
+		Convey("When I observe a request from the server", func() {
+
+			_, ok := tracker.observe(api.CallTypeResponse, call)
+			So(ok, ShouldBeFalse)
+
+			Convey("Then the response from the agent should have the request as origin", func() {
+				origin, ok := tracker.observe(api.CallTypeRequest, resp)
+				So(ok, ShouldBeTrue)
+				So(origin, ShouldResemble, call)
+			})
+		})
+
+		Convey("When I observe a notification", func() {
+			notif := mcp.NewMessage("")
+			notif.Method = "notifications/initialized"
+			_, ok := tracker.observe(api.CallTypeRequest, notif)
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
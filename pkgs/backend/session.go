@@ -0,0 +1,78 @@
+package backend
+
+import (
+	"log/slog"
+	"maps"
+
+	"go.acuvity.ai/minibridge/pkgs/mcp"
+	"go.acuvity.ai/minibridge/pkgs/policer/api"
+)
+
+// maxStateKeys is the maximum number
+// of keys in the state of a session.
+const maxStateKeys = 128
+
+// sessionTracker keeps the information about a session sent to the
+// policer, like the sequence number of the messages, the MCP server
+// and the state set by the policers.
+type sessionTracker struct {
+	session api.Session
+}
+
+func newSessionTracker(id string) *sessionTracker {
+	return &sessionTracker{
+		session: api.Session{ID: id},
+	}
+}
+
+// next counts the given message, and returns
+// the session to send to the policer with it.
+func (t *sessionTracker) next(rtype api.CallType, msg mcp.Message, origin mcp.Message) api.Session {
+
+	t.session.Sequence++
+
+	if rtype == api.CallTypeResponse && origin.Method == "initialize" && msg.Result != nil {
+
+		t.session.ProtocolVersion, _ = msg.Result["protocolVersion"].(string)
+
+		if info, ok := msg.Result["serverInfo"].(map[string]any); ok {
+			t.session.Server.Name, _ = info["name"].(string)
+			t.session.Server.Version, _ = info["version"].(string)
+		}
+	}
+
+	return t.current()
+}
+
+// current returns a copy of the session.
+func (t *sessionTracker) current() api.Session {
+
+	s := t.session
+	s.State = maps.Clone(s.State)
+
+	return s
+}
+
+// update sets the given values in the state
+// of the session. A nil value removes the key.
+func (t *sessionTracker) update(values map[string]any) {
+
+	for k, v := range values {
+
+		if v == nil {
+			delete(t.session.State, k)
+			continue
+		}
+
+		if _, ok := t.session.State[k]; !ok && len(t.session.State) >= maxStateKeys {
+			slog.Warn("Session state is full. Ignoring key", "session", t.session.ID, "key", k, "max", maxStateKeys)
+			continue
+		}
+
+		if t.session.State == nil {
+			t.session.State = map[string]any{}
+		}
+
+		t.session.State[k] = v
+	}
+}
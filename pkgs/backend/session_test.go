@@ -0,0 +1,85 @@
+package backend
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.acuvity.ai/minibridge/pkgs/mcp"
+	"go.acuvity.ai/minibridge/pkgs/policer/api"
+)
+
+func TestSessionTracker(t *testing.T) {
+
+	Convey("Given I have a session tracker", t, func() {
+
+		tracker := newSessionTracker("sid")
+
+		So(tracker.current(), ShouldResemble, api.Session{ID: "sid"})
+
+		Convey("When I observe the initialize call", func() {
+
+			init := mcp.NewMessage(1)
+			init.Method = "initialize"
+
+			s := tracker.next(api.CallTypeRequest, init, mcp.Message{})
+			So(s.Sequence, ShouldEqual, 1)
+			So(s.ProtocolVersion, ShouldBeEmpty)
+
+			resp := mcp.NewMessage(1)
+			resp.Result = map[string]any{
+				"protocolVersion": "2025-03-26",
+				"serverInfo":      map[string]any{"name": "everything", "version": "1.0.0"},
+			}
+
+			Convey("Then the session should contain the server information", func() {
+				s := tracker.next(api.CallTypeResponse, resp, init)
+				So(s, ShouldResemble, api.Session{
+					ID:              "sid",
+					Sequence:        2,
+					ProtocolVersion: "2025-03-26",
+					Server:          api.ServerInfo{Name: "everything", Version: "1.0.0"},
+				})
+			})
+
+			Convey("Then a response to another call should not change the server information", func() {
+				s := tracker.next(api.CallTypeResponse, resp, mcp.Message{Method: "tools/list"})
+				So(s.Sequence, ShouldEqual, 2)
+				So(s.ProtocolVersion, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When I update the state", func() {
+
+			tracker.update(map[string]any{"a": 1, "b": "x"})
+			tracker.update(map[string]any{"a": nil, "c": true})
+
+			Convey("Then the state should be updated", func() {
+				So(tracker.current().State, ShouldResemble, map[string]any{"b": "x", "c": true})
+			})
+
+			Convey("Then the returned sessions should not share the state", func() {
+				s := tracker.current()
+				s.State["d"] = 1
+				So(tracker.current().State, ShouldResemble, map[string]any{"b": "x", "c": true})
+			})
+		})
+
+		Convey("When I fill the state", func() {
+
+			for i := range maxStateKeys + 1 {
+				tracker.update(map[string]any{fmt.Sprintf("k%d", i): i})
+			}
+
+			Convey("Then the extra keys should be ignored", func() {
+				So(tracker.current().State, ShouldHaveLength, maxStateKeys)
+				So(tracker.current().State, ShouldNotContainKey, fmt.Sprintf("k%d", maxStateKeys))
+			})
+
+			Convey("Then the existing keys should still be updated", func() {
+				tracker.update(map[string]any{"k0": "updated"})
+				So(tracker.current().State["k0"], ShouldEqual, "updated")
+			})
+		})
+	})
+}
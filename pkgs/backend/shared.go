@@ -0,0 +1,425 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"go.acuvity.ai/minibridge/pkgs/backend/client"
+	"go.acuvity.ai/minibridge/pkgs/egress"
+)
+
+// sharedServer holds a single client.MCPStream that is shared
+// by all websocket sessions. The stream is started lazily with
+// the backend context, and restarted if the MCP server exits.
+type sharedServer struct {
+	ctx       context.Context
+	client    client.Client
+	allowlist *egress.Allowlist
+	proxy     *egress.Proxy
+
+	stream *client.MCPStream
+	dead   chan struct{}
+
+	initStarted bool
+	initDone    chan struct{}
+	initResult  json.RawMessage
+	initialized bool
+
+	lastActive string
+
+	sync.Mutex
+}
+
+func newSharedServer(ctx context.Context, cl client.Client, allowlist *egress.Allowlist) *sharedServer {
+	s := &sharedServer{
+		ctx:       ctx,
+		client:    cl,
+		allowlist: allowlist,
+	}
+
+	s.reset()
+
+	return s
+}
+
+// reset clears the initialization state.
+// The caller must hold the lock.
+func (s *sharedServer) reset() {
+	s.initStarted = false
+	s.initDone = make(chan struct{})
+	s.initResult = nil
+	s.initialized = false
+}
+
+// get returns the shared MCPStream, starting it if needed, as well as a
+// channel that will be closed when this stream is not usable anymore.
+func (s *sharedServer) get() (*client.MCPStream, chan struct{}, error) {
+
+	s.Lock()
+	defer s.Unlock()
+
+	if s.stream != nil {
+		return s.stream, s.dead, nil
+	}
+
+	var opts []client.Option
+
+	if s.allowlist != nil {
+
+		// The proxy is shared by all the sessions, which
+		// are identified by the tool calls in the audit log.
+		if s.proxy == nil {
+			proxy := egress.NewProxy(s.allowlist, "")
+			if err := proxy.Start(s.ctx); err != nil {
+				return nil, nil, err
+			}
+			s.proxy = proxy
+		}
+
+		opts = append(opts, client.OptionEnv(s.proxy.Env()...))
+	}
+
+	stream, err := s.client.Start(s.ctx, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	exit, unregister := stream.Exit()
+	dead := make(chan struct{})
+
+	s.stream = stream
+	s.dead = dead
+	s.reset()
+
+	go func() {
+
+		defer unregister()
+
+		<-exit
+
+		s.Lock()
+		if s.stream == stream {
+			s.stream = nil
+		}
+		s.Unlock()
+
+		close(dead)
+	}()
+
+	return stream, dead, nil
+}
+
+// touch marks the given session as the last active one.
+// Requests initiated by the MCP server are sent to that session.
+func (s *sharedServer) touch(sid string) {
+	s.Lock()
+	s.lastActive = sid
+	s.Unlock()
+}
+
+func (s *sharedServer) isLastActive(sid string) bool {
+	s.Lock()
+	defer s.Unlock()
+	return s.lastActive == sid
+}
+
+// beginInit returns true if the caller is the first session to initialize
+// the MCP server. In any case, it returns a channel that is closed once
+// the initialize response has been received.
+func (s *sharedServer) beginInit() (bool, chan struct{}) {
+
+	s.Lock()
+	defer s.Unlock()
+
+	if s.initStarted {
+		return false, s.initDone
+	}
+
+	s.initStarted = true
+
+	return true, s.initDone
+}
+
+// completeInit stores the result of the initialize response. If the
+// MCP server returned an error, the next session will try again.
+func (s *sharedServer) completeInit(result json.RawMessage) {
+
+	s.Lock()
+	defer s.Unlock()
+
+	if s.initResult != nil {
+		return
+	}
+
+	s.initResult = result
+	close(s.initDone)
+
+	if result == nil {
+		s.initStarted = false
+		s.initDone = make(chan struct{})
+	}
+}
+
+func (s *sharedServer) cachedInit() json.RawMessage {
+	s.Lock()
+	defer s.Unlock()
+	return s.initResult
+}
+
+// markInitialized returns true if this is the first time
+// notifications/initialized is sent to the MCP server.
+func (s *sharedServer) markInitialized() bool {
+
+	s.Lock()
+	defer s.Unlock()
+
+	if s.initialized {
+		return false
+	}
+
+	s.initialized = true
+
+	return true
+}
+
+type pendingCall struct {
+	id     json.RawMessage
+	method string
+}
+
+// sharedSession tracks the requests a websocket session
+// sent to a shared MCP server, so responses can be routed
+// back to their owner with their original ID.
+type sharedSession struct {
+	id      string
+	server  *sharedServer
+	counter int
+	pending map[string]pendingCall
+	replies chan []byte
+
+	sync.Mutex
+}
+
+func newSharedSession(server *sharedServer, id string) *sharedSession {
+	return &sharedSession{
+		id:      id,
+		server:  server,
+		pending: map[string]pendingCall{},
+		replies: make(chan []byte, 8),
+	}
+}
+
+// outbound processes a message sent by the agent. It returns the
+// data to send to the MCP server, or nil if the message must not
+// be forwarded. Replies that the session must send to the agent
+// without involving the MCP server are sent to s.replies.
+func (s *sharedSession) outbound(ctx context.Context, data []byte) ([]byte, error) {
+
+	msg := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return data, nil
+	}
+
+	s.server.touch(s.id)
+
+	var method string
+	_ = json.Unmarshal(msg["method"], &method)
+
+	id, hasID := msg["id"]
+	hasID = hasID && string(id) != "null"
+
+	switch {
+
+	case method == "initialize" && hasID:
+
+		first, done := s.server.beginInit()
+		if !first {
+			go s.replyInit(ctx, id, done)
+			return nil, nil
+		}
+
+	case method == "notifications/initialized":
+
+		if !s.server.markInitialized() {
+			return nil, nil
+		}
+		return data, nil
+
+	case method == "notifications/cancelled":
+
+		params := map[string]json.RawMessage{}
+		if err := json.Unmarshal(msg["params"], &params); err != nil {
+			return data, nil
+		}
+
+		if nid, ok := s.rewrittenID(params["requestId"]); ok {
+			params["requestId"] = nid
+			msg["params"], _ = json.Marshal(params)
+			return json.Marshal(msg)
+		}
+
+		return data, nil
+
+	case method == "" || !hasID:
+
+		// This is a response to a server initiated request, or a
+		// notification. They are forwarded as is.
+		return data, nil
+	}
+
+	s.Lock()
+	s.counter++
+	nid := fmt.Sprintf("%s:%d", s.id, s.counter)
+	s.pending[nid] = pendingCall{id: id, method: method}
+	s.Unlock()
+
+	msg["id"], _ = json.Marshal(nid)
+
+	out, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode rewritten mcp call: %w", err)
+	}
+
+	return out, nil
+}
+
+// inbound processes a message sent by the MCP server. It returns the data to
+// send to the agent with its original ID restored, and false if the message
+// does not concern this session.
+func (s *sharedSession) inbound(data []byte) ([]byte, bool, error) {
+
+	msg := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return data, true, nil
+	}
+
+	id, hasID := msg["id"]
+	hasID = hasID && string(id) != "null"
+
+	// Notifications are sent to everyone.
+	if !hasID {
+		return data, true, nil
+	}
+
+	// Requests from the server are sent to the last active session.
+	if _, isRequest := msg["method"]; isRequest {
+		return data, s.server.isLastActive(s.id), nil
+	}
+
+	var nid string
+	if err := json.Unmarshal(id, &nid); err != nil || !strings.HasPrefix(nid, s.id+":") {
+		return nil, false, nil
+	}
+
+	s.Lock()
+	call, ok := s.pending[nid]
+	delete(s.pending, nid)
+	s.Unlock()
+
+	if !ok {
+		return nil, false, nil
+	}
+
+	if call.method == "initialize" {
+		if _, isErr := msg["error"]; isErr {
+			s.server.completeInit(nil)
+		} else {
+			s.server.completeInit(msg["result"])
+		}
+	}
+
+	msg["id"] = call.id
+
+	out, err := json.Marshal(msg)
+	if err != nil {
+		return nil, false, fmt.Errorf("unable to encode restored mcp call: %w", err)
+	}
+
+	return out, true, nil
+}
+
+// close cancels all the requests that are still
+// waiting for a response from the MCP server.
+func (s *sharedSession) close(stream *client.MCPStream, dead chan struct{}) {
+
+	s.Lock()
+	defer s.Unlock()
+
+	for nid, call := range s.pending {
+
+		// Let another session initialize the server.
+		if call.method == "initialize" {
+			s.server.completeInit(nil)
+		}
+
+		data, err := json.Marshal(map[string]any{
+			"jsonrpc": "2.0",
+			"method":  "notifications/cancelled",
+			"params": map[string]any{
+				"requestId": nid,
+				"reason":    "agent disconnected",
+			},
+		})
+		if err != nil {
+			continue
+		}
+
+		select {
+		case stream.Stdin() <- data:
+		case <-dead:
+			return
+		case <-s.server.ctx.Done():
+			return
+		}
+	}
+
+	clear(s.pending)
+}
+
+func (s *sharedSession) rewrittenID(id json.RawMessage) (json.RawMessage, bool) {
+
+	s.Lock()
+	defer s.Unlock()
+
+	for nid, call := range s.pending {
+		if string(call.id) == string(id) {
+			out, _ := json.Marshal(nid)
+			return out, true
+		}
+	}
+
+	return nil, false
+}
+
+func (s *sharedSession) replyInit(ctx context.Context, id json.RawMessage, done chan struct{}) {
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return
+	}
+
+	resp := map[string]json.RawMessage{
+		"jsonrpc": json.RawMessage(`"2.0"`),
+		"id":      id,
+	}
+
+	if result := s.server.cachedInit(); result != nil {
+		resp["result"] = result
+	} else {
+		resp["error"] = json.RawMessage(`{"code":-32603,"message":"shared mcp server failed to initialize"}`)
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return
+	}
+
+	select {
+	case s.replies <- data:
+	case <-ctx.Done():
+	}
+}
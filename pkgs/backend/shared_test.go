@@ -0,0 +1,179 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.acuvity.ai/minibridge/pkgs/backend/client"
+	"go.acuvity.ai/minibridge/pkgs/frontend"
+	"go.acuvity.ai/wsc"
+)
+
+func TestSharedSession(t *testing.T) {
+
+	Convey("Given I have two sessions on a shared server", t, func() {
+
+		srv := newSharedServer(t.Context(), nil, nil)
+		s1 := newSharedSession(srv, "s1")
+		s2 := newSharedSession(srv, "s2")
+
+		Convey("Requests should be rewritten and routed back to their owner", func() {
+
+			out1, err := s1.outbound(t.Context(), []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+			So(err, ShouldBeNil)
+			So(string(out1), ShouldEqual, fmt.Sprintf(`{"id":"%s:1","jsonrpc":"2.0","method":"tools/list"}`, s1.id))
+
+			out2, err := s2.outbound(t.Context(), []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+			So(err, ShouldBeNil)
+			So(string(out2), ShouldEqual, fmt.Sprintf(`{"id":"%s:1","jsonrpc":"2.0","method":"tools/list"}`, s2.id))
+
+			resp := fmt.Appendf(nil, `{"jsonrpc":"2.0","id":"%s:1","result":{}}`, s1.id)
+
+			data, ok, err := s2.inbound(resp)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+			So(data, ShouldBeNil)
+
+			data, ok, err = s1.inbound(resp)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			So(string(data), ShouldEqual, `{"id":1,"jsonrpc":"2.0","result":{}}`)
+
+			data, ok, err = s1.inbound(resp)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+			So(data, ShouldBeNil)
+		})
+
+		Convey("Notifications should be sent to everyone", func() {
+
+			notif := []byte(`{"jsonrpc":"2.0","method":"notifications/tools/list_changed"}`)
+
+			data, ok, err := s1.inbound(notif)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			So(data, ShouldResemble, notif)
+
+			data, ok, err = s2.inbound(notif)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			So(data, ShouldResemble, notif)
+		})
+
+		Convey("Server requests should be sent to the last active session", func() {
+
+			_, _ = s1.outbound(t.Context(), []byte(`{"jsonrpc":"2.0","method":"notifications/whatever"}`))
+			_, _ = s2.outbound(t.Context(), []byte(`{"jsonrpc":"2.0","method":"notifications/whatever"}`))
+
+			req := []byte(`{"jsonrpc":"2.0","id":42,"method":"sampling/createMessage"}`)
+
+			_, ok, _ := s1.inbound(req)
+			So(ok, ShouldBeFalse)
+
+			_, ok, _ = s2.inbound(req)
+			So(ok, ShouldBeTrue)
+		})
+
+		Convey("Cancellations should use the rewritten ID", func() {
+
+			_, _ = s1.outbound(t.Context(), []byte(`{"jsonrpc":"2.0","id":"a","method":"tools/call"}`))
+
+			out, err := s1.outbound(t.Context(), []byte(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":"a"}}`))
+			So(err, ShouldBeNil)
+			So(string(out), ShouldEqual, fmt.Sprintf(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":"%s:1"}}`, s1.id))
+		})
+
+		Convey("Initialize should be answered from cache after the first one", func() {
+
+			out, err := s1.outbound(t.Context(), []byte(`{"jsonrpc":"2.0","id":0,"method":"initialize"}`))
+			So(err, ShouldBeNil)
+			So(out, ShouldNotBeNil)
+
+			out, err = s2.outbound(t.Context(), []byte(`{"jsonrpc":"2.0","id":"init","method":"initialize"}`))
+			So(err, ShouldBeNil)
+			So(out, ShouldBeNil)
+
+			data, ok, err := s1.inbound(fmt.Appendf(nil, `{"jsonrpc":"2.0","id":"%s:1","result":{"protocolVersion":"2025-03-26"}}`, s1.id))
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			So(string(data), ShouldEqual, `{"id":0,"jsonrpc":"2.0","result":{"protocolVersion":"2025-03-26"}}`)
+
+			var reply []byte
+			select {
+			case reply = <-s2.replies:
+			case <-time.After(time.Second):
+			}
+			So(string(reply), ShouldEqual, `{"id":"init","jsonrpc":"2.0","result":{"protocolVersion":"2025-03-26"}}`)
+
+			out, err = s1.outbound(t.Context(), []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`))
+			So(err, ShouldBeNil)
+			So(out, ShouldNotBeNil)
+
+			out, err = s2.outbound(t.Context(), []byte(`{"jsonrpc":"2.0","method":"notifications/initialized"}`))
+			So(err, ShouldBeNil)
+			So(out, ShouldBeNil)
+		})
+
+		Convey("A failed initialize should not be cached", func() {
+
+			_, _ = s1.outbound(t.Context(), []byte(`{"jsonrpc":"2.0","id":1,"method":"initialize"}`))
+			_, _, _ = s1.inbound(fmt.Appendf(nil, `{"jsonrpc":"2.0","id":"%s:1","error":{"code":500,"message":"nope"}}`, s1.id))
+
+			out, err := s2.outbound(t.Context(), []byte(`{"jsonrpc":"2.0","id":1,"method":"initialize"}`))
+			So(err, ShouldBeNil)
+			So(out, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestSharedServer(t *testing.T) {
+
+	Convey("Given a ws backend with a shared server", t, func() {
+
+		ctx, cancel := context.WithCancel(t.Context())
+		defer cancel()
+
+		backendListen := fmt.Sprintf("127.0.0.1:%d", freePort())
+
+		// This server turns every request into a response.
+		srv, err := client.NewMCPServer("sed", "-u", `s/"method":"[^"]*"/"result":{}/`)
+		So(err, ShouldBeNil)
+
+		backend := NewWebSocket(backendListen, nil, client.NewStdio(srv), OptSharedServer(true))
+		go func() { _ = backend.Start(ctx) }()
+
+		<-time.After(time.Second)
+
+		ws1, err := frontend.Connect(ctx, nil, fmt.Sprintf("ws://%s/ws", backendListen), nil, frontend.AgentInfo{UserAgent: "go-test"})
+		So(err, ShouldBeNil)
+
+		ws2, err := frontend.Connect(ctx, nil, fmt.Sprintf("ws://%s/ws", backendListen), nil, frontend.AgentInfo{UserAgent: "go-test"})
+		So(err, ShouldBeNil)
+
+		ws1.Write([]byte(`{"jsonrpc":"2.0","id":1,"method":"ping"}`))
+		ws2.Write([]byte(`{"jsonrpc":"2.0","id":1,"method":"ping"}`))
+
+		for _, ws := range []wsc.Websocket{ws1, ws2} {
+
+			var data []byte
+			select {
+			case data = <-ws.Read():
+			case <-time.After(2 * time.Second):
+			}
+
+			So(string(data), ShouldEqual, `{"id":1,"jsonrpc":"2.0","result":{}}`)
+
+			select {
+			case data = <-ws.Read():
+			case <-time.After(300 * time.Millisecond):
+				data = nil
+			}
+
+			So(strings.TrimSpace(string(data)), ShouldBeEmpty)
+		}
+	})
+}
@@ -0,0 +1,128 @@
+package backend
+
+import (
+	"fmt"
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+
+	"go.acuvity.ai/minibridge/pkgs/mcp"
+	"go.acuvity.ai/minibridge/pkgs/policer/api"
+)
+
+// Various tool tags.
+const (
+	// ToolTagSensitive marks the tools reading sensitive data.
+	// A session is tainted once such a tool returned content.
+	ToolTagSensitive = "sensitive"
+
+	// ToolTagExternal marks the tools having external
+	// side effects. They are refused in tainted sessions.
+	ToolTagExternal = "external"
+)
+
+// ToolTags holds the tags of the tools, keyed
+// by tool name pattern, like 'github_*'.
+type ToolTags map[string][]string
+
+// ParseToolTags returns the ToolTags described by the given
+// entries, in the form 'pattern=tag'. The patterns use the
+// syntax of path.Match.
+func ParseToolTags(entries ...string) (ToolTags, error) {
+
+	tags := ToolTags{}
+
+	for _, e := range entries {
+
+		pattern, tag, ok := strings.Cut(e, "=")
+		if !ok || pattern == "" {
+			return nil, fmt.Errorf("invalid tool tag '%s': must be in the form 'pattern=tag'", e)
+		}
+
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid tool tag '%s': %w", e, err)
+		}
+
+		if tag != ToolTagSensitive && tag != ToolTagExternal {
+			return nil, fmt.Errorf("invalid tool tag '%s': tag must be '%s' or '%s'", e, ToolTagSensitive, ToolTagExternal)
+		}
+
+		if !slices.Contains(tags[pattern], tag) {
+			tags[pattern] = append(tags[pattern], tag)
+		}
+	}
+
+	return tags, nil
+}
+
+// Has returns true if the given tool has the given tag.
+func (t ToolTags) Has(tool string, tag string) bool {
+
+	for pattern, tags := range t {
+		if ok, _ := path.Match(pattern, tool); ok && slices.Contains(tags, tag) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// taintTracker tracks if a session has been tainted by a tool
+// reading sensitive data, and refuses the tools having external
+// side effects once it is, so the sensitive data cannot leave.
+// All methods can be called on a nil taintTracker.
+type taintTracker struct {
+	tags   ToolTags
+	source string
+}
+
+func newTaintTracker(tags ToolTags) *taintTracker {
+
+	if len(tags) == 0 {
+		return nil
+	}
+
+	return &taintTracker{
+		tags: tags,
+	}
+}
+
+// check returns an error wrapping api.ErrBlocked if the given
+// request calls a tool having external side effects while the
+// session is tainted.
+func (t *taintTracker) check(msg mcp.Message) error {
+
+	if t == nil || t.source == "" || msg.Method != "tools/call" {
+		return nil
+	}
+
+	name, _ := msg.Params["name"].(string)
+	if !t.tags.Has(name, ToolTagExternal) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: tool '%s' has external side effects and the session read sensitive data from tool '%s'", api.ErrBlocked, name, t.source)
+}
+
+// observe taints the session if the given response
+// is the content returned by a sensitive tool.
+func (t *taintTracker) observe(msg mcp.Message, origin mcp.Message) {
+
+	if t == nil || t.source != "" || origin.Method != "tools/call" || msg.Result == nil {
+		return
+	}
+
+	if isError, _ := msg.Result["isError"].(bool); isError {
+		return
+	}
+
+	name, _ := origin.Params["name"].(string)
+	if !t.tags.Has(name, ToolTagSensitive) {
+		return
+	}
+
+	slog.Info("Session tainted by sensitive tool", "tool", name)
+
+	t.source = name
+}
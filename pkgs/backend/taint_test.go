@@ -0,0 +1,115 @@
+package backend
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.acuvity.ai/minibridge/pkgs/mcp"
+	"go.acuvity.ai/minibridge/pkgs/policer/api"
+)
+
+func TestParseToolTags(t *testing.T) {
+
+	Convey("Given I parse valid tool tags", t, func() {
+		tags, err := ParseToolTags("read_*=sensitive", "post_web=external", "read_*=external", "read_*=sensitive")
+		So(err, ShouldBeNil)
+		So(tags, ShouldResemble, ToolTags{
+			"read_*":   {ToolTagSensitive, ToolTagExternal},
+			"post_web": {ToolTagExternal},
+		})
+		So(tags.Has("read_repo", ToolTagSensitive), ShouldBeTrue)
+		So(tags.Has("read_repo", ToolTagExternal), ShouldBeTrue)
+		So(tags.Has("post_web", ToolTagSensitive), ShouldBeFalse)
+		So(tags.Has("list_repos", ToolTagSensitive), ShouldBeFalse)
+	})
+
+	Convey("Given I parse invalid tool tags", t, func() {
+
+		_, err := ParseToolTags("read_repo")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "invalid tool tag 'read_repo': must be in the form 'pattern=tag'")
+
+		_, err = ParseToolTags("read_repo=secret")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "invalid tool tag 'read_repo=secret': tag must be 'sensitive' or 'external'")
+
+		_, err = ParseToolTags("read_[=sensitive")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "invalid tool tag 'read_[=sensitive': syntax error in pattern")
+	})
+}
+
+func TestTaintTracker(t *testing.T) {
+
+	call := func(name string) mcp.Message {
+		msg := mcp.NewMessage(1)
+		msg.Method = "tools/call"
+		msg.Params = map[string]any{"name": name}
+		return msg
+	}
+
+	result := func(r map[string]any) mcp.Message {
+		msg := mcp.NewMessage(1)
+		msg.Result = r
+		return msg
+	}
+
+	Convey("Given I have a nil taint tracker", t, func() {
+		tracker := newTaintTracker(nil)
+		So(tracker, ShouldBeNil)
+		tracker.observe(result(map[string]any{}), call("read_repo"))
+		So(tracker.check(call("post_web")), ShouldBeNil)
+	})
+
+	Convey("Given I have a taint tracker", t, func() {
+
+		tags, err := ParseToolTags("read_*=sensitive", "post_web=external")
+		So(err, ShouldBeNil)
+
+		tracker := newTaintTracker(tags)
+
+		Convey("Then external tools should be allowed while the session is not tainted", func() {
+			So(tracker.check(call("post_web")), ShouldBeNil)
+		})
+
+		Convey("When a sensitive tool returns an error", func() {
+
+			tracker.observe(result(map[string]any{"isError": true}), call("read_repo"))
+
+			msg := mcp.NewMessage(1)
+			msg.Error = &mcp.Error{Code: 500, Message: "nope"}
+			tracker.observe(msg, call("read_repo"))
+
+			Convey("Then external tools should be allowed", func() {
+				So(tracker.check(call("post_web")), ShouldBeNil)
+			})
+		})
+
+		Convey("When a non sensitive tool returns content", func() {
+
+			tracker.observe(result(map[string]any{"content": []any{}}), call("list_repos"))
+
+			Convey("Then external tools should be allowed", func() {
+				So(tracker.check(call("post_web")), ShouldBeNil)
+			})
+		})
+
+		Convey("When a sensitive tool returns content", func() {
+
+			tracker.observe(result(map[string]any{"content": []any{}}), call("read_repo"))
+			tracker.observe(result(map[string]any{"content": []any{}}), call("read_file"))
+
+			Convey("Then external tools should be refused", func() {
+				err := tracker.check(call("post_web"))
+				So(err, ShouldNotBeNil)
+				So(errors.Is(err, api.ErrBlocked), ShouldBeTrue)
+				So(err.Error(), ShouldEqual, "request blocked: tool 'post_web' has external side effects and the session read sensitive data from tool 'read_repo'")
+			})
+
+			Convey("Then other tools should be allowed", func() {
+				So(tracker.check(call("read_file")), ShouldBeNil)
+			})
+		})
+	})
+}
@@ -14,11 +14,13 @@ import (
 	"time"
 
 	"github.com/go-viper/mapstructure/v2"
+	"github.com/gofrs/uuid"
 	"github.com/gorilla/websocket"
 	"github.com/karlseguin/ccache/v3"
 	"github.com/smallnest/ringbuffer"
 	"go.acuvity.ai/elemental"
 	"go.acuvity.ai/minibridge/pkgs/backend/client"
+	"go.acuvity.ai/minibridge/pkgs/egress"
 	"go.acuvity.ai/minibridge/pkgs/info"
 	"go.acuvity.ai/minibridge/pkgs/internal/cors"
 	"go.acuvity.ai/minibridge/pkgs/internal/sanitize"
@@ -42,6 +44,7 @@ type wsBackend struct {
 	client    client.Client
 	listen    string
 	tlsConfig *tls.Config
+	shared    *sharedServer
 }
 
 // NewWebSocket retrurns a new backend.Backend exposing a Websocket to communicate
@@ -72,56 +75,14 @@ func NewWebSocket(listen string, tlsConfig *tls.Config, client client.Client, op
 // Start starts the server and will block until the given
 // context is canceled.
 func (p *wsBackend) Start(ctx context.Context) (err error) {
+	return serve(ctx, p.server, p.listen, p.tlsConfig, p.cfg, p.init)
+}
 
-	errCh := make(chan error, 1)
-
-	sctx, cancel := context.WithCancel(ctx)
-	defer cancel()
-
-	p.server.BaseContext = func(net.Listener) context.Context { return sctx }
-	p.server.RegisterOnShutdown(func() { cancel() })
-
-	if mm := p.cfg.metricsManager; mm != nil {
-		p.server.ConnState = func(conn net.Conn, state http.ConnState) {
-			switch state {
-			case http.StateNew:
-				mm.RegisterTCPConnection()
-			case http.StateClosed, http.StateHijacked:
-				mm.UnregisterTCPConnection()
-			}
-		}
-	}
-
-	listener := p.cfg.listener
-	if listener == nil {
-		if listener, err = net.Listen("tcp", p.listen); err != nil {
-			return fmt.Errorf("unable to start listener: %w", err)
-		}
-	}
-	if p.tlsConfig != nil {
-		listener = tls.NewListener(listener, p.tlsConfig)
-	}
-
-	go func() {
-		err := p.server.Serve(listener)
-		if err != nil {
-			if !errors.Is(err, http.ErrServerClosed) {
-				slog.Error("unable to start server", "err", err)
-			}
-		}
-		errCh <- err
-	}()
-
-	select {
-	case <-sctx.Done():
-	case err := <-errCh:
-		return err
+// init prepares the backend once the server context is known.
+func (p *wsBackend) init(ctx context.Context) {
+	if p.cfg.sharedServer {
+		p.shared = newSharedServer(ctx, p.client, p.cfg.egressAllowlist)
 	}
-
-	stopCtx, stopCancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer stopCancel()
-
-	return p.server.Shutdown(stopCtx)
 }
 
 func (p *wsBackend) ServeHTTP(w http.ResponseWriter, req *http.Request) {
@@ -130,7 +91,14 @@ func (p *wsBackend) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	switch req.URL.Path {
+	p.route(w, req, req.URL.Path)
+}
+
+// route serves the request for the given path,
+// relative to the root of the backend.
+func (p *wsBackend) route(w http.ResponseWriter, req *http.Request, path string) {
+
+	switch path {
 
 	case "/ws":
 		p.handleWS(w, req)
@@ -143,7 +111,7 @@ func (p *wsBackend) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 
 	if r, ok := p.client.(client.RemoteClient); ok {
 
-		switch req.URL.Path {
+		switch path {
 
 		case "/oauth2/.well-known/oauth-authorization-server":
 			defer oauth.Forward(r.BaseURL(), r.HTTPClient(), w, req, "/.well-known/oauth-authorization-server")()
@@ -166,6 +134,64 @@ func (p *wsBackend) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	w.WriteHeader(http.StatusNotFound)
 }
 
+// serve starts the given server and blocks until the given context
+// is canceled. The init function is called with the context of the
+// server before it starts accepting connections.
+func serve(ctx context.Context, server *http.Server, listen string, tlsConfig *tls.Config, cfg wsCfg, init func(context.Context)) (err error) {
+
+	errCh := make(chan error, 1)
+
+	sctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	server.BaseContext = func(net.Listener) context.Context { return sctx }
+
+	init(sctx)
+	server.RegisterOnShutdown(func() { cancel() })
+
+	if mm := cfg.metricsManager; mm != nil {
+		server.ConnState = func(conn net.Conn, state http.ConnState) {
+			switch state {
+			case http.StateNew:
+				mm.RegisterTCPConnection()
+			case http.StateClosed, http.StateHijacked:
+				mm.UnregisterTCPConnection()
+			}
+		}
+	}
+
+	listener := cfg.listener
+	if listener == nil {
+		if listener, err = net.Listen("tcp", listen); err != nil {
+			return fmt.Errorf("unable to start listener: %w", err)
+		}
+	}
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+
+	go func() {
+		err := server.Serve(listener)
+		if err != nil {
+			if !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("unable to start server", "err", err)
+			}
+		}
+		errCh <- err
+	}()
+
+	select {
+	case <-sctx.Done():
+	case err := <-errCh:
+		return err
+	}
+
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer stopCancel()
+
+	return server.Shutdown(stopCtx)
+}
+
 func (p *wsBackend) handleInfo(w http.ResponseWriter, req *http.Request) {
 
 	if req.Method != http.MethodGet {
@@ -236,17 +262,75 @@ func (p *wsBackend) handleWS(w http.ResponseWriter, req *http.Request) {
 
 	auth, hasAuth := parseBasicAuth(req.Header.Get("Authorization"))
 
-	stream, err := p.client.Start(ctx, client.OptionAuth(auth))
+	agent := api.Agent{
+		RemoteAddr: req.Header.Get("X-Forwarded-For"),
+		UserAgent:  req.Header.Get("X-Forwarded-UA"),
+	}
 
-	stdout, unregisterOut := stream.Stdout()
-	stderr, unregisterErr := stream.Stderr()
-	exit, unregisterExit := stream.Exit()
+	if hasAuth {
+		agent.User = auth.User()
+		agent.Password = auth.Password()
+	}
+
+	sessionID := uuid.Must(uuid.NewV7()).String()
+	sessions := newSessionTracker(sessionID)
+
+	// The agent must be allowed to start a session
+	// before we start an MCP server for it.
+	if err := p.policeSession(ctx, api.CallTypeSessionStart, agent, sessions); err != nil {
+
+		if errors.Is(err, api.ErrBlocked) {
+			hErr(w, fmt.Sprintf("session denied: %s", err), http.StatusForbidden, span)
+			m(http.StatusForbidden)
+			return
+		}
+
+		if errors.Is(err, api.ErrUnavailable) {
+			hErr(w, fmt.Sprintf("session denied: %s", err), http.StatusServiceUnavailable, span)
+			m(http.StatusServiceUnavailable)
+			return
+		}
+
+		slog.Error("Unable to police session start", "err", err)
+		hErr(w, fmt.Sprintf("unable to police session: %s", err), http.StatusInternalServerError, span)
+		m(http.StatusInternalServerError)
+		return
+	}
+
+	// Once started, the session must be ended for the policer,
+	// even if we fail to start the MCP server or to upgrade.
 	defer func() {
-		unregisterOut()
-		unregisterErr()
-		unregisterExit()
+		sctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer cancel()
+		if err := p.policeSession(sctx, api.CallTypeSessionEnd, agent, sessions); err != nil {
+			slog.Debug("Unable to police session end", "err", err)
+		}
 	}()
 
+	var stream *client.MCPStream
+	var dead chan struct{}
+	var proxy *egress.Proxy
+	var err error
+
+	if p.shared != nil {
+		stream, dead, err = p.shared.get()
+		proxy = p.shared.proxy
+	} else {
+
+		opts := []client.Option{client.OptionAuth(auth)}
+
+		if _, remote := p.client.(client.RemoteClient); !remote && p.cfg.egressAllowlist != nil {
+			proxy = egress.NewProxy(p.cfg.egressAllowlist, sessionID)
+			if err = proxy.Start(ctx); err == nil {
+				opts = append(opts, client.OptionEnv(proxy.Env()...))
+			}
+		}
+
+		if err == nil {
+			stream, err = p.client.Start(ctx, opts...)
+		}
+	}
+
 	if err != nil {
 
 		if errors.Is(err, client.ErrAuthRequired) {
@@ -255,12 +339,27 @@ func (p *wsBackend) handleWS(w http.ResponseWriter, req *http.Request) {
 			return
 		}
 
+		if errors.Is(err, client.ErrPoolExhausted) {
+			hErr(w, fmt.Sprintf("unable to start mcp client: %s", err), http.StatusServiceUnavailable, span)
+			m(http.StatusServiceUnavailable)
+			return
+		}
+
 		slog.Error("Unable to start mcp client", "type", p.client.Type(), err)
 		hErr(w, fmt.Sprintf("unable to start mcp client: %s", err), http.StatusInternalServerError, span)
 		m(http.StatusInternalServerError)
 		return
 	}
 
+	stdout, unregisterOut := stream.Stdout()
+	stderr, unregisterErr := stream.Stderr()
+	exit, unregisterExit := stream.Exit()
+	defer func() {
+		unregisterOut()
+		unregisterErr()
+		unregisterExit()
+	}()
+
 	select {
 	default:
 	case err := <-exit:
@@ -298,17 +397,51 @@ func (p *wsBackend) handleWS(w http.ResponseWriter, req *http.Request) {
 
 	rb := ringbuffer.New(4096)
 
-	agent := api.Agent{
-		RemoteAddr: req.Header.Get("X-Forwarded-For"),
-		UserAgent:  req.Header.Get("X-Forwarded-UA"),
+	session := &wsSession{
+		backend:  p,
+		ws:       ws,
+		agent:    agent,
+		sessions: sessions,
+		cache:    ccache.New(ccache.Configure[context.Context]().MaxSize(64)),
+		origins:  newOriginTracker(),
+		taint:    newTaintTracker(p.cfg.toolTags),
+		held:     newApprovalTracker(p.cfg.approvals),
 	}
+	defer session.origins.close()
 
-	if hasAuth {
-		agent.User = auth.User()
-		agent.Password = auth.Password()
+	var shared *sharedSession
+	var replies chan []byte
+	if p.shared != nil {
+		shared = newSharedSession(p.shared, sessionID)
+		replies = shared.replies
+		defer shared.close(stream, dead)
 	}
 
-	cache := ccache.New(ccache.Configure[context.Context]().MaxSize(64))
+	tracker := newEgressTracker(proxy, sessionID)
+	defer tracker.close()
+
+	// forward sends the given agent message to the MCP server.
+	// Nil data means the message must not be forwarded.
+	forward := func(data []byte) {
+
+		if data == nil {
+			return
+		}
+
+		tracker.outbound(data)
+
+		if shared != nil {
+			if data, err = shared.outbound(ctx, data); err != nil {
+				slog.Error("Unable to rewrite mcp agent message", "err", err)
+				return
+			}
+			if data == nil {
+				return
+			}
+		}
+
+		stream.Stdin() <- sanitize.Data(data)
+	}
 
 	for {
 
@@ -318,24 +451,70 @@ func (p *wsBackend) handleWS(w http.ResponseWriter, req *http.Request) {
 
 			slog.Debug("Received data from websocket", "msg", string(data))
 
-			if data, err = p.handleMCPCall(ctx, cache, ws, agent, data, api.CallTypeRequest); err != nil {
+			if data, err = session.handleMCPCall(ctx, data, api.CallTypeRequest); err != nil {
 				slog.Error("Unable to handle mcp agent message", err)
 				continue
 			}
 
-			stream.Stdin() <- sanitize.Data(data)
+			forward(data)
+
+		case h := <-session.held.results:
+
+			if !h.approved {
+				ws.Write(sanitize.Data(h.data))
+				continue
+			}
+
+			// The session may have been tainted while the call was held.
+			if err := session.taint.check(h.msg); err != nil {
+				slog.Warn("Approved tool call refused", "err", err)
+				session.refuse(h.msg.ID, err)
+				continue
+			}
+
+			slog.Debug("Forwarding approved tool call", "msg", string(h.data))
+
+			forward(h.data)
 
 		case data := <-stdout:
 
+			if shared != nil {
+				var ok bool
+				if data, ok, err = shared.inbound(data); err != nil {
+					slog.Error("Unable to restore mcp server message", "err", err)
+					continue
+				}
+				if !ok {
+					continue
+				}
+			}
+
 			slog.Debug("Received data from MCP Server", "msg", string(data))
 
-			if data, err = p.handleMCPCall(ctx, cache, ws, agent, data, api.CallTypeResponse); err != nil {
+			tracker.inbound(data)
+
+			if data, err = session.handleMCPCall(ctx, data, api.CallTypeResponse); err != nil {
 				slog.Error("Unable to handle mcp server message", err)
 				continue
 			}
 
 			ws.Write(sanitize.Data(data))
 
+		case data := <-replies:
+
+			slog.Debug("Replying from shared MCP Server cache", "msg", string(data))
+
+			if data, err = session.handleMCPCall(ctx, data, api.CallTypeResponse); err != nil {
+				slog.Error("Unable to handle mcp server message", "err", err)
+				continue
+			}
+
+			ws.Write(sanitize.Data(data))
+
+		case <-dead:
+			slog.Error("Shared MCP Server is gone")
+			return
+
 		case data := <-stderr:
 			_, _ = rb.Write(data)
 			slog.Debug("MCP Server Log", "stderr", string(data))
@@ -353,9 +532,9 @@ func (p *wsBackend) handleWS(w http.ResponseWriter, req *http.Request) {
 
 			if len(data) > 0 {
 				if p.cfg.dumpStderr {
-					_, _ = fmt.Fprintf(os.Stderr, "---\n%s\n---\n", strings.TrimSpace(string(data)))
+					_, _ = fmt.Fprintf(os.Stderr, "--- %s\n%s\n---\n", exitReason(err), strings.TrimSpace(string(data)))
 				} else {
-					slog.Error("MCP Server stderr", "stderr", string(data))
+					slog.Error("MCP Server stderr", "stderr", string(data), "reason", exitReason(err))
 				}
 			}
 
@@ -384,7 +563,25 @@ func (p *wsBackend) handleWS(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
-func (p *wsBackend) handleMCPCall(ctx context.Context, cache *ccache.Cache[context.Context], session wsc.Websocket, agent api.Agent, data []byte, rtype api.CallType) (buff []byte, err error) {
+// wsSession holds the state of a websocket session,
+// used to handle the messages exchanged during it.
+type wsSession struct {
+	backend  *wsBackend
+	ws       wsc.Websocket
+	agent    api.Agent
+	sessions *sessionTracker
+	cache    *ccache.Cache[context.Context]
+	origins  *originTracker
+	taint    *taintTracker
+	held     *approvalTracker
+}
+
+// refuse sends an MCP error for the call with the given ID to the agent.
+func (s *wsSession) refuse(id any, err error) {
+	s.ws.Write(sanitize.Data(makeMCPError(id, err)))
+}
+
+func (s *wsSession) handleMCPCall(ctx context.Context, data []byte, rtype api.CallType) (buff []byte, err error) {
 
 	msg := mcp.NewMessage("")
 	if err := elemental.Decode(elemental.EncodingTypeJSON, data, &msg); err != nil {
@@ -407,7 +604,7 @@ func (p *wsBackend) handleMCPCall(ctx context.Context, cache *ccache.Cache[conte
 		kind = trace.SpanKindServer
 	}
 
-	ctx, pctx, lspan, name := spanContextFromCache(ctx, cache, p.cfg.tracer, msg, kind)
+	ctx, pctx, lspan, name := spanContextFromCache(ctx, s.cache, s.backend.cfg.tracer, msg, kind)
 	defer lspan.End()
 
 	var spc *api.SpanContext
@@ -423,11 +620,30 @@ func (p *wsBackend) handleMCPCall(ctx context.Context, cache *ccache.Cache[conte
 		}
 	}
 
-	if data, err = p.police(ctx, spc, rtype, agent, msg, data); err != nil {
+	origin, _ := s.origins.observe(rtype, msg)
+
+	if rtype == api.CallTypeRequest {
+		if err := s.taint.check(msg); err != nil {
+			slog.Warn("Tool call refused", "err", err)
+			s.refuse(msg.ID, err)
+			return nil, nil
+		}
+	}
+
+	if data, err = s.police(ctx, spc, rtype, msg, origin, data); err != nil {
+
+		// The call is held until a decision is made on its approval.
+		if errors.Is(err, api.ErrPending) {
+			if err := s.held.hold(ctx, s.sessions.current(), s.agent, data, err); err != nil {
+				slog.Warn("Tool call refused", "err", err)
+				s.refuse(msg.ID, err)
+			}
+			return nil, nil
+		}
 
 		var oerr = err
-		if errors.Is(err, api.ErrBlocked) {
-			session.Write(sanitize.Data(data))
+		if errors.Is(err, api.ErrBlocked) || errors.Is(err, api.ErrUnavailable) {
+			s.ws.Write(sanitize.Data(data))
 			return nil, nil
 		}
 
@@ -438,10 +654,17 @@ func (p *wsBackend) handleMCPCall(ctx context.Context, cache *ccache.Cache[conte
 		return data, nil
 	}
 
+	if rtype == api.CallTypeResponse {
+		s.taint.observe(msg, origin)
+	}
+
 	return data, nil
 }
 
-func (p *wsBackend) police(ctx context.Context, spc *api.SpanContext, rtype api.CallType, agent api.Agent, call mcp.Message, rawData []byte) ([]byte, error) {
+func (s *wsSession) police(ctx context.Context, spc *api.SpanContext, rtype api.CallType, call mcp.Message, origin mcp.Message, rawData []byte) ([]byte, error) {
+
+	p := s.backend
+	session := s.sessions.next(rtype, call, origin)
 
 	// This is tools/list response, if we have hashes for them, we verify their integrity.
 	if dtools, ok := call.Result["tools"]; ok && len(p.cfg.sbom.Tools) > 0 {
@@ -485,24 +708,30 @@ func (p *wsBackend) police(ctx context.Context, spc *api.SpanContext, rtype api.
 
 	m := func(bool) time.Duration { return 0 }
 	if mm := p.cfg.metricsManager; mm != nil {
-		m = mm.MeasurePolicer(p.cfg.policer.Type(), rtype)
+		m = mm.MeasurePolicer(p.cfg.policer.Type(), "", rtype)
 	}
 
 	ctx, span := p.cfg.tracer.Start(ctx, "policer")
 	defer span.End()
 
 	req := api.Request{
-		Type:  rtype,
-		MCP:   call,
-		Agent: agent,
+		Type:      rtype,
+		MCP:       call,
+		OriginMCP: origin,
+		Agent:     s.agent,
+		Session:   session,
 	}
 	if spc != nil {
 		req.SpanContext = *spc
 		req.SpanContext.End = time.Now()
 	}
 
+	ctx, updates := api.WithStateUpdates(ctx)
+
 	rcall, err := p.cfg.policer.Police(ctx, req)
 
+	s.sessions.update(updates())
+
 	logFunc := slog.Debug
 	if !p.cfg.policerEnforced && err != nil {
 		logFunc = slog.Warn
@@ -512,7 +741,9 @@ func (p *wsBackend) police(ctx context.Context, spc *api.SpanContext, rtype api.
 	if err != nil {
 		defer m(false)
 
-		if errors.Is(err, api.ErrBlocked) {
+		// The policer is in fail closed mode if it returns
+		// ErrUnavailable, so the call is denied as if it was blocked.
+		if errors.Is(err, api.ErrBlocked) || errors.Is(err, api.ErrUnavailable) {
 			span.SetStatus(codes.Error, err.Error())
 			if !p.cfg.policerEnforced {
 				return rawData, nil
@@ -520,6 +751,21 @@ func (p *wsBackend) police(ctx context.Context, spc *api.SpanContext, rtype api.
 			return makeMCPError(call.ID, err), err
 		}
 
+		// The call is held until approved, along with the
+		// modifications made by the policer, like redactions.
+		if errors.Is(err, api.ErrPending) {
+			if rcall != nil {
+				var eerr error
+				if rawData, eerr = elemental.Encode(elemental.EncodingTypeJSON, rcall); eerr != nil {
+					return nil, fmt.Errorf("unable to reencode modified mcp call: %w", eerr)
+				}
+			}
+			if !p.cfg.policerEnforced {
+				return rawData, nil
+			}
+			return rawData, err
+		}
+
 		span.SetStatus(codes.Error, err.Error())
 		return nil, fmt.Errorf("unable to run policer.Police: %w", err)
 	}
@@ -540,6 +786,61 @@ func (p *wsBackend) police(ctx context.Context, spc *api.SpanContext, rtype api.
 	return rawData, nil
 }
 
+// policeSession sends the given session call to the policer.
+// It returns an error wrapping api.ErrBlocked if the session is
+// denied, or api.ErrUnavailable if the policer could not decide,
+// unless the policer is not enforced.
+func (p *wsBackend) policeSession(ctx context.Context, rtype api.CallType, agent api.Agent, sessions *sessionTracker) error {
+
+	if p.cfg.policer == nil {
+		return nil
+	}
+
+	m := func(bool) time.Duration { return 0 }
+	if mm := p.cfg.metricsManager; mm != nil {
+		m = mm.MeasurePolicer(p.cfg.policer.Type(), "", rtype)
+	}
+
+	ctx, span := p.cfg.tracer.Start(ctx, "policer")
+	defer span.End()
+
+	ctx, updates := api.WithStateUpdates(ctx)
+
+	_, err := p.cfg.policer.Police(ctx, api.Request{
+		Type:    rtype,
+		Agent:   agent,
+		Session: sessions.current(),
+	})
+
+	sessions.update(updates())
+
+	logFunc := slog.Debug
+	if !p.cfg.policerEnforced && err != nil {
+		logFunc = slog.Warn
+	}
+	defer logFunc("Policer session result", "type", rtype, "allowed", err == nil, "enforced", p.cfg.policerEnforced, "err", err)
+
+	if err != nil {
+		defer m(false)
+
+		span.SetStatus(codes.Error, err.Error())
+
+		if errors.Is(err, api.ErrBlocked) || errors.Is(err, api.ErrUnavailable) {
+			if !p.cfg.policerEnforced {
+				return nil
+			}
+			return err
+		}
+
+		return fmt.Errorf("unable to run policer.Police: %w", err)
+	}
+
+	m(true)
+	span.SetStatus(codes.Ok, "")
+
+	return nil
+}
+
 func spanContextFromCache(
 	ctx context.Context,
 	cache *ccache.Cache[context.Context],
@@ -613,3 +914,17 @@ func spanContextFromCache(
 
 	return rctx, ctx, span, name
 }
+
+// exitReason returns a human readable
+// reason for the MCP server exit.
+func exitReason(err error) string {
+
+	switch {
+	case err == nil:
+		return "mcp server exited"
+	case errors.Is(err, client.ErrOOMKilled):
+		return "mcp server was killed: out of memory"
+	default:
+		return fmt.Sprintf("mcp server exited: %s", err)
+	}
+}
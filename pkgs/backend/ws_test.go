@@ -4,13 +4,16 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"sync"
 	"testing"
 	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
 	"go.acuvity.ai/minibridge/pkgs/backend/client"
 	"go.acuvity.ai/minibridge/pkgs/frontend"
+	"go.acuvity.ai/minibridge/pkgs/mcp"
 	"go.acuvity.ai/minibridge/pkgs/policer"
+	"go.acuvity.ai/minibridge/pkgs/policer/api"
 	"go.acuvity.ai/wsc"
 )
 
@@ -96,6 +99,7 @@ func TestWS(t *testing.T) {
 		policer, err := policer.NewRego(`package main
 		import rego.v1
 		default allow := false
+		allow if input.type == "sessionStart"
 		reasons contains "you can't do that, Dave"
 		`)
 		So(err, ShouldBeNil)
@@ -115,6 +119,99 @@ func TestWS(t *testing.T) {
 		So(string(data), ShouldEqual, `{"error":{"code":451,"message":"request blocked: you can't do that, Dave"},"id":2,"jsonrpc":"2.0"}`)
 	})
 
+	Convey("Given a ws backend with a rego policer that denies the session", t, func() {
+
+		ctx, cancel := context.WithCancel(t.Context())
+		defer cancel()
+
+		policer, err := policer.NewRego(`package main
+		import rego.v1
+		default allow := false
+		allow if input.type != "sessionStart"
+		reasons contains "no session for you"
+		`)
+		So(err, ShouldBeNil)
+
+		ws, err := startBackend(ctx, OptPolicer(policer))
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "403 Forbidden")
+		So(ws, ShouldBeNil)
+	})
+
+	Convey("Given a ws backend with an MCP server that cannot start", t, func() {
+
+		ctx, cancel := context.WithCancel(t.Context())
+		defer cancel()
+
+		p := &recordingPolicer{}
+
+		listen := fmt.Sprintf("127.0.0.1:%d", freePort())
+		backend := NewWebSocket(listen, nil, client.NewStdio(client.MCPServer{Command: "/not/here"}), OptPolicer(p))
+
+		go func() { _ = backend.Start(ctx) }()
+		<-time.After(time.Second)
+
+		_, err := frontend.Connect(ctx, nil, fmt.Sprintf("ws://%s/ws", listen), nil, frontend.AgentInfo{UserAgent: "go-test"})
+		So(err, ShouldNotBeNil)
+
+		Convey("Then the session should be ended for the policer", func() {
+			So(p.types(), ShouldResemble, []api.CallType{api.CallTypeSessionStart, api.CallTypeSessionEnd})
+		})
+	})
+
+	Convey("Given a ws backend with a rego policer that denies the session without enforcing", t, func() {
+
+		ctx, cancel := context.WithCancel(t.Context())
+		defer cancel()
+
+		policer, err := policer.NewRego(`package main
+		import rego.v1
+		default allow := false
+		`)
+		So(err, ShouldBeNil)
+
+		ws, err := startBackend(ctx, OptPolicer(policer), OptPolicerEnforce(false))
+		So(err, ShouldBeNil)
+		So(ws, ShouldNotBeNil)
+	})
+
+	Convey("Given a ws backend with a rego policer that denies the output of a tool", t, func() {
+
+		ctx, cancel := context.WithCancel(t.Context())
+		defer cancel()
+
+		policer, err := policer.NewRego(`package main
+		import rego.v1
+		default allow := true
+		allow := false if {
+			input.type == "response"
+			input.originMCP.params.name == "secret"
+		}
+		reasons contains "no secret output"
+		`)
+		So(err, ShouldBeNil)
+
+		ws, err := startBackend(ctx, OptPolicer(policer))
+		So(err, ShouldBeNil)
+
+		read := func() string {
+			select {
+			case data := <-ws.Read():
+				return string(data)
+			case <-time.After(time.Second):
+				return ""
+			}
+		}
+
+		// cat echoes the request, seen as a request from the server.
+		ws.Write([]byte(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"secret"}}`))
+		So(read(), ShouldEqual, `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"secret"}}`)
+
+		// cat echoes the response, seen as the response to the tool call.
+		ws.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{}}`))
+		So(read(), ShouldEqual, `{"error":{"code":451,"message":"request blocked: no secret output"},"id":1,"jsonrpc":"2.0"}`)
+	})
+
 	Convey("Given a ws backend with a rego policer that allows the call without mutation", t, func() {
 
 		ctx, cancel := context.WithCancel(t.Context())
@@ -179,3 +276,25 @@ func TestWS(t *testing.T) {
 		So(string(data), ShouldEqual, `{"id":1,"jsonrpc":"2.0","result":{"hello":"world"}}`)
 	})
 }
+
+// recordingPolicer allows everything and
+// records the types of the requests.
+type recordingPolicer struct {
+	seen []api.CallType
+	sync.Mutex
+}
+
+func (p *recordingPolicer) Type() string { return "recording" }
+
+func (p *recordingPolicer) Police(_ context.Context, req api.Request) (*mcp.Message, error) {
+	p.Lock()
+	defer p.Unlock()
+	p.seen = append(p.seen, req.Type)
+	return nil, nil
+}
+
+func (p *recordingPolicer) types() []api.CallType {
+	p.Lock()
+	defer p.Unlock()
+	return append([]api.CallType{}, p.seen...)
+}
@@ -0,0 +1,108 @@
+package egress
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// An Allowlist holds the destinations an MCP server is allowed to reach.
+type Allowlist struct {
+	domains  map[string]struct{}
+	suffixes []string
+	prefixes []netip.Prefix
+}
+
+// NewAllowlist returns a new Allowlist from the given entries.
+// An entry can be:
+//   - a domain, like example.com, matching only that domain.
+//   - a wildcard domain, like *.example.com, matching all its subdomains.
+//   - an IP, like 10.0.0.1, or a CIDR, like 10.0.0.0/8, matching the
+//     IPs the destination resolves to.
+func NewAllowlist(entries ...string) (*Allowlist, error) {
+
+	a := &Allowlist{
+		domains: map[string]struct{}{},
+	}
+
+	for _, e := range entries {
+
+		e = strings.ToLower(strings.TrimSpace(e))
+
+		switch {
+
+		case e == "":
+			continue
+
+		case strings.Contains(e, "/"):
+			prefix, err := netip.ParsePrefix(e)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cidr '%s': %w", e, err)
+			}
+			a.prefixes = append(a.prefixes, prefix.Masked())
+
+		case isIP(e):
+			addr, _ := netip.ParseAddr(strings.Trim(e, "[]"))
+			a.prefixes = append(a.prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+
+		case strings.HasPrefix(e, "*."):
+			if strings.Contains(e[2:], "*") {
+				return nil, fmt.Errorf("invalid domain '%s': only leading wildcards are supported", e)
+			}
+			a.suffixes = append(a.suffixes, e[1:])
+
+		default:
+			if strings.Contains(e, "*") {
+				return nil, fmt.Errorf("invalid domain '%s': only leading wildcards are supported", e)
+			}
+			a.domains[strings.TrimSuffix(e, ".")] = struct{}{}
+		}
+	}
+
+	return a, nil
+}
+
+// AllowsDomain returns true if the given host
+// is allowed by a domain or wildcard entry.
+func (a *Allowlist) AllowsDomain(host string) bool {
+
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+
+	if _, ok := a.domains[host]; ok {
+		return true
+	}
+
+	for _, s := range a.suffixes {
+		if strings.HasSuffix(host, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllowsIP returns true if the given IP
+// is allowed by an IP or CIDR entry.
+func (a *Allowlist) AllowsIP(ip net.IP) bool {
+
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return false
+	}
+
+	addr = addr.Unmap()
+
+	for _, p := range a.prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isIP(s string) bool {
+	_, err := netip.ParseAddr(strings.Trim(s, "[]"))
+	return err == nil
+}
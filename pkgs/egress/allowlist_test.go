@@ -0,0 +1,50 @@
+package egress
+
+import (
+	"net"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAllowlist(t *testing.T) {
+
+	Convey("Given I have an allowlist", t, func() {
+
+		a, err := NewAllowlist("example.com", "*.acuvity.ai", "10.0.0.0/8", "192.168.1.1", "::1", "")
+		So(err, ShouldBeNil)
+
+		Convey("Domains should be matched", func() {
+			So(a.AllowsDomain("example.com"), ShouldBeTrue)
+			So(a.AllowsDomain("EXAMPLE.com."), ShouldBeTrue)
+			So(a.AllowsDomain("www.example.com"), ShouldBeFalse)
+			So(a.AllowsDomain("api.acuvity.ai"), ShouldBeTrue)
+			So(a.AllowsDomain("a.b.acuvity.ai"), ShouldBeTrue)
+			So(a.AllowsDomain("acuvity.ai"), ShouldBeFalse)
+			So(a.AllowsDomain("evilacuvity.ai"), ShouldBeFalse)
+		})
+
+		Convey("IPs should be matched", func() {
+			So(a.AllowsIP(net.ParseIP("10.1.2.3")), ShouldBeTrue)
+			So(a.AllowsIP(net.ParseIP("::ffff:10.1.2.3")), ShouldBeTrue)
+			So(a.AllowsIP(net.ParseIP("11.1.2.3")), ShouldBeFalse)
+			So(a.AllowsIP(net.ParseIP("192.168.1.1")), ShouldBeTrue)
+			So(a.AllowsIP(net.ParseIP("192.168.1.2")), ShouldBeFalse)
+			So(a.AllowsIP(net.ParseIP("::1")), ShouldBeTrue)
+			So(a.AllowsIP(nil), ShouldBeFalse)
+		})
+	})
+
+	Convey("Given I have invalid entries", t, func() {
+
+		_, err := NewAllowlist("10.0.0.0/99")
+		So(err, ShouldNotBeNil)
+
+		_, err = NewAllowlist("api.*.com")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "invalid domain 'api.*.com': only leading wildcards are supported")
+
+		_, err = NewAllowlist("*.*.com")
+		So(err, ShouldNotBeNil)
+	})
+}
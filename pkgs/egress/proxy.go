@@ -0,0 +1,400 @@
+package egress
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// proxyUser is the user of the proxy credentials.
+const proxyUser = "minibridge"
+
+// ErrDenied is returned when the destination is not in the allowlist.
+var ErrDenied = errors.New("destination not allowed")
+
+// hopHeaders are the headers that must not be forwarded.
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// A Call is a tool call in flight while the MCP server
+// reaches the network. It is used in the audit log.
+type Call struct {
+	Session string
+	Tool    string
+}
+
+func (c Call) String() string {
+	if c.Session == "" {
+		return c.Tool
+	}
+	return fmt.Sprintf("%s@%s", c.Tool, c.Session)
+}
+
+// A Proxy is an HTTP proxy, supporting CONNECT, that only
+// allows destinations from an Allowlist. It logs every
+// request along with the tool calls in flight.
+//
+// The proxy requires credentials generated for each Proxy and
+// given through Env, so other local processes cannot use it.
+// It is advisory: nothing prevents the MCP server from ignoring
+// it and reaching the network directly, unless it runs in an
+// isolated network where it can only reach the proxy.
+type Proxy struct {
+	allowlist *Allowlist
+	session   string
+	password  string
+	dialer    *net.Dialer
+	transport *http.Transport
+	listener  net.Listener
+
+	calls   map[uint64]Call
+	counter uint64
+
+	sync.Mutex
+}
+
+// NewProxy returns a new Proxy using the given Allowlist.
+// The session is used in the audit log. It can be empty
+// if the proxy is used by multiple sessions.
+func NewProxy(allowlist *Allowlist, session string) *Proxy {
+
+	p := &Proxy{
+		allowlist: allowlist,
+		session:   session,
+		password:  rand.Text(),
+		dialer:    &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+		calls:     map[uint64]Call{},
+	}
+
+	p.transport = &http.Transport{
+		DialContext:         p.dial,
+		ForceAttemptHTTP2:   true,
+		MaxIdleConns:        16,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+
+	return p
+}
+
+// Start starts the proxy on a random port of the loopback interface.
+// It returns once the proxy is listening, and stops it when the given
+// context is canceled.
+func (p *Proxy) Start(ctx context.Context) error {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("unable to start egress proxy listener: %w", err)
+	}
+
+	p.listener = listener
+
+	server := &http.Server{
+		Handler:           p,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Unable to start egress proxy", "err", err)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+		p.transport.CloseIdleConnections()
+	}()
+
+	return nil
+}
+
+// Addr returns the address the proxy listens on.
+func (p *Proxy) Addr() string {
+	return p.listener.Addr().String()
+}
+
+// Env returns the environment variables to configure
+// a command to use the proxy, including its credentials.
+func (p *Proxy) Env() []string {
+
+	u := (&url.URL{
+		Scheme: "http",
+		User:   url.UserPassword(proxyUser, p.password),
+		Host:   p.Addr(),
+	}).String()
+
+	return []string{
+		"HTTP_PROXY=" + u,
+		"HTTPS_PROXY=" + u,
+		"ALL_PROXY=" + u,
+		"http_proxy=" + u,
+		"https_proxy=" + u,
+		"all_proxy=" + u,
+		"NO_PROXY=",
+		"no_proxy=",
+	}
+}
+
+// TrackCall registers a tool call in flight. The returned
+// function must be called once the call is complete.
+func (p *Proxy) TrackCall(session string, tool string) func() {
+
+	p.Lock()
+	p.counter++
+	id := p.counter
+	p.calls[id] = Call{Session: session, Tool: tool}
+	p.Unlock()
+
+	return func() {
+		p.Lock()
+		delete(p.calls, id)
+		p.Unlock()
+	}
+}
+
+// Calls returns the tool calls in flight.
+func (p *Proxy) Calls() []string {
+
+	p.Lock()
+	defer p.Unlock()
+
+	out := make([]string, 0, len(p.calls))
+	for _, c := range p.calls {
+		out = append(out, c.String())
+	}
+
+	sort.Strings(out)
+
+	return out
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+
+	if !p.authenticated(req) {
+		w.Header().Set("Proxy-Authenticate", `Basic realm="minibridge"`)
+		http.Error(w, "minibridge egress proxy requires authentication", http.StatusProxyAuthRequired)
+		return
+	}
+
+	if req.Method == http.MethodConnect {
+		p.handleConnect(w, req)
+		return
+	}
+
+	if req.URL.Host == "" || !req.URL.IsAbs() {
+		http.Error(w, "minibridge egress proxy only accepts proxy requests", http.StatusBadRequest)
+		return
+	}
+
+	p.handleHTTP(w, req)
+}
+
+// authenticated returns true if the request
+// has the credentials of the proxy.
+func (p *Proxy) authenticated(req *http.Request) bool {
+
+	r := &http.Request{Header: http.Header{"Authorization": req.Header.Values("Proxy-Authorization")}}
+
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(user), []byte(proxyUser)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(password), []byte(p.password)) == 1
+}
+
+func (p *Proxy) handleConnect(w http.ResponseWriter, req *http.Request) {
+
+	upstream, err := p.dial(req.Context(), "tcp", req.Host)
+	p.audit(req, req.Host, err)
+	if err != nil {
+		p.fail(w, err)
+		return
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		_ = upstream.Close()
+		http.Error(w, "hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+
+	conn, brw, err := hj.Hijack()
+	if err != nil {
+		_ = upstream.Close()
+		slog.Error("Unable to hijack egress connection", "err", err)
+		return
+	}
+
+	if _, err := conn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+		_ = conn.Close()
+		_ = upstream.Close()
+		return
+	}
+
+	// The client may have sent data along with the CONNECT request.
+	if n := brw.Reader.Buffered(); n > 0 {
+		data, _ := brw.Reader.Peek(n)
+		if _, err := upstream.Write(data); err != nil {
+			_ = conn.Close()
+			_ = upstream.Close()
+			return
+		}
+	}
+
+	go pipe(conn, upstream)
+}
+
+func (p *Proxy) handleHTTP(w http.ResponseWriter, req *http.Request) {
+
+	host := req.URL.Host
+	if req.URL.Port() == "" {
+		host = net.JoinHostPort(req.URL.Hostname(), "80")
+	}
+
+	outreq := req.Clone(req.Context())
+	outreq.RequestURI = ""
+	for _, h := range hopHeaders {
+		outreq.Header.Del(h)
+	}
+
+	resp, err := p.transport.RoundTrip(outreq)
+	p.audit(req, host, err)
+	if err != nil {
+		p.fail(w, err)
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	for _, h := range hopHeaders {
+		resp.Header.Del(h)
+	}
+
+	for k, vs := range resp.Header {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, resp.Body)
+}
+
+// dial connects to the given address if it is allowed. If the host is
+// only allowed by an IP or CIDR entry, it connects to the resolved IP
+// that matched, so the destination cannot change between the check
+// and the connection.
+func (p *Proxy) dial(ctx context.Context, network string, addr string) (net.Conn, error) {
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid destination '%s': %w", addr, err)
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		if !p.allowlist.AllowsIP(ip) {
+			return nil, ErrDenied
+		}
+		return p.dialer.DialContext(ctx, network, addr)
+	}
+
+	if p.allowlist.AllowsDomain(host) {
+		return p.dialer.DialContext(ctx, network, addr)
+	}
+
+	// We don't resolve names if we cannot allow them,
+	// as DNS queries can be used to exfiltrate data.
+	if len(p.allowlist.prefixes) == 0 {
+		return nil, ErrDenied
+	}
+
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve '%s': %w", host, err)
+	}
+
+	for _, ip := range ips {
+		if p.allowlist.AllowsIP(ip.IP) {
+			return p.dialer.DialContext(ctx, network, net.JoinHostPort(ip.IP.String(), port))
+		}
+	}
+
+	return nil, ErrDenied
+}
+
+func (p *Proxy) audit(req *http.Request, host string, err error) {
+
+	attrs := []any{
+		"session", p.session,
+		"calls", p.Calls(),
+		"method", req.Method,
+		"host", host,
+	}
+
+	if req.Method != http.MethodConnect {
+		attrs = append(attrs, "url", req.URL.String())
+	}
+
+	switch {
+	case errors.Is(err, ErrDenied):
+		slog.Warn("Egress request denied", attrs...)
+	case err != nil:
+		slog.Info("Egress request failed", append(attrs, "err", err)...)
+	default:
+		slog.Info("Egress request allowed", attrs...)
+	}
+}
+
+func (p *Proxy) fail(w http.ResponseWriter, err error) {
+
+	if errors.Is(err, ErrDenied) {
+		http.Error(w, "destination blocked by minibridge egress policy", http.StatusForbidden)
+		return
+	}
+
+	http.Error(w, fmt.Sprintf("unable to reach destination: %s", err), http.StatusBadGateway)
+}
+
+func pipe(a net.Conn, b net.Conn) {
+
+	done := make(chan struct{}, 2)
+
+	cp := func(dst net.Conn, src net.Conn) {
+		_, _ = io.Copy(dst, src)
+		if c, ok := dst.(interface{ CloseWrite() error }); ok {
+			_ = c.CloseWrite()
+		}
+		done <- struct{}{}
+	}
+
+	go cp(a, b)
+	go cp(b, a)
+
+	<-done
+	<-done
+
+	_ = a.Close()
+	_ = b.Close()
+}
@@ -0,0 +1,152 @@
+package egress
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func proxiedClient(p *Proxy, user *url.Userinfo) *http.Client {
+	u, _ := url.Parse("http://" + p.Addr())
+	u.User = user
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:           http.ProxyURL(u),
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, // #nosec: G402
+		},
+	}
+}
+
+func TestProxy(t *testing.T) {
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+	})
+
+	ts := httptest.NewServer(handler)
+	defer ts.Close()
+
+	tts := httptest.NewTLSServer(handler)
+	defer tts.Close()
+
+	Convey("Given I have a proxy allowing localhost", t, func() {
+
+		a, _ := NewAllowlist("127.0.0.0/8")
+		p := NewProxy(a, "sid")
+		So(p.Start(t.Context()), ShouldBeNil)
+
+		cl := proxiedClient(p, url.UserPassword(proxyUser, p.password))
+
+		Convey("Then HTTP requests should be proxied", func() {
+
+			resp, err := cl.Get(ts.URL)
+			So(err, ShouldBeNil)
+			defer func() { _ = resp.Body.Close() }()
+			data, _ := io.ReadAll(resp.Body)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(string(data), ShouldEqual, "hello")
+		})
+
+		Convey("Then HTTPS requests should be tunneled", func() {
+
+			resp, err := cl.Get(tts.URL)
+			So(err, ShouldBeNil)
+			defer func() { _ = resp.Body.Close() }()
+			data, _ := io.ReadAll(resp.Body)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(string(data), ShouldEqual, "hello")
+		})
+
+		Convey("Then requests by name should be allowed by the resolved IP", func() {
+
+			resp, err := cl.Get(strings.Replace(ts.URL, "127.0.0.1", "localhost", 1))
+			So(err, ShouldBeNil)
+			defer func() { _ = resp.Body.Close() }()
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("Then non proxy requests should be rejected", func() {
+
+			req, err := http.NewRequest(http.MethodGet, "http://"+p.Addr()+"/", nil)
+			So(err, ShouldBeNil)
+			req.Header.Set("Proxy-Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(proxyUser+":"+p.password)))
+			resp, err := http.DefaultClient.Do(req)
+			So(err, ShouldBeNil)
+			defer func() { _ = resp.Body.Close() }()
+			So(resp.StatusCode, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("Then HTTP requests without credentials should be rejected", func() {
+
+			resp, err := proxiedClient(p, nil).Get(ts.URL)
+			So(err, ShouldBeNil)
+			defer func() { _ = resp.Body.Close() }()
+			So(resp.StatusCode, ShouldEqual, http.StatusProxyAuthRequired)
+		})
+
+		Convey("Then HTTPS requests with invalid credentials should be rejected", func() {
+
+			_, err := proxiedClient(p, url.UserPassword(proxyUser, "nope")).Get(tts.URL)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "Proxy Authentication Required")
+		})
+	})
+
+	Convey("Given I have a proxy not allowing localhost", t, func() {
+
+		a, _ := NewAllowlist("example.com")
+		p := NewProxy(a, "sid")
+		So(p.Start(t.Context()), ShouldBeNil)
+
+		cl := proxiedClient(p, url.UserPassword(proxyUser, p.password))
+
+		Convey("Then HTTP requests should be denied", func() {
+
+			resp, err := cl.Get(ts.URL)
+			So(err, ShouldBeNil)
+			defer func() { _ = resp.Body.Close() }()
+			So(resp.StatusCode, ShouldEqual, http.StatusForbidden)
+		})
+
+		Convey("Then HTTPS requests should be denied", func() {
+
+			_, err := cl.Get(tts.URL)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "Forbidden")
+		})
+	})
+
+	Convey("Given I track calls", t, func() {
+
+		a, _ := NewAllowlist()
+		p := NewProxy(a, "")
+
+		done1 := p.TrackCall("s1", "fetch")
+		done2 := p.TrackCall("", "search")
+		So(p.Calls(), ShouldResemble, []string{"fetch@s1", "search"})
+
+		done1()
+		So(p.Calls(), ShouldResemble, []string{"search"})
+
+		done2()
+		So(p.Calls(), ShouldBeEmpty)
+	})
+
+	Convey("Env should return the proxy variables", t, func() {
+
+		a, _ := NewAllowlist()
+		p := NewProxy(a, "")
+		So(p.Start(t.Context()), ShouldBeNil)
+
+		env := p.Env()
+		So(env, ShouldContain, "HTTPS_PROXY=http://"+proxyUser+":"+p.password+"@"+p.Addr())
+		So(env, ShouldContain, "NO_PROXY=")
+	})
+}
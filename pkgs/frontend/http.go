@@ -152,7 +152,7 @@ func (p *httpFrontend) HTTPClient() *http.Client {
 }
 
 func (p *httpFrontend) BackendInfo() (info.Info, error) {
-	return getBackendInfo(p)
+	return getBackendInfo(p, p.u)
 }
 
 // ServeHTTP is the main HTTP handler. If you decide to not start the built-in server
@@ -509,7 +509,7 @@ func (p *httpFrontend) handleOAuth2(w http.ResponseWriter, req *http.Request) {
 		uu.Scheme = "https"
 	}
 
-	uu.Path = "/oauth2"
+	uu.Path = gatewayPath(p.u, "/oauth2")
 	uu.RawPath = uu.Path
 
 	proxy := &httputil.ReverseProxy{
@@ -4,17 +4,35 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"strings"
 
 	"go.acuvity.ai/elemental"
 	"go.acuvity.ai/minibridge/pkgs/info"
 )
 
-func getBackendInfo(mfrontend Frontend) (info.Info, error) {
+// gatewayServer returns the name of the server when the
+// given backend url points to a gateway, like /ws/{server}.
+// Otherwise, it returns an empty string.
+func gatewayServer(u *url.URL) string {
+	return strings.TrimPrefix(strings.TrimPrefix(u.Path, "/ws"), "/")
+}
+
+// gatewayPath returns the given backend path,
+// suffixed with the gateway server name if any.
+func gatewayPath(u *url.URL, path string) string {
+	if server := gatewayServer(u); server != "" {
+		return path + "/" + server
+	}
+	return path
+}
+
+func getBackendInfo(mfrontend Frontend, u *url.URL) (info.Info, error) {
 
 	inf := info.Info{}
 	cl := mfrontend.HTTPClient()
 
-	resp, err := cl.Get(fmt.Sprintf("%s/_info", mfrontend.BackendURL()))
+	resp, err := cl.Get(mfrontend.BackendURL() + gatewayPath(u, "/_info"))
 	if err != nil {
 		return inf, fmt.Errorf("unable to make backend info request: %w", err)
 	}
@@ -115,7 +115,7 @@ func (p *stdioFrontend) BackendURL() string {
 }
 
 func (p *stdioFrontend) BackendInfo() (info.Info, error) {
-	return getBackendInfo(p)
+	return getBackendInfo(p, p.u)
 }
 
 func (p *stdioFrontend) wspump(ctx context.Context) error {
@@ -24,6 +24,13 @@ type Manager struct {
 	wsConnCurrentMetric       prometheus.Gauge
 	policerDurationMetric     *prometheus.HistogramVec
 	policerRequestTotalMetric *prometheus.CounterVec
+	policerCacheTotalMetric   *prometheus.CounterVec
+	policerDetectionMetric    *prometheus.CounterVec
+	seccompBlockedMetric      *prometheus.CounterVec
+	oomKillsMetric            *prometheus.CounterVec
+	cpuThrottledMetric        *prometheus.CounterVec
+	cpuThrottledTimeMetric    *prometheus.CounterVec
+	restartsMetric            *prometheus.CounterVec
 
 	server *http.Server
 }
@@ -87,14 +94,63 @@ func NewManager(listen string) *Manager {
 				Help:    "The average duration of the policing requests",
 				Buckets: []float64{0.001, 0.0025, 0.005, 0.010, 0.025, 0.050, 0.100, 0.250, 0.500, 1.0, 2.5, 5.0, 10.0},
 			},
-			[]string{"policer_type", "call_type"},
+			[]string{"policer_type", "policer_stage", "call_type"},
 		),
 		policerRequestTotalMetric: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "policer_request_total",
 				Help: "The total number of policer requests.",
 			},
-			[]string{"policer_type", "call_type", "decision"},
+			[]string{"policer_type", "policer_stage", "call_type", "decision"},
+		),
+		policerCacheTotalMetric: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "policer_cache_total",
+				Help: "The total number of policer decision cache lookups.",
+			},
+			[]string{"policer_type", "result"},
+		),
+		policerDetectionMetric: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "policer_detections_total",
+				Help: "The total number of sensitive data found by the policers.",
+			},
+			[]string{"policer_type", "detector"},
+		),
+		seccompBlockedMetric: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mcp_server_seccomp_blocked_total",
+				Help: "The total number of syscalls blocked by the seccomp profile.",
+			},
+			[]string{"profile", "syscall"},
+		),
+		oomKillsMetric: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mcp_server_oom_kills_total",
+				Help: "The total number of MCP server processes killed for exceeding their memory limit.",
+			},
+			[]string{"server"},
+		),
+		cpuThrottledMetric: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mcp_server_cpu_throttled_periods_total",
+				Help: "The total number of periods MCP servers have been throttled for exceeding their cpu limit.",
+			},
+			[]string{"server"},
+		),
+		cpuThrottledTimeMetric: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mcp_server_cpu_throttled_seconds_total",
+				Help: "The total time MCP servers have been throttled for exceeding their cpu limit.",
+			},
+			[]string{"server"},
+		),
+		restartsMetric: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "mcp_server_restarts_total",
+				Help: "The total number of MCP servers restarted after an unexpected exit.",
+			},
+			[]string{"server"},
 		),
 	}
 
@@ -107,6 +163,13 @@ func NewManager(listen string) *Manager {
 	r.MustRegister(mc.errorMetric)
 	r.MustRegister(mc.policerDurationMetric)
 	r.MustRegister(mc.policerRequestTotalMetric)
+	r.MustRegister(mc.policerCacheTotalMetric)
+	r.MustRegister(mc.policerDetectionMetric)
+	r.MustRegister(mc.seccompBlockedMetric)
+	r.MustRegister(mc.oomKillsMetric)
+	r.MustRegister(mc.cpuThrottledMetric)
+	r.MustRegister(mc.cpuThrottledTimeMetric)
+	r.MustRegister(mc.restartsMetric)
 
 	mc.server = &http.Server{
 		Addr:              listen,
@@ -185,15 +248,18 @@ func (c *Manager) MeasureRequest(method string, path string) func(int) time.Dura
 	}
 }
 
-func (c *Manager) MeasurePolicer(ptype string, rtype api.CallType) func(allow bool) time.Duration {
+// MeasurePolicer measures a policer decision. The stage is the name of
+// the stage of a policer chain, or empty for the policer as a whole.
+func (c *Manager) MeasurePolicer(ptype string, stage string, rtype api.CallType) func(allow bool) time.Duration {
 
 	timer := prometheus.NewTimer(
 		prometheus.ObserverFunc(
 			func(v float64) {
 				c.policerDurationMetric.With(
 					prometheus.Labels{
-						"policer_type": ptype,
-						"call_type":    string(rtype),
+						"policer_type":  ptype,
+						"policer_stage": stage,
+						"call_type":     string(rtype),
 					},
 				).Observe(v)
 			},
@@ -202,8 +268,9 @@ func (c *Manager) MeasurePolicer(ptype string, rtype api.CallType) func(allow bo
 
 	return func(allow bool) time.Duration {
 		c.policerRequestTotalMetric.With(prometheus.Labels{
-			"policer_type": ptype,
-			"call_type":    string(rtype),
+			"policer_type":  ptype,
+			"policer_stage": stage,
+			"call_type":     string(rtype),
 			"decision": func() string {
 				if allow {
 					return "allow"
@@ -216,6 +283,29 @@ func (c *Manager) MeasurePolicer(ptype string, rtype api.CallType) func(allow bo
 	}
 }
 
+// RecordPolicerCache records a lookup in the decision cache of a policer.
+func (c *Manager) RecordPolicerCache(ptype string, hit bool) {
+
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+
+	c.policerCacheTotalMetric.With(prometheus.Labels{
+		"policer_type": ptype,
+		"result":       result,
+	}).Inc()
+}
+
+// RecordPolicerDetection records n pieces of sensitive
+// data found by the given detector of a policer.
+func (c *Manager) RecordPolicerDetection(ptype string, detector string, n int) {
+	c.policerDetectionMetric.With(prometheus.Labels{
+		"policer_type": ptype,
+		"detector":     detector,
+	}).Add(float64(n))
+}
+
 func (c *Manager) RegisterWSConnection() {
 	c.wsConnTotalMetric.Inc()
 	c.wsConnCurrentMetric.Inc()
@@ -234,6 +324,34 @@ func (c *Manager) UnregisterTCPConnection() {
 	c.tcpConnCurrentMetric.Dec()
 }
 
+// RecordSeccompBlock records a syscall of an MCP
+// server blocked by the given seccomp profile.
+func (c *Manager) RecordSeccompBlock(profile string, syscall string) {
+	c.seccompBlockedMetric.With(prometheus.Labels{
+		"profile": profile,
+		"syscall": syscall,
+	}).Inc()
+}
+
+// RecordOOMKills records n processes of the given
+// MCP server killed for exceeding its memory limit.
+func (c *Manager) RecordOOMKills(server string, n int64) {
+	c.oomKillsMetric.With(prometheus.Labels{"server": server}).Add(float64(n))
+}
+
+// RecordCPUThrottling records the periods and the time
+// the given MCP server was throttled by its cpu limit.
+func (c *Manager) RecordCPUThrottling(server string, periods int64, d time.Duration) {
+	c.cpuThrottledMetric.With(prometheus.Labels{"server": server}).Add(float64(periods))
+	c.cpuThrottledTimeMetric.With(prometheus.Labels{"server": server}).Add(d.Seconds())
+}
+
+// RecordRestart records a restart of the given
+// MCP server after an unexpected exit.
+func (c *Manager) RecordRestart(server string) {
+	c.restartsMetric.With(prometheus.Labels{"server": server}).Inc()
+}
+
 func (c *Manager) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 
 	switch req.URL.Path {
@@ -0,0 +1,26 @@
+package api
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+type cacheControlKey struct{}
+
+// WithCacheControl returns a context allowing the policers to mark
+// their decision as not cacheable using DisableCache. The returned
+// function reports if the decision can be cached.
+func WithCacheControl(ctx context.Context) (context.Context, func() bool) {
+
+	disabled := &atomic.Bool{}
+
+	return context.WithValue(ctx, cacheControlKey{}, disabled), func() bool { return !disabled.Load() }
+}
+
+// DisableCache marks the decision made for the
+// request policed with the given context as not cacheable.
+func DisableCache(ctx context.Context) {
+	if disabled, ok := ctx.Value(cacheControlKey{}).(*atomic.Bool); ok {
+		disabled.Store(true)
+	}
+}
@@ -3,3 +3,13 @@ package api
 import "errors"
 
 var ErrBlocked = errors.New("request blocked")
+
+// ErrUnavailable is returned when a policer
+// is unable to give a decision.
+var ErrUnavailable = errors.New("policer unavailable")
+
+// ErrPending is returned when a request must
+// be approved by a human before being forwarded.
+// The policer can return the modified request
+// along with it, which is the one to approve.
+var ErrPending = errors.New("request pending approval")
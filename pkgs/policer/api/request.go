@@ -13,6 +13,14 @@ type CallType string
 var (
 	CallTypeRequest  CallType = "request"
 	CallTypeResponse CallType = "response"
+
+	// CallTypeSessionStart is sent when an agent connects, before
+	// the MCP server is started. The MCP field is empty.
+	CallTypeSessionStart CallType = "sessionStart"
+
+	// CallTypeSessionEnd is sent when an agent disconnects.
+	// The MCP field is empty and the decision is ignored.
+	CallTypeSessionEnd CallType = "sessionEnd"
 )
 
 // SpanContext contains information about the OTEL span
@@ -35,15 +43,26 @@ type Request struct {
 
 	// Type of the request. Request will be set for request from the agent
 	// and Response will be set for replies from the MPC server.
+	// SessionStart and SessionEnd are set when the agent connects
+	// and disconnects.
 	Type CallType `json:"type"`
 
 	// MPC embeds the full MPC call, either request or response,
-	// based on the Type.
+	// based on the Type. It is empty for session calls.
 	MCP mcp.Message `json:"mcp,omitzero"`
 
+	// OriginMCP contains the request the MCP call responds to,
+	// when the MCP call is a response to a known request. This
+	// allows to police the output of a given method or tool.
+	OriginMCP mcp.Message `json:"originMCP,omitzero"`
+
 	// Agent contains callers information.
 	Agent Agent `json:"agent,omitzero"`
 
+	// Session contains information about the session, like
+	// the MCP server and the state set by the policers.
+	Session Session `json:"session,omitzero"`
+
 	// SpanContext contains info about the eventual OTEL span
 	// for the request. There are advanced use cases where you
 	// want correlation between a Request and the OTEL traces
@@ -5,6 +5,10 @@ import "go.acuvity.ai/minibridge/pkgs/mcp"
 // GenericDenyReason is the generic reason returned if non is provided.
 const GenericDenyReason = "You are not allowed to perform this operation"
 
+// GenericPendingReason is the generic reason returned
+// if none is provided for a request pending approval.
+const GenericPendingReason = "This operation must be approved"
+
 // A Response is returned by the Policer.
 type Response struct {
 
@@ -22,4 +26,19 @@ type Response struct {
 	// this one. This allows Policers to modify the content
 	// of an MCP call.
 	MCP *mcp.Message `json:"mcp,omitempty"`
+
+	// If true, the decision must not be cached, as
+	// it depends on something else than the request.
+	NoCache bool `json:"noCache,omitempty"`
+
+	// If true, and Allow is false, the request is held
+	// until a human approves or denies it for the
+	// reasons set in Reasons. If MCP is set, it is the
+	// call that is held and forwarded once approved.
+	Pending bool `json:"pending,omitempty"`
+
+	// State contains values to set in the state of the
+	// session. A null value removes the key. Setting
+	// values disables the cache of the decision.
+	State map[string]any `json:"state,omitempty"`
 }
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"context"
+	"maps"
+	"sync"
+)
+
+// Session contains information about the session
+// of the agent the request is part of.
+type Session struct {
+
+	// ID is the identifier of the session.
+	ID string `json:"ID"`
+
+	// Sequence is the number of the message in the session,
+	// counting the messages in both directions, starting at 1.
+	// It is 0 for the SessionStart call.
+	Sequence int64 `json:"sequence,omitempty"`
+
+	// ProtocolVersion is the MCP protocol version negotiated
+	// by the initialize call, once the MCP server responded.
+	ProtocolVersion string `json:"protocolVersion,omitempty"`
+
+	// Server contains the information the MCP server
+	// returned in the response to the initialize call.
+	Server ServerInfo `json:"server,omitzero"`
+
+	// State contains the values set by the policers
+	// for the session, using UpdateState.
+	State map[string]any `json:"state,omitempty"`
+}
+
+// ServerInfo contains information about the MCP server.
+type ServerInfo struct {
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+type stateUpdatesKey struct{}
+
+type stateUpdates struct {
+	values map[string]any
+	sync.Mutex
+}
+
+// WithStateUpdates returns a context allowing the policers to update
+// the state of the session using UpdateState. The returned function
+// returns the updates made.
+func WithStateUpdates(ctx context.Context) (context.Context, func() map[string]any) {
+
+	updates := &stateUpdates{values: map[string]any{}}
+
+	return context.WithValue(ctx, stateUpdatesKey{}, updates), func() map[string]any {
+		updates.Lock()
+		defer updates.Unlock()
+		return maps.Clone(updates.values)
+	}
+}
+
+// UpdateState sets the given values in the state of the session of the
+// request policed with the given context. A nil value removes the key.
+// As the updates would not be replayed, it also disables the cache of
+// the decision.
+func UpdateState(ctx context.Context, values map[string]any) {
+
+	if len(values) == 0 {
+		return
+	}
+
+	DisableCache(ctx)
+
+	if updates, ok := ctx.Value(stateUpdatesKey{}).(*stateUpdates); ok {
+		updates.Lock()
+		maps.Copy(updates.values, values)
+		updates.Unlock()
+	}
+}
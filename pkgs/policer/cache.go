@@ -0,0 +1,124 @@
+package policer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/karlseguin/ccache/v3"
+	"go.acuvity.ai/minibridge/pkgs/mcp"
+	"go.acuvity.ai/minibridge/pkgs/policer/api"
+)
+
+// decision is a cached policer decision.
+type decision struct {
+	msg *mcp.Message
+	err error
+}
+
+type cache struct {
+	policer   Policer
+	decisions *ccache.Cache[decision]
+	cfg       cacheCfg
+}
+
+// NewCache returns a Policer caching the decisions of the given Policer.
+// Identical requests, ignoring their MCP IDs, span contexts, session IDs
+// and sequence numbers, get the same decision until it expires. Only
+// allowed and blocked requests are cached, and a policer can opt out for
+// a given decision by calling api.DisableCache. Session calls are never
+// cached. If the Policer is Generational, the decisions made before its
+// generation changed, like before its policies are reloaded, are ignored.
+func NewCache(policer Policer, options ...CacheOption) Policer {
+
+	cfg := newCacheCfg()
+	for _, o := range options {
+		o(&cfg)
+	}
+
+	return &cache{
+		policer:   policer,
+		decisions: ccache.New(ccache.Configure[decision]().MaxSize(cfg.maxSize)),
+		cfg:       cfg,
+	}
+}
+
+func (c *cache) Type() string { return c.policer.Type() }
+
+func (c *cache) Police(ctx context.Context, req api.Request) (*mcp.Message, error) {
+
+	if req.Type != api.CallTypeRequest && req.Type != api.CallTypeResponse {
+		return c.policer.Police(ctx, req)
+	}
+
+	key, err := cacheKey(req, generation(c.policer))
+	if err != nil {
+		return nil, fmt.Errorf("unable to compute policer cache key: %w", err)
+	}
+
+	if item := c.decisions.Get(key); item != nil && !item.Expired() {
+		c.record(true)
+		return item.Value().forID(req.MCP.ID)
+	}
+
+	c.record(false)
+
+	cctx, cacheable := api.WithCacheControl(ctx)
+
+	msg, err := c.policer.Police(cctx, req)
+
+	switch {
+	case !cacheable():
+		// Let the eventual outer caches know as well.
+		api.DisableCache(ctx)
+	case err == nil, errors.Is(err, api.ErrBlocked):
+		c.decisions.Set(key, decision{msg: msg, err: err}, c.cfg.ttl)
+	}
+
+	return msg, err
+}
+
+func (c *cache) record(hit bool) {
+	if c.cfg.metricsManager != nil {
+		c.cfg.metricsManager.RecordPolicerCache(c.policer.Type(), hit)
+	}
+}
+
+// forID returns the decision for a request with the given ID.
+func (d decision) forID(id any) (*mcp.Message, error) {
+
+	if d.msg == nil {
+		return nil, d.err
+	}
+
+	msg := *d.msg
+	msg.ID = id
+
+	return &msg, d.err
+}
+
+// cacheKey returns a canonical hash of the given request, ignoring
+// the fields changing for every call, for the given generation.
+func cacheKey(req api.Request, gen uint64) (string, error) {
+
+	req.MCP.ID = nil
+	req.OriginMCP.ID = nil
+	req.SpanContext = api.SpanContext{}
+	req.Session.ID = ""
+	req.Session.Sequence = 0
+
+	// encoding/json sorts the keys of the maps,
+	// so identical requests are always encoded
+	// the same way.
+	data, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return fmt.Sprintf("%d:%s", gen, hex.EncodeToString(sum[:])), nil
+}
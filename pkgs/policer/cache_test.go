@@ -0,0 +1,216 @@
+package policer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.acuvity.ai/minibridge/pkgs/mcp"
+	"go.acuvity.ai/minibridge/pkgs/policer/api"
+	"go.acuvity.ai/minibridge/pkgs/policer/internal/rego"
+)
+
+func TestCache(t *testing.T) {
+
+	call := func(id any, name string) api.Request {
+		return api.Request{
+			Type:        api.CallTypeRequest,
+			MCP:         mcp.Message{ID: id, Method: "tools/call", Params: map[string]any{"name": name}},
+			SpanContext: api.SpanContext{TraceID: fmt.Sprintf("%v", id)},
+		}
+	}
+
+	Convey("Given I have a cached policer modifying the calls", t, func() {
+
+		inner := renaming("a", "renamed")
+		p := NewCache(inner)
+		So(p.Type(), ShouldEqual, "a")
+
+		msg, err := p.Police(context.Background(), call(1, "echo"))
+		So(err, ShouldBeNil)
+		So(msg.ID, ShouldEqual, 1)
+
+		Convey("Then an identical call with another ID should be cached", func() {
+			msg, err := p.Police(context.Background(), call(2, "echo"))
+			So(err, ShouldBeNil)
+			So(msg.Method, ShouldEqual, "renamed")
+			So(msg.ID, ShouldEqual, 2)
+			So(inner.seen, ShouldHaveLength, 1)
+		})
+
+		Convey("Then an identical call in another session should be cached", func() {
+			req := call(2, "echo")
+			req.Session = api.Session{ID: "other", Sequence: 12}
+			_, err := p.Police(context.Background(), req)
+			So(err, ShouldBeNil)
+			So(inner.seen, ShouldHaveLength, 1)
+		})
+
+		Convey("Then an identical call with another session state should not be cached", func() {
+			req := call(2, "echo")
+			req.Session = api.Session{State: map[string]any{"calls": 1}}
+			_, err := p.Police(context.Background(), req)
+			So(err, ShouldBeNil)
+			So(inner.seen, ShouldHaveLength, 2)
+		})
+
+		Convey("Then a different call should not be cached", func() {
+			_, err := p.Police(context.Background(), call(2, "other"))
+			So(err, ShouldBeNil)
+			So(inner.seen, ShouldHaveLength, 2)
+		})
+
+		Convey("Then a session call should not be cached", func() {
+			_, _ = p.Police(context.Background(), api.Request{Type: api.CallTypeSessionStart})
+			_, _ = p.Police(context.Background(), api.Request{Type: api.CallTypeSessionStart})
+			So(inner.seen, ShouldHaveLength, 3)
+		})
+	})
+
+	Convey("Given I have a cached policer denying the calls", t, func() {
+
+		inner := denying("a", "nope")
+		p := NewCache(inner)
+
+		_, err := p.Police(context.Background(), call(1, "echo"))
+		So(err, ShouldNotBeNil)
+		_, err = p.Police(context.Background(), call(2, "echo"))
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "request blocked: nope")
+		So(inner.seen, ShouldHaveLength, 1)
+	})
+
+	Convey("Given I have a cached policer that is unavailable", t, func() {
+
+		inner := &fakePolicer{name: "a", police: func(api.Request) (*mcp.Message, error) {
+			return nil, fmt.Errorf("%w: boom", api.ErrUnavailable)
+		}}
+		p := NewCache(inner)
+
+		_, _ = p.Police(context.Background(), call(1, "echo"))
+		_, err := p.Police(context.Background(), call(2, "echo"))
+		So(errors.Is(err, api.ErrUnavailable), ShouldBeTrue)
+		So(inner.seen, ShouldHaveLength, 2)
+	})
+
+	Convey("Given I have a cached policer disabling the cache", t, func() {
+
+		inner := &fakePolicer{name: "a"}
+		inner.police = func(api.Request) (*mcp.Message, error) { return nil, nil }
+		p := NewCache(&disablingPolicer{inner})
+
+		outer, cacheable := api.WithCacheControl(context.Background())
+
+		_, _ = p.Police(outer, call(1, "echo"))
+		_, _ = p.Police(outer, call(2, "echo"))
+		So(inner.seen, ShouldHaveLength, 2)
+		So(cacheable(), ShouldBeFalse)
+	})
+
+	Convey("Given I have a cached policer with a short ttl", t, func() {
+
+		inner := allowing("a")
+		p := NewCache(inner, OptCacheTTL(10*time.Millisecond))
+
+		_, _ = p.Police(context.Background(), call(1, "echo"))
+		time.Sleep(20 * time.Millisecond)
+		_, _ = p.Police(context.Background(), call(2, "echo"))
+		So(inner.seen, ShouldHaveLength, 2)
+	})
+}
+
+func TestCacheReload(t *testing.T) {
+
+	Convey("Given I have a cached chain with a rego policer loaded from a path", t, func() {
+
+		path := filepath.Join(t.TempDir(), "policy.rego")
+		So(os.WriteFile(path, []byte("package main\ndefault allow := true\n"), 0600), ShouldBeNil)
+
+		r, err := NewRegoFromPath(context.Background(), path)
+		So(err, ShouldBeNil)
+
+		chain, err := NewChain([]ChainStage{{Policer: r}})
+		So(err, ShouldBeNil)
+
+		p := NewCache(chain)
+
+		req := api.Request{
+			Type: api.CallTypeRequest,
+			MCP:  mcp.Message{ID: 1, Method: "tools/call", Params: map[string]any{"name": "echo"}},
+		}
+
+		_, err = p.Police(context.Background(), req)
+		So(err, ShouldBeNil)
+
+		Convey("When the policies are reloaded", func() {
+
+			So(os.WriteFile(path, []byte("package main\ndefault allow := false\nreasons := [\"reloaded\"]\n"), 0600), ShouldBeNil)
+			So(r.(*rego.Policer).Reload(), ShouldBeNil)
+
+			Convey("Then the cached decision should not be used", func() {
+				_, err = p.Police(context.Background(), req)
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "request blocked: reloaded")
+			})
+		})
+	})
+}
+
+func TestCacheFailOpen(t *testing.T) {
+
+	Convey("Given I have a cached HTTP policer failing open", t, func() {
+
+		var down atomic.Bool
+		down.Store(true)
+
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			if down.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte(`{"allow":false,"reasons":["denied"]}`))
+		}))
+		defer ts.Close()
+
+		p := NewCache(NewHTTP(ts.URL, nil, nil, OptHTTPRetries(0, 0), OptHTTPFailMode(HTTPFailModeOpen)))
+
+		req := api.Request{
+			Type: api.CallTypeRequest,
+			MCP:  mcp.Message{ID: 1, Method: "tools/call", Params: map[string]any{"name": "echo"}},
+		}
+
+		_, err := p.Police(context.Background(), req)
+		So(err, ShouldBeNil)
+
+		Convey("When the policer recovers", func() {
+
+			down.Store(false)
+
+			Convey("Then the decision allowed while it was down should not be used", func() {
+				_, err := p.Police(context.Background(), req)
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "request blocked: denied")
+			})
+		})
+	})
+}
+
+// disablingPolicer is a policer disabling the
+// cache for all the decisions of its policer.
+type disablingPolicer struct {
+	*fakePolicer
+}
+
+func (p *disablingPolicer) Police(ctx context.Context, req api.Request) (*mcp.Message, error) {
+	api.DisableCache(ctx)
+	return p.fakePolicer.Police(ctx, req)
+}
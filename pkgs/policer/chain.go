@@ -0,0 +1,187 @@
+package policer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"go.acuvity.ai/minibridge/pkgs/mcp"
+	"go.acuvity.ai/minibridge/pkgs/policer/api"
+)
+
+// ChainMode defines how a chain combines the decisions of its stages.
+type ChainMode string
+
+// Various values of ChainMode.
+var (
+	// ChainModeFirstDeny stops at the first stage denying the request.
+	ChainModeFirstDeny ChainMode = "first-deny"
+
+	// ChainModeAllMustAllow runs all the stages, and denies the request
+	// with the reasons of all the stages that denied it.
+	ChainModeAllMustAllow ChainMode = "all-must-allow"
+)
+
+// A ChainStage is a Policer run by a chain.
+type ChainStage struct {
+
+	// Name identifies the stage in the logs and metrics.
+	// It defaults to the type of the Policer.
+	Name string
+
+	// Policer is the Policer run by the stage.
+	Policer Policer
+
+	// LogOnly, if true, only logs the denials of the stage
+	// instead of denying the request.
+	LogOnly bool
+}
+
+type chain struct {
+	stages []ChainStage
+	cfg    chainCfg
+}
+
+// NewChain returns a Policer running the given stages in order.
+// When a stage modifies the MCP call, the next stages police the
+// modified call, and the last modification is returned.
+// When a stage returns api.ErrPending, the next stages still run,
+// and the request is pending approval only if none of them denies it.
+// The modifications made by the stages are returned along with the
+// api.ErrPending error, so the request to approve is the modified one.
+func NewChain(stages []ChainStage, options ...ChainOption) (Policer, error) {
+
+	cfg := newChainCfg()
+	for _, o := range options {
+		o(&cfg)
+	}
+
+	if cfg.mode != ChainModeFirstDeny && cfg.mode != ChainModeAllMustAllow {
+		return nil, fmt.Errorf("invalid chain mode '%s': must be %s or %s", cfg.mode, ChainModeFirstDeny, ChainModeAllMustAllow)
+	}
+
+	if len(stages) == 0 {
+		return nil, fmt.Errorf("at least one stage must be given")
+	}
+
+	names := make(map[string]struct{}, len(stages))
+	out := make([]ChainStage, len(stages))
+
+	for i, s := range stages {
+
+		if s.Policer == nil {
+			return nil, fmt.Errorf("stage %d has no policer", i)
+		}
+
+		if s.Name == "" {
+			s.Name = s.Policer.Type()
+		}
+
+		if _, ok := names[s.Name]; ok {
+			return nil, fmt.Errorf("duplicate stage name '%s'", s.Name)
+		}
+		names[s.Name] = struct{}{}
+
+		out[i] = s
+	}
+
+	return &chain{
+		stages: out,
+		cfg:    cfg,
+	}, nil
+}
+
+func (c *chain) Type() string { return "chain" }
+
+// Generation returns the sum of the generations of the stages.
+func (c *chain) Generation() uint64 {
+
+	var g uint64
+	for _, s := range c.stages {
+		g += generation(s.Policer)
+	}
+
+	return g
+}
+
+func (c *chain) Police(ctx context.Context, req api.Request) (*mcp.Message, error) {
+
+	var modified *mcp.Message
+	var reasons []string
+	var pending []string
+
+	for _, s := range c.stages {
+
+		rcall, err := c.police(ctx, s, req)
+
+		if errors.Is(err, api.ErrPending) {
+
+			if s.LogOnly {
+				slog.Warn("Policer stage requested an approval", "stage", s.Name, "enforced", false, "err", err)
+				continue
+			}
+
+			pending = append(pending, strings.TrimPrefix(err.Error(), api.ErrPending.Error()+": "))
+
+			if rcall != nil {
+				modified = rcall
+				req.MCP = *rcall
+			}
+			continue
+		}
+
+		if err != nil {
+
+			if !errors.Is(err, api.ErrBlocked) {
+				if s.LogOnly && errors.Is(err, api.ErrUnavailable) {
+					slog.Warn("Policer stage unavailable", "stage", s.Name, "enforced", false, "err", err)
+					continue
+				}
+				return nil, fmt.Errorf("unable to run policer stage '%s': %w", s.Name, err)
+			}
+
+			if s.LogOnly {
+				slog.Warn("Policer stage denied the request", "stage", s.Name, "enforced", false, "err", err)
+				continue
+			}
+
+			if c.cfg.mode == ChainModeFirstDeny {
+				return nil, err
+			}
+
+			reasons = append(reasons, strings.TrimPrefix(err.Error(), api.ErrBlocked.Error()+": "))
+			continue
+		}
+
+		if rcall != nil {
+			modified = rcall
+			req.MCP = *rcall
+		}
+	}
+
+	if len(reasons) > 0 {
+		return nil, fmt.Errorf("%w: %s", api.ErrBlocked, strings.Join(reasons, ", "))
+	}
+
+	if len(pending) > 0 {
+		return modified, fmt.Errorf("%w: %s", api.ErrPending, strings.Join(pending, ", "))
+	}
+
+	return modified, nil
+}
+
+func (c *chain) police(ctx context.Context, s ChainStage, req api.Request) (*mcp.Message, error) {
+
+	m := func(bool) time.Duration { return 0 }
+	if mm := c.cfg.metricsManager; mm != nil {
+		m = mm.MeasurePolicer(s.Policer.Type(), s.Name, req.Type)
+	}
+
+	rcall, err := s.Policer.Police(ctx, req)
+	m(err == nil)
+
+	return rcall, err
+}
@@ -8,7 +8,11 @@ import (
 	"go.acuvity.ai/minibridge/pkgs/mcp"
 	"go.acuvity.ai/minibridge/pkgs/policer/api"
 	"go.acuvity.ai/minibridge/pkgs/policer/internal/http"
+	"go.acuvity.ai/minibridge/pkgs/policer/internal/injection"
+	"go.acuvity.ai/minibridge/pkgs/policer/internal/pii"
 	"go.acuvity.ai/minibridge/pkgs/policer/internal/rego"
+	"go.acuvity.ai/minibridge/pkgs/policer/internal/rules"
+	"go.acuvity.ai/minibridge/pkgs/policer/internal/secrets"
 )
 
 // A Policer is the interface of objects that can police request.
@@ -17,12 +21,91 @@ type Policer interface {
 	Type() string
 }
 
+// A Generational Policer can change its decisions over time, for instance
+// when its policies are reloaded. Generation must return a different value
+// each time it does, so the decisions made before can be discarded.
+type Generational interface {
+	Generation() uint64
+}
+
+// generation returns the generation of the given
+// Policer, or 0 if it is not Generational.
+func generation(p Policer) uint64 {
+
+	if g, ok := p.(Generational); ok {
+		return g.Generation()
+	}
+
+	return 0
+}
+
 // NewRego returns a new rego based Policer.
 func NewRego(policy string) (Policer, error) {
 	return rego.New(policy)
 }
 
+// NewRegoFromPath returns a new rego based Policer loading its policies
+// from the given rego file, directory or OPA bundle. If OptRegoWatch is
+// set, the policies are reloaded when the path changes, until ctx is done.
+func NewRegoFromPath(ctx context.Context, path string, opts ...RegoOption) (Policer, error) {
+
+	cfg := newRegoCfg()
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	p, err := rego.NewFromPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.watch {
+		if err := p.Watch(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return p, nil
+}
+
+// NewRules returns a new Policer evaluating the
+// rules described by the given YAML or JSON document.
+func NewRules(config string) (Policer, error) {
+	return rules.New(config)
+}
+
+// NewSecrets returns a new Policer detecting secrets, like keys and tokens,
+// in the arguments of the tool calls and in the tool results. If detectors
+// is empty, all the detectors returned by SecretsDetectors are used.
+func NewSecrets(detectors []string, opts ...DetectionOption) (Policer, error) {
+	return secrets.New(detectors, opts...)
+}
+
+// SecretsDetectors returns the names of the detectors used by NewSecrets.
+func SecretsDetectors() []string {
+	return secrets.Detectors()
+}
+
+// NewPII returns a new Policer processing the PII, like emails or card
+// numbers, found in all the messages. If entities is empty, all the
+// entities returned by PIIEntities are processed.
+func NewPII(entities []string, opts ...PIIOption) (Policer, error) {
+	return pii.New(entities, opts...)
+}
+
+// PIIEntities returns the names of the entities processed by NewPII.
+func PIIEntities() []string {
+	return pii.Entities()
+}
+
+// NewInjection returns a new Policer looking for prompt injections,
+// like hidden instructions or invisible characters, in the descriptions
+// of the tools and in the tool results.
+func NewInjection(opts ...InjectionOption) (Policer, error) {
+	return injection.New(opts...)
+}
+
 // NewHTTP returns a new HTTP based Policer
-func NewHTTP(endpoint string, auth *auth.Auth, tlsConfig *tls.Config) Policer {
-	return http.New(endpoint, auth, tlsConfig)
+func NewHTTP(endpoint string, auth *auth.Auth, tlsConfig *tls.Config, opts ...HTTPOption) Policer {
+	return http.New(endpoint, auth, tlsConfig, opts...)
 }
@@ -0,0 +1,195 @@
+package detect
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"go.acuvity.ai/minibridge/pkgs/mcp"
+	"go.acuvity.ai/minibridge/pkgs/policer/api"
+)
+
+// A Detector finds sensitive data in strings.
+type Detector struct {
+
+	// Name identifies the detector in the
+	// redactions, the reasons and the metrics.
+	Name string
+
+	// Find returns the start and end indexes
+	// of the sensitive data found in s.
+	Find func(s string) [][]int
+}
+
+// Regexp returns a Detector finding the matches of the given expression.
+// If the expression has a group, only the first group is detected, so
+// the surrounding context is not redacted.
+func Regexp(name string, expr string) Detector {
+
+	re := regexp.MustCompile(expr)
+
+	if re.NumSubexp() == 0 {
+		return Detector{
+			Name: name,
+			Find: func(s string) [][]int { return re.FindAllStringIndex(s, -1) },
+		}
+	}
+
+	return Detector{
+		Name: name,
+		Find: func(s string) [][]int {
+			var out [][]int
+			for _, m := range re.FindAllStringSubmatchIndex(s, -1) {
+				if m[2] >= 0 {
+					out = append(out, m[2:4])
+				}
+			}
+			return out
+		},
+	}
+}
+
+// Policer is a Policer running detectors on the arguments of the tool
+// calls and on the tool results, and redacting or blocking the data found.
+type Policer struct {
+	ptype     string
+	detectors []Detector
+	cfg       cfg
+}
+
+// New returns a new Policer of the given type using the given detectors.
+func New(ptype string, detectors []Detector, opts ...Option) *Policer {
+
+	cfg := newCfg()
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	return &Policer{
+		ptype:     ptype,
+		detectors: detectors,
+		cfg:       cfg,
+	}
+}
+
+func (p *Policer) Type() string { return p.ptype }
+
+func (p *Policer) Police(_ context.Context, preq api.Request) (*mcp.Message, error) {
+
+	msg := preq.MCP
+
+	var data any
+	switch {
+	case preq.Type == api.CallTypeRequest && msg.Method == "tools/call":
+		data = msg.Params["arguments"]
+	case preq.Type == api.CallTypeResponse && msg.Result != nil && (preq.OriginMCP.Method == "tools/call" || preq.OriginMCP.Method == ""):
+		data = msg.Result
+	default:
+		return nil, nil
+	}
+
+	counts := map[string]int{}
+	redacted := p.scan(data, counts)
+
+	if len(counts) == 0 {
+		return nil, nil
+	}
+
+	names := slices.Sorted(maps.Keys(counts))
+
+	slog.Debug("Sensitive data detected", "type", p.ptype, "detectors", names, "mode", p.cfg.mode)
+
+	if mm := p.cfg.metricsManager; mm != nil {
+		for name, n := range counts {
+			mm.RecordPolicerDetection(p.ptype, name, n)
+		}
+	}
+
+	if p.cfg.mode == ModeBlock {
+		return nil, fmt.Errorf("%w: %s detected: %s", api.ErrBlocked, p.ptype, strings.Join(names, ", "))
+	}
+
+	if preq.Type == api.CallTypeRequest {
+		msg.Params = maps.Clone(msg.Params)
+		msg.Params["arguments"] = redacted
+	} else {
+		msg.Result, _ = redacted.(map[string]any)
+	}
+
+	return &msg, nil
+}
+
+// scan returns a copy of the given decoded JSON value with the data
+// found by the detectors redacted, and counts the detections.
+func (p *Policer) scan(v any, counts map[string]int) any {
+	return Walk(v, func(s string) string {
+		for _, d := range p.detectors {
+			var n int
+			if s, n = Replace(s, d, func(string) string { return "[REDACTED:" + d.Name + "]" }); n > 0 {
+				counts[d.Name] += n
+			}
+		}
+		return s
+	})
+}
+
+// Walk returns a copy of the given decoded JSON
+// value with its strings transformed by fn.
+func Walk(v any, fn func(string) string) any {
+
+	switch v := v.(type) {
+
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			out[k] = Walk(e, fn)
+		}
+		return out
+
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = Walk(e, fn)
+		}
+		return out
+
+	case string:
+		return fn(v)
+
+	default:
+		return v
+	}
+}
+
+// Replace replaces the data found by the detector in s by
+// the result of fn, and returns the number of replacements.
+// Matches overlapping a previous one are ignored.
+func Replace(s string, d Detector, fn func(match string) string) (string, int) {
+
+	matches := d.Find(s)
+	if len(matches) == 0 {
+		return s, 0
+	}
+
+	slices.SortFunc(matches, func(a, b []int) int { return a[0] - b[0] })
+
+	var sb strings.Builder
+	var n int
+	last := 0
+	for _, m := range matches {
+		if m[0] < last {
+			continue
+		}
+		sb.WriteString(s[last:m[0]])
+		sb.WriteString(fn(s[m[0]:m[1]]))
+		last = m[1]
+		n++
+	}
+	sb.WriteString(s[last:])
+
+	return sb.String(), n
+}
@@ -0,0 +1,43 @@
+package detect
+
+import "go.acuvity.ai/minibridge/pkgs/metrics"
+
+// Mode defines what the policer does with the detections.
+type Mode string
+
+// Various values of Mode.
+const (
+	ModeRedact Mode = "redact"
+	ModeBlock  Mode = "block"
+)
+
+type cfg struct {
+	mode           Mode
+	metricsManager *metrics.Manager
+}
+
+func newCfg() cfg {
+	return cfg{
+		mode: ModeRedact,
+	}
+}
+
+// An Option can be given to New.
+type Option func(*cfg)
+
+// OptMode sets if the detections are redacted (ModeRedact),
+// or if the message is blocked (ModeBlock). The default
+// is ModeRedact.
+func OptMode(mode Mode) Option {
+	return func(c *cfg) {
+		c.mode = mode
+	}
+}
+
+// OptMetricsManager sets the metric manager
+// used to count the detections.
+func OptMetricsManager(m *metrics.Manager) Option {
+	return func(c *cfg) {
+		c.metricsManager = m
+	}
+}
@@ -0,0 +1,82 @@
+package http
+
+import (
+	"sync"
+	"time"
+)
+
+// breaker is a circuit breaker opening after a number of
+// consecutive failures. Once the cooldown is over, a single
+// call is let through to probe if the policer is back.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	failures int
+	openedAt time.Time
+	probing  bool
+
+	sync.Mutex
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+// allow returns true if a call can be made.
+func (b *breaker) allow() bool {
+
+	if b.threshold <= 0 {
+		return true
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+
+	if b.probing || time.Since(b.openedAt) < b.cooldown {
+		return false
+	}
+
+	b.probing = true
+
+	return true
+}
+
+func (b *breaker) success() {
+
+	b.Lock()
+	defer b.Unlock()
+
+	b.failures = 0
+	b.probing = false
+}
+
+// abort ends a call that did not tell if the policer works,
+// like a call canceled by the caller, without counting it.
+func (b *breaker) abort() {
+
+	b.Lock()
+	defer b.Unlock()
+
+	b.probing = false
+}
+
+func (b *breaker) failure() {
+
+	b.Lock()
+	defer b.Unlock()
+
+	b.failures++
+	b.probing = false
+
+	if b.failures >= b.threshold {
+		b.openedAt = time.Now()
+	}
+}
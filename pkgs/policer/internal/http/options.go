@@ -0,0 +1,77 @@
+package http
+
+import "time"
+
+// FailMode defines what happens when
+// the policer is unable to give a decision.
+type FailMode string
+
+// Various values of FailMode.
+const (
+	FailModeClosed FailMode = "closed"
+	FailModeOpen   FailMode = "open"
+)
+
+type cfg struct {
+	timeout          time.Duration
+	retries          int
+	retryBackoff     time.Duration
+	breakerThreshold int
+	breakerCooldown  time.Duration
+	failMode         FailMode
+}
+
+func newCfg() cfg {
+	return cfg{
+		timeout:          10 * time.Second,
+		retries:          2,
+		retryBackoff:     100 * time.Millisecond,
+		breakerThreshold: 5,
+		breakerCooldown:  30 * time.Second,
+		failMode:         FailModeClosed,
+	}
+}
+
+// An Option can be given to New.
+type Option func(*cfg)
+
+// OptTimeout sets the maximum duration of a single call to the
+// policer. A zero duration disables the timeout. The default is 10s.
+func OptTimeout(timeout time.Duration) Option {
+	return func(c *cfg) {
+		c.timeout = timeout
+	}
+}
+
+// OptRetries sets how many times a call failing because the policer
+// is unreachable or in error is retried, waiting a jittered exponential
+// backoff starting at the given duration between attempts.
+// The default is 2 retries with a backoff of 100ms.
+func OptRetries(retries int, backoff time.Duration) Option {
+	return func(c *cfg) {
+		c.retries = retries
+		c.retryBackoff = backoff
+	}
+}
+
+// OptCircuitBreaker sets the number of consecutive failed calls after
+// which the policer is not called anymore for the given cooldown.
+// A threshold of 0 disables the circuit breaker. The default is
+// 5 failures and a cooldown of 30s.
+func OptCircuitBreaker(threshold int, cooldown time.Duration) Option {
+	return func(c *cfg) {
+		c.breakerThreshold = threshold
+		c.breakerCooldown = cooldown
+	}
+}
+
+// OptFailMode sets if the requests should be allowed (FailModeOpen)
+// or denied (FailModeClosed) when the policer cannot be reached, keeps
+// returning retryable statuses, or when the circuit breaker is open.
+// Other errors, like an invalid response, always deny the request.
+// The default is FailModeClosed.
+func OptFailMode(mode FailMode) Option {
+	return func(c *cfg) {
+		c.failMode = mode
+	}
+}
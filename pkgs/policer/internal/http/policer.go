@@ -4,10 +4,14 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"math/rand/v2"
 	"net/http"
 	"strings"
+	"time"
 
 	"go.acuvity.ai/elemental"
 	"go.acuvity.ai/minibridge/pkgs/auth"
@@ -15,23 +19,41 @@ import (
 	"go.acuvity.ai/minibridge/pkgs/policer/api"
 )
 
+// errRetryable wraps the errors that
+// are worth retrying the call for.
+type errRetryable struct {
+	err error
+}
+
+func (e errRetryable) Error() string { return e.err.Error() }
+func (e errRetryable) Unwrap() error { return e.err }
+
 type Policer struct {
 	endpoint string
-	auth     auth.Auth
+	auth     *auth.Auth
 	client   *http.Client
+	breaker  *breaker
+	cfg      cfg
 }
 
 // New returns a new HTTP based Policer.
-func New(endpoint string, auth *auth.Auth, tlsConfig *tls.Config) *Policer {
+func New(endpoint string, auth *auth.Auth, tlsConfig *tls.Config, opts ...Option) *Policer {
+
+	cfg := newCfg()
+	for _, o := range opts {
+		o(&cfg)
+	}
 
 	return &Policer{
 		endpoint: endpoint,
-		auth:     *auth,
+		auth:     auth,
 		client: &http.Client{
 			Transport: &http.Transport{
 				TLSClientConfig: tlsConfig,
 			},
 		},
+		breaker: newBreaker(cfg.breakerThreshold, cfg.breakerCooldown),
+		cfg:     cfg,
 	}
 }
 
@@ -44,6 +66,83 @@ func (p *Policer) Police(ctx context.Context, preq api.Request) (*mcp.Message, e
 		return nil, fmt.Errorf("unable to encode scan request: %w", err)
 	}
 
+	if !p.breaker.allow() {
+		return p.fail(ctx, fmt.Errorf("circuit breaker open"))
+	}
+
+	var sresp *api.Response
+	for attempt := 0; ; attempt++ {
+
+		sresp, err = p.call(ctx, body)
+		if err == nil {
+			p.breaker.success()
+			break
+		}
+
+		// The policer answered, but not with a decision: this
+		// is not an outage, and the fail mode does not apply.
+		if !errors.As(err, &errRetryable{}) {
+			p.breaker.success()
+			return nil, err
+		}
+
+		// A caller giving up does not mean the policer is down,
+		// so it must not count as a failure for the breaker.
+		if ctx.Err() != nil {
+			p.breaker.abort()
+			return p.fail(ctx, err)
+		}
+
+		if attempt >= p.cfg.retries {
+			p.breaker.failure()
+			return p.fail(ctx, err)
+		}
+
+		select {
+		case <-time.After(p.backoff(attempt)):
+		case <-ctx.Done():
+			p.breaker.abort()
+			return p.fail(ctx, err)
+		}
+	}
+
+	if sresp.NoCache {
+		api.DisableCache(ctx)
+	}
+
+	api.UpdateState(ctx, sresp.State)
+
+	if sresp.MCP != nil && preq.MCP.ID != nil {
+		sresp.MCP.ID = preq.MCP.ID
+	}
+
+	if sresp.Allow {
+		return sresp.MCP, nil
+	}
+
+	if sresp.Pending {
+		if len(sresp.Reasons) == 0 {
+			sresp.Reasons = []string{api.GenericPendingReason}
+		}
+		return sresp.MCP, fmt.Errorf("%w: %s", api.ErrPending, strings.Join(sresp.Reasons, ", "))
+	}
+
+	if len(sresp.Reasons) == 0 {
+		sresp.Reasons = []string{api.GenericDenyReason}
+	}
+
+	return nil, fmt.Errorf("%w: %s", api.ErrBlocked, strings.Join(sresp.Reasons, ", "))
+}
+
+// call sends a single request to the policer.
+func (p *Policer) call(ctx context.Context, body []byte) (*api.Response, error) {
+
+	if p.cfg.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.cfg.timeout)
+		defer cancel()
+	}
+
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewBuffer(body))
 	if err != nil {
 		return nil, fmt.Errorf("unable to create new http request: %w", err)
@@ -51,43 +150,65 @@ func (p *Policer) Police(ctx context.Context, preq api.Request) (*mcp.Message, e
 
 	req.Header.Add("Accept", "application/json")
 	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Authorization", p.auth.Encode())
+	if p.auth != nil {
+		req.Header.Add("Authorization", p.auth.Encode())
+	}
 
 	resp, err := p.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("unable to send request: %w", err)
+		return nil, errRetryable{fmt.Errorf("unable to send request: %w", err)}
 	}
+	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode == http.StatusNoContent {
-		return nil, nil
+		return &api.Response{Allow: true}, nil
 	}
 
 	rbody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("unable to read response body: %w", err)
+		return nil, errRetryable{fmt.Errorf("unable to read response body: %w", err)}
 	}
-	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("invalid response from policer `%s`: %s", string(rbody), resp.Status)
+		err := fmt.Errorf("invalid response from policer `%s`: %s", string(rbody), resp.Status)
+		if resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests {
+			return nil, errRetryable{err}
+		}
+		return nil, err
 	}
 
-	sresp := api.Response{}
-	if err := elemental.Decode(elemental.EncodingTypeJSON, rbody, &sresp); err != nil {
+	sresp := &api.Response{}
+	if err := elemental.Decode(elemental.EncodingTypeJSON, rbody, sresp); err != nil {
 		return nil, fmt.Errorf("unable to decode response body: %w", err)
 	}
 
-	if sresp.MCP != nil && preq.MCP.ID != nil {
-		sresp.MCP.ID = preq.MCP.ID
-	}
+	return sresp, nil
+}
 
-	if sresp.Allow {
-		return sresp.MCP, nil
+// backoff returns the time to wait before the next
+// attempt, using an exponential backoff with full jitter.
+func (p *Policer) backoff(attempt int) time.Duration {
+
+	d := p.cfg.retryBackoff << attempt
+	if d <= 0 {
+		return 0
 	}
 
-	if len(sresp.Reasons) == 0 {
-		sresp.Reasons = []string{api.GenericDenyReason}
+	return rand.N(d) // #nosec: G404
+}
+
+// fail handles a call for which the policer could not be reached,
+// or kept failing, allowing or denying the request based on the fail
+// mode. It is not used for the errors returned by a working policer.
+func (p *Policer) fail(ctx context.Context, err error) (*mcp.Message, error) {
+
+	if p.cfg.failMode == FailModeOpen {
+		slog.Warn("HTTP policer unavailable. Allowing the request", "endpoint", p.endpoint, "err", err)
+		// The request is not allowed by the policer, so this
+		// must not be used once the policer is back.
+		api.DisableCache(ctx)
+		return nil, nil
 	}
 
-	return nil, fmt.Errorf("%w: %s", api.ErrBlocked, strings.Join(sresp.Reasons, ", "))
+	return nil, fmt.Errorf("%w: %w", api.ErrUnavailable, err)
 }
@@ -0,0 +1,234 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.acuvity.ai/minibridge/pkgs/auth"
+	"go.acuvity.ai/minibridge/pkgs/policer/api"
+)
+
+// newTestServer returns a server answering with the given
+// handler and counting how many requests it received.
+func newTestServer(handler func(w http.ResponseWriter, n int32)) (*httptest.Server, *atomic.Int32) {
+
+	calls := &atomic.Int32{}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		handler(w, calls.Add(1))
+	}))
+
+	return ts, calls
+}
+
+func TestPolicer(t *testing.T) {
+
+	req := api.Request{Type: api.CallTypeRequest}
+
+	Convey("Given I have a policer allowing the requests", t, func() {
+
+		ts, _ := newTestServer(func(w http.ResponseWriter, _ int32) { w.WriteHeader(http.StatusNoContent) })
+		defer ts.Close()
+
+		p := New(ts.URL, auth.NewBearerAuth("token"), nil)
+
+		msg, err := p.Police(context.Background(), req)
+		So(err, ShouldBeNil)
+		So(msg, ShouldBeNil)
+	})
+
+	Convey("Given I have a policer denying the requests", t, func() {
+
+		ts, _ := newTestServer(func(w http.ResponseWriter, _ int32) {
+			_, _ = w.Write([]byte(`{"allow":false,"reasons":["nope"]}`))
+		})
+		defer ts.Close()
+
+		p := New(ts.URL, nil, nil)
+
+		_, err := p.Police(context.Background(), req)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "request blocked: nope")
+	})
+
+	Convey("Given I have a policer requiring an approval", t, func() {
+
+		ts, _ := newTestServer(func(w http.ResponseWriter, _ int32) {
+			_, _ = w.Write([]byte(`{"allow":false,"pending":true}`))
+		})
+		defer ts.Close()
+
+		p := New(ts.URL, nil, nil)
+
+		_, err := p.Police(context.Background(), req)
+		So(errors.Is(err, api.ErrPending), ShouldBeTrue)
+		So(err.Error(), ShouldEqual, "request pending approval: "+api.GenericPendingReason)
+	})
+
+	Convey("Given I have a policer updating the session state", t, func() {
+
+		ts, _ := newTestServer(func(w http.ResponseWriter, _ int32) {
+			_, _ = w.Write([]byte(`{"allow":true,"state":{"calls":3,"last":null}}`))
+		})
+		defer ts.Close()
+
+		p := New(ts.URL, nil, nil)
+
+		ctx, updates := api.WithStateUpdates(context.Background())
+		ctx, cacheable := api.WithCacheControl(ctx)
+
+		_, err := p.Police(ctx, req)
+		So(err, ShouldBeNil)
+		So(updates(), ShouldResemble, map[string]any{"calls": 3.0, "last": nil})
+		So(cacheable(), ShouldBeFalse)
+	})
+
+	Convey("Given I have a policer failing before allowing", t, func() {
+
+		ts, calls := newTestServer(func(w http.ResponseWriter, n int32) {
+			if n < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+		defer ts.Close()
+
+		p := New(ts.URL, nil, nil, OptRetries(2, time.Millisecond))
+
+		_, err := p.Police(context.Background(), req)
+		So(err, ShouldBeNil)
+		So(calls.Load(), ShouldEqual, 3)
+	})
+
+	Convey("Given I have a policer rejecting the requests", t, func() {
+
+		ts, calls := newTestServer(func(w http.ResponseWriter, _ int32) { w.WriteHeader(http.StatusBadRequest) })
+		defer ts.Close()
+
+		p := New(ts.URL, nil, nil, OptRetries(2, time.Millisecond), OptFailMode(FailModeOpen))
+
+		_, err := p.Police(context.Background(), req)
+		So(err, ShouldNotBeNil)
+		So(errors.Is(err, api.ErrUnavailable), ShouldBeFalse)
+		So(calls.Load(), ShouldEqual, 1)
+	})
+
+	Convey("Given I have a policer returning an invalid body", t, func() {
+
+		ts, calls := newTestServer(func(w http.ResponseWriter, _ int32) {
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte("nope"))
+		})
+		defer ts.Close()
+
+		p := New(ts.URL, nil, nil, OptRetries(2, time.Millisecond), OptFailMode(FailModeOpen))
+
+		_, err := p.Police(context.Background(), req)
+		So(err, ShouldNotBeNil)
+		So(errors.Is(err, api.ErrUnavailable), ShouldBeFalse)
+		So(calls.Load(), ShouldEqual, 1)
+	})
+
+	Convey("Given I have a slow policer", t, func() {
+
+		done := make(chan struct{})
+		ts, calls := newTestServer(func(w http.ResponseWriter, _ int32) { <-done })
+		defer ts.Close()
+		defer close(done)
+
+		Convey("When the fail mode is closed", func() {
+
+			p := New(ts.URL, nil, nil, OptTimeout(20*time.Millisecond), OptRetries(1, time.Millisecond))
+
+			_, err := p.Police(context.Background(), req)
+			So(err, ShouldNotBeNil)
+			So(errors.Is(err, api.ErrUnavailable), ShouldBeTrue)
+			So(errors.Is(err, context.DeadlineExceeded), ShouldBeTrue)
+			So(calls.Load(), ShouldEqual, 2)
+		})
+
+		Convey("When the fail mode is open", func() {
+
+			p := New(ts.URL, nil, nil, OptTimeout(20*time.Millisecond), OptRetries(0, 0), OptFailMode(FailModeOpen))
+
+			msg, err := p.Police(context.Background(), req)
+			So(err, ShouldBeNil)
+			So(msg, ShouldBeNil)
+		})
+	})
+
+	Convey("Given I have a slow policer with a circuit breaker", t, func() {
+
+		ts, calls := newTestServer(func(w http.ResponseWriter, n int32) {
+			if n == 1 {
+				time.Sleep(100 * time.Millisecond)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+		defer ts.Close()
+
+		p := New(ts.URL, nil, nil, OptRetries(0, 0), OptCircuitBreaker(1, time.Minute))
+
+		Convey("When the caller gives up", func() {
+
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+
+			_, err := p.Police(ctx, req)
+			So(err, ShouldNotBeNil)
+
+			Convey("Then the breaker should not be opened", func() {
+				_, err := p.Police(context.Background(), req)
+				So(err, ShouldBeNil)
+				So(calls.Load(), ShouldEqual, 2)
+			})
+		})
+	})
+
+	Convey("Given I have a failing policer with a circuit breaker", t, func() {
+
+		failing := atomic.Bool{}
+		failing.Store(true)
+
+		ts, calls := newTestServer(func(w http.ResponseWriter, _ int32) {
+			if failing.Load() {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+		defer ts.Close()
+
+		p := New(ts.URL, nil, nil, OptRetries(0, 0), OptCircuitBreaker(2, 50*time.Millisecond))
+
+		_, err := p.Police(context.Background(), req)
+		So(err, ShouldNotBeNil)
+		_, err = p.Police(context.Background(), req)
+		So(err, ShouldNotBeNil)
+
+		Convey("Then the policer should not be called while the breaker is open", func() {
+
+			_, err := p.Police(context.Background(), req)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "policer unavailable: circuit breaker open")
+			So(calls.Load(), ShouldEqual, 2)
+
+			Convey("When the cooldown is over and the policer is back", func() {
+
+				time.Sleep(60 * time.Millisecond)
+				failing.Store(false)
+
+				_, err := p.Police(context.Background(), req)
+				So(err, ShouldBeNil)
+				So(calls.Load(), ShouldEqual, 3)
+			})
+		})
+	})
+}
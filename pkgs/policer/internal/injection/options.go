@@ -0,0 +1,43 @@
+package injection
+
+import "go.acuvity.ai/minibridge/pkgs/metrics"
+
+// Mode defines what the policer does with the injections.
+type Mode string
+
+// Various values of Mode.
+const (
+	ModeBlock Mode = "block"
+	ModeStrip Mode = "strip"
+)
+
+type cfg struct {
+	mode           Mode
+	metricsManager *metrics.Manager
+}
+
+func newCfg() cfg {
+	return cfg{
+		mode: ModeBlock,
+	}
+}
+
+// An Option can be given to New.
+type Option func(*cfg)
+
+// OptMode sets if the message is blocked (ModeBlock), or if the
+// injections are stripped from it (ModeStrip). The default
+// is ModeBlock.
+func OptMode(mode Mode) Option {
+	return func(c *cfg) {
+		c.mode = mode
+	}
+}
+
+// OptMetricsManager sets the metric manager
+// used to count the injections.
+func OptMetricsManager(m *metrics.Manager) Option {
+	return func(c *cfg) {
+		c.metricsManager = m
+	}
+}
@@ -0,0 +1,191 @@
+package injection
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+
+	"go.acuvity.ai/minibridge/pkgs/mcp"
+	"go.acuvity.ai/minibridge/pkgs/policer/api"
+	"go.acuvity.ai/minibridge/pkgs/policer/internal/detect"
+	"go.acuvity.ai/minibridge/pkgs/scan"
+)
+
+// Policer is a Policer looking for prompt injections in
+// the descriptions of the tools listed by the MCP server
+// and in the tool results, and blocking or stripping them.
+type Policer struct {
+	cfg cfg
+}
+
+// New returns a new Policer.
+func New(opts ...Option) (*Policer, error) {
+
+	cfg := newCfg()
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	if cfg.mode != ModeBlock && cfg.mode != ModeStrip {
+		return nil, fmt.Errorf("invalid mode '%s': must be block or strip", cfg.mode)
+	}
+
+	return &Policer{
+		cfg: cfg,
+	}, nil
+}
+
+func (p *Policer) Type() string { return "injection" }
+
+func (p *Policer) Police(_ context.Context, preq api.Request) (*mcp.Message, error) {
+
+	msg := preq.MCP
+
+	if preq.Type != api.CallTypeResponse || msg.Result == nil {
+		return nil, nil
+	}
+
+	var injections scan.Injections
+	var stripped map[string]any
+
+	switch preq.OriginMCP.Method {
+
+	case "tools/list":
+
+		tools, ok := msg.Result["tools"].([]any)
+		if !ok {
+			return nil, nil
+		}
+
+		var strippedTools []any
+		injections, strippedTools = inspectTools(tools)
+
+		stripped = maps.Clone(msg.Result)
+		stripped["tools"] = strippedTools
+
+	case "tools/call", "":
+
+		location := "tool result"
+		if name, ok := preq.OriginMCP.Params["name"].(string); ok {
+			location = fmt.Sprintf("tool '%s' result", name)
+		}
+
+		stripped, _ = detect.Walk(msg.Result, func(s string) string {
+			found := scan.InspectText(location, s, nil)
+			if len(found) == 0 {
+				return s
+			}
+			injections = append(injections, found...)
+			return scan.StripText(s, nil)
+		}).(map[string]any)
+
+	default:
+		return nil, nil
+	}
+
+	if len(injections) == 0 {
+		return nil, nil
+	}
+
+	slog.Debug("Prompt injections detected", "injections", injections.String(), "mode", p.cfg.mode)
+
+	if mm := p.cfg.metricsManager; mm != nil {
+		counts := map[string]int{}
+		for _, i := range injections {
+			counts[i.Kind]++
+		}
+		for kind, n := range counts {
+			mm.RecordPolicerDetection("injection", kind, n)
+		}
+	}
+
+	if p.cfg.mode == ModeBlock {
+		return nil, fmt.Errorf("%w: prompt injection detected: %s", api.ErrBlocked, injections)
+	}
+
+	msg.Result = stripped
+
+	return &msg, nil
+}
+
+// inspectTools returns the injections found in the descriptions of the
+// given decoded tools, and a copy of the tools with the injections stripped.
+func inspectTools(tools []any) (scan.Injections, []any) {
+
+	names := make([]string, 0, len(tools))
+	for _, t := range tools {
+		if tool, ok := t.(map[string]any); ok {
+			if name, ok := tool["name"].(string); ok {
+				names = append(names, name)
+			}
+		}
+	}
+
+	var injections scan.Injections
+
+	inspect := func(location string, desc string, others []string) (string, bool) {
+		found := scan.InspectText(location, desc, others)
+		if len(found) == 0 {
+			return desc, false
+		}
+		injections = append(injections, found...)
+		return scan.StripText(desc, others), true
+	}
+
+	out := make([]any, len(tools))
+
+	for i, t := range tools {
+
+		out[i] = t
+
+		tool, ok := t.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		name, _ := tool["name"].(string)
+		others := scan.OtherTools(names, name)
+		tool = maps.Clone(tool)
+
+		if desc, ok := tool["description"].(string); ok {
+			tool["description"], _ = inspect(scan.ToolLocation(name, ""), desc, others)
+		}
+
+		schema, _ := tool["inputSchema"].(map[string]any)
+		props, _ := schema["properties"].(map[string]any)
+		props = maps.Clone(props)
+
+		var changed bool
+		for _, pk := range slices.Sorted(maps.Keys(props)) {
+
+			prop, ok := props[pk].(map[string]any)
+			if !ok {
+				continue
+			}
+
+			pdesc, ok := prop["description"].(string)
+			if !ok {
+				continue
+			}
+
+			if pdesc, ok = inspect(scan.ToolLocation(name, pk), pdesc, others); ok {
+				prop = maps.Clone(prop)
+				prop["description"] = pdesc
+				props[pk] = prop
+				changed = true
+			}
+		}
+
+		if changed {
+			schema = maps.Clone(schema)
+			schema["properties"] = props
+			tool["inputSchema"] = schema
+		}
+
+		out[i] = tool
+	}
+
+	return injections, out
+}
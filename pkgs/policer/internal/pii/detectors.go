@@ -0,0 +1,185 @@
+package pii
+
+import (
+	"math/big"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.acuvity.ai/minibridge/pkgs/policer/internal/detect"
+)
+
+var (
+	cardCandidate = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+	ibanCandidate = regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]){11,30}\b`)
+	ssnCandidate  = regexp.MustCompile(`\b(\d{3})-(\d{2})-(\d{4})\b`)
+	phoneFormat   = regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?(?:\(\d{2,4}\)|\b\d{2,4})[ .-]\d{3,4}[ .-]\d{3,4}\b|\+\d{8,15}\b`)
+	ipv6Candidate = regexp.MustCompile(`(?i)(?:[0-9a-f]{0,4}:){2,7}[0-9a-f]{0,4}`)
+)
+
+// detectors are the available detectors, in the order they run.
+// Emails run first, as their domains could contain other entities.
+var detectors = []detect.Detector{
+	detect.Regexp("email", `[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	{Name: "iban", Find: findIBANs},
+	{Name: "credit-card", Find: findCards},
+	{Name: "national-id", Find: findNationalIDs},
+	{Name: "phone", Find: findPhones},
+	{Name: "ip", Find: findIPs},
+}
+
+// Entities returns the names of the available entities.
+func Entities() []string {
+
+	names := make([]string, len(detectors))
+	for i, d := range detectors {
+		names[i] = d.Name
+	}
+
+	return names
+}
+
+// findCards finds the card numbers passing the Luhn check.
+func findCards(s string) [][]int {
+
+	var out [][]int
+	for _, m := range cardCandidate.FindAllStringIndex(s, -1) {
+		if luhn(digits(s[m[0]:m[1]])) {
+			out = append(out, m)
+		}
+	}
+
+	return out
+}
+
+// findIBANs finds the IBANs passing the mod 97 check.
+func findIBANs(s string) [][]int {
+
+	var out [][]int
+	for _, m := range ibanCandidate.FindAllStringIndex(s, -1) {
+		if validIBAN(strings.ReplaceAll(s[m[0]:m[1]], " ", "")) {
+			out = append(out, m)
+		}
+	}
+
+	return out
+}
+
+// findPhones finds the phone numbers. The matches that are
+// part of a longer group of numbers, like an invalid card
+// number, are ignored.
+func findPhones(s string) [][]int {
+
+	var out [][]int
+	for _, m := range phoneFormat.FindAllStringIndex(s, -1) {
+		if m[0] >= 2 && isSeparator(s[m[0]-1]) && isDigit(s[m[0]-2]) {
+			continue
+		}
+		if m[1]+1 < len(s) && isSeparator(s[m[1]]) && isDigit(s[m[1]+1]) {
+			continue
+		}
+		out = append(out, m)
+	}
+
+	return out
+}
+
+var ninoFormat = regexp.MustCompile(`\b[A-CEGHJ-PR-TW-Z][A-CEGHJ-NPR-TW-Z] ?\d{2} ?\d{2} ?\d{2} ?[A-D]\b`)
+
+// findNationalIDs finds the US social security
+// numbers and the UK national insurance numbers.
+func findNationalIDs(s string) [][]int {
+
+	var out [][]int
+	for _, m := range ssnCandidate.FindAllStringSubmatchIndex(s, -1) {
+		area, group, serial := s[m[2]:m[3]], s[m[4]:m[5]], s[m[6]:m[7]]
+		if area == "000" || area == "666" || area[0] == '9' || group == "00" || serial == "0000" {
+			continue
+		}
+		out = append(out, m[0:2])
+	}
+
+	return append(out, ninoFormat.FindAllStringIndex(s, -1)...)
+}
+
+var ipv4Format = regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\.){3}(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\b`)
+
+// findIPs finds the IPv4 and IPv6 addresses.
+func findIPs(s string) [][]int {
+
+	out := ipv4Format.FindAllStringIndex(s, -1)
+
+	// Short candidates, or candidates without digits, are
+	// ignored to not match things like C++ scopes.
+	for _, m := range ipv6Candidate.FindAllStringIndex(s, -1) {
+		c := s[m[0]:m[1]]
+		if len(c) < 6 || digits(c) == "" {
+			continue
+		}
+		if ip := net.ParseIP(c); ip != nil && ip.To4() == nil {
+			out = append(out, m)
+		}
+	}
+
+	return out
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isSeparator(c byte) bool { return c == ' ' || c == '.' || c == '-' }
+
+func digits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+func luhn(number string) bool {
+
+	var sum int
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		d := int(number[i] - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+
+	return sum%10 == 0
+}
+
+func validIBAN(iban string) bool {
+
+	if len(iban) < 15 || len(iban) > 34 {
+		return false
+	}
+
+	// Move the country and check digits to the
+	// end, and convert the letters to numbers.
+	var sb strings.Builder
+	for _, r := range iban[4:] + iban[:4] {
+		switch {
+		case r >= '0' && r <= '9':
+			sb.WriteRune(r)
+		case r >= 'A' && r <= 'Z':
+			sb.WriteString(strconv.Itoa(int(r-'A') + 10))
+		default:
+			return false
+		}
+	}
+
+	n, ok := new(big.Int).SetString(sb.String(), 10)
+	if !ok {
+		return false
+	}
+
+	return new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
@@ -0,0 +1,89 @@
+package pii
+
+import (
+	"time"
+
+	"go.acuvity.ai/minibridge/pkgs/metrics"
+)
+
+// Mode defines how an entity is processed.
+type Mode string
+
+// Various values of Mode.
+const (
+	// ModeMask replaces the letters and digits of
+	// the entity by '*', except the last ones.
+	ModeMask Mode = "mask"
+
+	// ModeHash replaces the entity by a keyed hash, so
+	// the same entity can still be correlated.
+	ModeHash Mode = "hash"
+
+	// ModeTokenize replaces the entity by a token. The
+	// tokens sent back by the agent in the same session are
+	// replaced by their entity before reaching the MCP server.
+	ModeTokenize Mode = "tokenize"
+)
+
+type cfg struct {
+	mode           Mode
+	entityModes    map[string]Mode
+	hashKey        []byte
+	tokenTTL       time.Duration
+	tokenMaxSize   int64
+	metricsManager *metrics.Manager
+}
+
+func newCfg() cfg {
+	return cfg{
+		mode:         ModeMask,
+		tokenTTL:     24 * time.Hour,
+		tokenMaxSize: 100000,
+	}
+}
+
+// An Option can be given to New.
+type Option func(*cfg)
+
+// OptMode sets the mode used for the entities
+// without a mode set by OptEntityModes.
+// The default is ModeMask.
+func OptMode(mode Mode) Option {
+	return func(c *cfg) {
+		c.mode = mode
+	}
+}
+
+// OptEntityModes sets the mode to use for given entities.
+func OptEntityModes(modes map[string]Mode) Option {
+	return func(c *cfg) {
+		c.entityModes = modes
+	}
+}
+
+// OptHashKey sets the key used to hash and tokenize the entities.
+// Setting the same key on several backends gives the same hashes.
+// By default, a random key is generated.
+func OptHashKey(key []byte) Option {
+	return func(c *cfg) {
+		c.hashKey = key
+	}
+}
+
+// OptTokens sets how long, and how many, tokens are kept
+// to be replaced by their entity. The defaults are 24h
+// and 100000 tokens.
+func OptTokens(ttl time.Duration, maxSize int64) Option {
+	return func(c *cfg) {
+		c.tokenTTL = ttl
+		c.tokenMaxSize = maxSize
+	}
+}
+
+// OptMetricsManager sets the metric manager
+// used to count the entities found.
+func OptMetricsManager(m *metrics.Manager) Option {
+	return func(c *cfg) {
+		c.metricsManager = m
+	}
+}
@@ -0,0 +1,237 @@
+package pii
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/karlseguin/ccache/v3"
+	"go.acuvity.ai/minibridge/pkgs/mcp"
+	"go.acuvity.ai/minibridge/pkgs/policer/api"
+	"go.acuvity.ai/minibridge/pkgs/policer/internal/detect"
+)
+
+var tokenFormat = regexp.MustCompile(`\[TOKEN:[a-z-]+:[a-p]{16}\]`)
+
+// Policer is a Policer processing the PII found in all
+// the messages, in both directions, so PII never goes
+// through minibridge unprocessed. The tokens can only
+// be restored in the session they were created in.
+type Policer struct {
+	detectors []detect.Detector
+	modes     map[string]Mode
+	hashKey   []byte
+	tokens    *ccache.Cache[string]
+	cfg       cfg
+}
+
+// New returns a new Policer processing the given entities.
+// If entities is empty, all the entities are processed.
+func New(entities []string, opts ...Option) (*Policer, error) {
+
+	cfg := newCfg()
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	if len(entities) == 0 {
+		entities = Entities()
+	}
+
+	p := &Policer{
+		modes:   make(map[string]Mode, len(entities)),
+		hashKey: cfg.hashKey,
+		tokens:  ccache.New(ccache.Configure[string]().MaxSize(cfg.tokenMaxSize)),
+		cfg:     cfg,
+	}
+
+	for _, d := range detectors {
+		if slices.Contains(entities, d.Name) {
+			p.detectors = append(p.detectors, d)
+			p.modes[d.Name] = cfg.mode
+		}
+	}
+
+	for _, e := range entities {
+		if _, ok := p.modes[e]; !ok {
+			return nil, fmt.Errorf("unknown pii entity '%s': must be one of %s", e, strings.Join(Entities(), ", "))
+		}
+	}
+
+	for e, m := range cfg.entityModes {
+		if _, ok := p.modes[e]; !ok {
+			return nil, fmt.Errorf("cannot set mode of entity '%s': entity is not processed", e)
+		}
+		p.modes[e] = m
+	}
+
+	for e, m := range p.modes {
+		if m != ModeMask && m != ModeHash && m != ModeTokenize {
+			return nil, fmt.Errorf("invalid mode '%s' for entity '%s': must be mask, hash or tokenize", m, e)
+		}
+	}
+
+	if len(p.hashKey) == 0 {
+		p.hashKey = make([]byte, 32)
+		if _, err := rand.Read(p.hashKey); err != nil {
+			return nil, fmt.Errorf("unable to generate hash key: %w", err)
+		}
+	}
+
+	return p, nil
+}
+
+func (p *Policer) Type() string { return "pii" }
+
+func (p *Policer) Police(ctx context.Context, preq api.Request) (*mcp.Message, error) {
+
+	if preq.Type != api.CallTypeRequest && preq.Type != api.CallTypeResponse {
+		return nil, nil
+	}
+
+	counts := map[string]int{}
+	var restored int
+	var tokenized bool
+
+	process := func(s string) string {
+
+		for _, d := range p.detectors {
+			var n int
+			if s, n = detect.Replace(s, d, func(v string) string { return p.replace(preq.Session.ID, d.Name, v) }); n > 0 {
+				counts[d.Name] += n
+				tokenized = tokenized || p.modes[d.Name] == ModeTokenize
+			}
+		}
+
+		// The tokens sent by the agent are replaced after the
+		// detection, as the MCP server is allowed to see them.
+		if preq.Type == api.CallTypeRequest {
+			s = tokenFormat.ReplaceAllStringFunc(s, func(token string) string {
+				if item := p.tokens.Get(tokenKey(preq.Session.ID, token)); item != nil && !item.Expired() {
+					restored++
+					return item.Value()
+				}
+				return token
+			})
+		}
+
+		return s
+	}
+
+	msg := preq.MCP
+
+	if msg.Params != nil {
+		msg.Params, _ = detect.Walk(msg.Params, process).(map[string]any)
+	}
+
+	if msg.Result != nil {
+		msg.Result, _ = detect.Walk(msg.Result, process).(map[string]any)
+	}
+
+	if msg.Error != nil {
+		merr := *msg.Error
+		merr.Message = process(merr.Message)
+		merr.Data = detect.Walk(merr.Data, process)
+		msg.Error = &merr
+	}
+
+	// The tokens are stored per session, which
+	// the decisions of the cache do not depend on.
+	if tokenized || restored > 0 {
+		api.DisableCache(ctx)
+	}
+
+	if len(counts) == 0 && restored == 0 {
+		return nil, nil
+	}
+
+	slog.Debug("PII processed", "entities", counts, "restored", restored)
+
+	if mm := p.cfg.metricsManager; mm != nil {
+		for name, n := range counts {
+			mm.RecordPolicerDetection("pii", name, n)
+		}
+	}
+
+	return &msg, nil
+}
+
+// replace returns what replaces the given entity value
+// in a message of the session with the given ID.
+func (p *Policer) replace(session string, entity string, value string) string {
+
+	switch p.modes[entity] {
+
+	case ModeHash:
+		return fmt.Sprintf("[HASH:%s:%s]", entity, p.hash("hash", entity, value))
+
+	case ModeTokenize:
+		token := fmt.Sprintf("[TOKEN:%s:%s]", entity, p.hash("token", entity, value))
+		p.tokens.Set(tokenKey(session, token), value, p.cfg.tokenTTL)
+		return token
+
+	default:
+		return mask(entity, value)
+	}
+}
+
+// tokenKey returns the key of the given token in
+// the store of the session with the given ID.
+func tokenKey(session string, token string) string {
+	return session + ":" + token
+}
+
+// hash returns a keyed hash of the given value. It is encoded
+// with the letters a to p instead of hexadecimal digits, so the
+// detectors looking for numbers never match it.
+func (p *Policer) hash(purpose string, entity string, value string) string {
+
+	h := hmac.New(sha256.New, p.hashKey)
+	_, _ = h.Write([]byte(purpose + ":" + entity + ":" + value))
+
+	return strings.Map(func(r rune) rune {
+		if r <= '9' {
+			return 'a' + r - '0'
+		}
+		return r - 'a' + 'k'
+	}, hex.EncodeToString(h.Sum(nil)[:8]))
+}
+
+// mask replaces the letters and digits of the given value by '*'.
+// The domain of the emails, and the last 4 letters and digits of
+// the numbers, like card or phone numbers, are kept.
+func mask(entity string, value string) string {
+
+	keep := 0
+	switch entity {
+	case "email":
+		if i := strings.LastIndex(value, "@"); i >= 0 {
+			return strings.Repeat("*", len(value[:i])) + value[i:]
+		}
+	case "ip":
+	default:
+		keep = 4
+	}
+
+	out := []byte(value)
+	for i := len(out) - 1; i >= 0; i-- {
+		c := out[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') {
+			continue
+		}
+		if keep > 0 {
+			keep--
+			continue
+		}
+		out[i] = '*'
+	}
+
+	return string(out)
+}
@@ -0,0 +1,173 @@
+package pii
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.acuvity.ai/minibridge/pkgs/mcp"
+	"go.acuvity.ai/minibridge/pkgs/policer/api"
+)
+
+func police(p *Policer, rtype api.CallType, text string) string {
+
+	msg, err := p.Police(context.Background(), api.Request{
+		Type: rtype,
+		MCP:  mcp.Message{ID: 1, Method: "tools/call", Params: map[string]any{"arguments": map[string]any{"text": text}}},
+	})
+	So(err, ShouldBeNil)
+
+	if msg == nil {
+		return text
+	}
+
+	So(msg.ID, ShouldEqual, 1)
+
+	return msg.Params["arguments"].(map[string]any)["text"].(string)
+}
+
+func TestDetectors(t *testing.T) {
+
+	Convey("Given I have a policer masking all the entities", t, func() {
+
+		p, err := New(nil)
+		So(err, ShouldBeNil)
+		So(p.Type(), ShouldEqual, "pii")
+
+		for input, expected := range map[string]string{
+			"mail john.doe@example.com now":      "mail ********@example.com now",
+			"card 4111 1111 1111 1111":           "card **** **** **** 1111",
+			"card 4111 1111 1111 1112":           "card 4111 1111 1111 1112",
+			"iban GB82 WEST 1234 5698 7654 32":   "iban **** **** **** **** **54 32",
+			"iban GB82 WEST 1234 5698 7654 33":   "iban GB82 WEST 1234 5698 7654 33",
+			"ssn 123-45-6789":                    "ssn ***-**-6789",
+			"ssn 666-45-6789":                    "ssn 666-45-6789",
+			"nino AB 12 34 56 C":                 "nino ** ** *4 56 C",
+			"call +1 (415) 555-0100":             "call +* (***) ***-0100",
+			"call 0612345678 or +33612345678":    "call 0612345678 or +*******5678",
+			"from 192.168.1.10":                  "from ***.***.*.**",
+			"from 2001:db8::8a2e:370:7334":       "from ****:***::****:***:****",
+			"std::vector<int> at 10:30:00":       "std::vector<int> at 10:30:00",
+			"on 2024-01-15, version 1.2.3":       "on 2024-01-15, version 1.2.3",
+			"sha e3b0c44298fc1c149afbf4c8996fb9": "sha e3b0c44298fc1c149afbf4c8996fb9",
+		} {
+			So(police(p, api.CallTypeRequest, input), ShouldEqual, expected)
+		}
+	})
+
+	Convey("Given I select unknown entities", t, func() {
+		_, err := New([]string{"email", "nope"})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "unknown pii entity 'nope': must be one of "+strings.Join(Entities(), ", "))
+	})
+
+	Convey("Given I set the mode of an entity not processed", t, func() {
+		_, err := New([]string{"email"}, OptEntityModes(map[string]Mode{"ip": ModeHash}))
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "cannot set mode of entity 'ip': entity is not processed")
+	})
+
+	Convey("Given I set an invalid mode", t, func() {
+		_, err := New([]string{"email"}, OptMode("nope"))
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "invalid mode 'nope' for entity 'email': must be mask, hash or tokenize")
+	})
+}
+
+func TestPolicer(t *testing.T) {
+
+	Convey("Given I have a policer hashing emails and tokenizing cards", t, func() {
+
+		p, err := New(
+			[]string{"email", "credit-card"},
+			OptMode(ModeHash),
+			OptEntityModes(map[string]Mode{"credit-card": ModeTokenize}),
+			OptHashKey([]byte("key")),
+		)
+		So(err, ShouldBeNil)
+
+		Convey("Then the emails should be hashed with the key", func() {
+
+			hashed := police(p, api.CallTypeResponse, "john@example.com")
+			So(regexp.MustCompile(`^\[HASH:email:[a-p]{16}\]$`).MatchString(hashed), ShouldBeTrue)
+			So(police(p, api.CallTypeResponse, "john@example.com"), ShouldEqual, hashed)
+
+			other, err := New([]string{"email"}, OptMode(ModeHash), OptHashKey([]byte("other")))
+			So(err, ShouldBeNil)
+			So(police(other, api.CallTypeResponse, "john@example.com"), ShouldNotEqual, hashed)
+		})
+
+		Convey("Then the cards should be tokenized and restored", func() {
+
+			msg, err := p.Police(context.Background(), api.Request{
+				Type: api.CallTypeResponse,
+				MCP: mcp.Message{ID: 1, Result: map[string]any{
+					"content": []any{map[string]any{"type": "text", "text": "your card is 4111111111111111"}},
+				}},
+			})
+			So(err, ShouldBeNil)
+
+			text := msg.Result["content"].([]any)[0].(map[string]any)["text"].(string)
+			So(text, ShouldStartWith, "your card is [TOKEN:credit-card:")
+
+			token := regexp.MustCompile(`\[TOKEN:.*\]`).FindString(text)
+
+			So(police(p, api.CallTypeRequest, "pay with "+token), ShouldEqual, "pay with 4111111111111111")
+			So(police(p, api.CallTypeRequest, "pay with [TOKEN:credit-card:aaaaaaaaaaaaaaaa]"), ShouldEqual, "pay with [TOKEN:credit-card:aaaaaaaaaaaaaaaa]")
+		})
+
+		Convey("Then the tokens should only be restored in their session", func() {
+
+			call := func(rtype api.CallType, session string, text string) (string, bool) {
+
+				ctx, cacheable := api.WithCacheControl(context.Background())
+
+				msg, err := p.Police(ctx, api.Request{
+					Type:    rtype,
+					MCP:     mcp.Message{ID: 1, Method: "tools/call", Params: map[string]any{"arguments": map[string]any{"text": text}}},
+					Session: api.Session{ID: session},
+				})
+				So(err, ShouldBeNil)
+
+				if msg == nil {
+					return text, cacheable()
+				}
+
+				return msg.Params["arguments"].(map[string]any)["text"].(string), cacheable()
+			}
+
+			text, cacheable := call(api.CallTypeResponse, "a", "your card is 4111111111111111")
+			So(cacheable, ShouldBeFalse)
+
+			token := regexp.MustCompile(`\[TOKEN:.*\]`).FindString(text)
+			So(token, ShouldNotBeEmpty)
+
+			text, cacheable = call(api.CallTypeRequest, "b", "pay with "+token)
+			So(text, ShouldEqual, "pay with "+token)
+			So(cacheable, ShouldBeTrue)
+
+			text, cacheable = call(api.CallTypeRequest, "a", "pay with "+token)
+			So(text, ShouldEqual, "pay with 4111111111111111")
+			So(cacheable, ShouldBeFalse)
+		})
+
+		Convey("Then the errors should be processed", func() {
+
+			msg, err := p.Police(context.Background(), api.Request{
+				Type: api.CallTypeResponse,
+				MCP:  mcp.Message{ID: 1, Error: &mcp.Error{Code: 404, Message: "unknown user john@example.com"}},
+			})
+			So(err, ShouldBeNil)
+			So(msg.Error.Message, ShouldStartWith, "unknown user [HASH:email:")
+		})
+
+		Convey("Then the session calls should be ignored", func() {
+
+			msg, err := p.Police(context.Background(), api.Request{Type: api.CallTypeSessionStart})
+			So(err, ShouldBeNil)
+			So(msg, ShouldBeNil)
+		})
+	})
+}
@@ -5,19 +5,34 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-viper/mapstructure/v2"
 	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/open-policy-agent/opa/v1/loader"
 	"github.com/open-policy-agent/opa/v1/rego"
+	"github.com/open-policy-agent/opa/v1/storage"
+	"github.com/open-policy-agent/opa/v1/storage/inmem"
 	"go.acuvity.ai/minibridge/pkgs/mcp"
 	"go.acuvity.ai/minibridge/pkgs/policer/api"
 )
 
 type Policer struct {
-	queryAllow   rego.PreparedEvalQuery
-	queryReasons rego.PreparedEvalQuery
-	queryMCP     rego.PreparedEvalQuery
+	queries    atomic.Pointer[queries]
+	generation atomic.Uint64
+	path       string
+}
+
+// queries holds the prepared queries of a set of policies.
+// They are swapped as a whole when the policies are reloaded.
+type queries struct {
+	allow   rego.PreparedEvalQuery
+	reasons rego.PreparedEvalQuery
+	mcp     rego.PreparedEvalQuery
+	noCache *rego.PreparedEvalQuery
+	state   *rego.PreparedEvalQuery
+	pending *rego.PreparedEvalQuery
 }
 
 const RegoRuntimeEnvPrefix = "REGO_POLICY_RUNTIME_"
@@ -30,50 +45,111 @@ func New(policy string) (*Policer, error) {
 		return nil, fmt.Errorf("unable to compile rego policy: %w", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	q, err := prepare(comp, nil)
+	if err != nil {
+		return nil, err
+	}
 
-	rTerm := makeRegoRuntimeTerm()
+	p := &Policer{}
+	p.queries.Store(q)
 
-	queryAllow, err := rego.New(rego.Compiler(comp), rego.Query("data.main.allow"), rego.Runtime(rTerm)).PrepareForEval(ctx)
+	return p, nil
+}
+
+// NewFromPath returns a new Rego based Policer loading its policies
+// from the given path. The path can be a single rego file, a directory
+// or an OPA bundle tarball containing several modules and data files.
+func NewFromPath(path string) (*Policer, error) {
+
+	q, err := load(path)
 	if err != nil {
-		return nil, fmt.Errorf("unable to prepare rego deny query: %w", err)
+		return nil, err
 	}
 
-	queryReasons, err := rego.New(rego.Compiler(comp), rego.Query("reasons := data.main.reasons"), rego.Runtime(rTerm)).PrepareForEval(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("unable to prepare rego deny query: %w", err)
+	p := &Policer{path: path}
+	p.queries.Store(q)
+
+	return p, nil
+}
+
+func (p *Policer) Type() string { return "rego" }
+
+// Reload loads the policies again from the path given to NewFromPath.
+// If they cannot be loaded, the current policies are kept.
+func (p *Policer) Reload() error {
+
+	if p.path == "" {
+		return fmt.Errorf("policer was not loaded from a path")
 	}
 
-	queryMCP, err := rego.New(rego.Compiler(comp), rego.Query("mcp := data.main.mcp"), rego.Runtime(rTerm)).PrepareForEval(ctx)
+	q, err := load(p.path)
 	if err != nil {
-		return nil, fmt.Errorf("unable to prepare rego mcp query: %w", err)
+		return err
 	}
 
-	return &Policer{
-		queryAllow:   queryAllow,
-		queryReasons: queryReasons,
-		queryMCP:     queryMCP,
-	}, nil
+	p.queries.Store(q)
+	p.generation.Add(1)
+
+	return nil
 }
 
-func (p *Policer) Type() string { return "rego" }
+// Generation returns the number of times the policies have been
+// reloaded, so the decisions made before a reload can be discarded.
+func (p *Policer) Generation() uint64 { return p.generation.Load() }
 
 func (p *Policer) Police(ctx context.Context, preq api.Request) (*mcp.Message, error) {
 
-	res, err := p.queryAllow.Eval(ctx, rego.EvalInput(preq), rego.EvalPrintHook(printer{}))
+	q := p.queries.Load()
+
+	res, err := q.allow.Eval(ctx, rego.EvalInput(preq), rego.EvalPrintHook(printer{}))
 	if err != nil {
 		return nil, fmt.Errorf("unable to eval allow query: %w", err)
 	}
 
+	if q.noCache != nil {
+		ncres, err := q.noCache.Eval(ctx, rego.EvalInput(preq), rego.EvalPrintHook(printer{}))
+		if err != nil {
+			return nil, fmt.Errorf("unable to eval no_cache query: %w", err)
+		}
+		if ncres.Allowed() {
+			api.DisableCache(ctx)
+		}
+	}
+
+	if q.state != nil {
+		sres, err := q.state.Eval(ctx, rego.EvalInput(preq), rego.EvalPrintHook(printer{}))
+		if err != nil {
+			return nil, fmt.Errorf("unable to eval state query: %w", err)
+		}
+		if len(sres) > 0 {
+			state, ok := sres[0].Bindings["state"].(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("invalid binding: state must be an map[string]any, got %T", sres[0].Bindings["state"])
+			}
+			api.UpdateState(ctx, state)
+		}
+	}
+
 	if !res.Allowed() {
 
-		res, err = p.queryReasons.Eval(ctx, rego.EvalInput(preq), rego.EvalPrintHook(printer{}))
+		res, err = q.reasons.Eval(ctx, rego.EvalInput(preq), rego.EvalPrintHook(printer{}))
 		if err != nil {
 			return nil, fmt.Errorf("unable to eval reasons query: %w", err)
 		}
 
+		pending := false
+		if q.pending != nil {
+			pres, err := q.pending.Eval(ctx, rego.EvalInput(preq), rego.EvalPrintHook(printer{}))
+			if err != nil {
+				return nil, fmt.Errorf("unable to eval pending query: %w", err)
+			}
+			pending = pres.Allowed()
+		}
+
 		reasons := []string{api.GenericDenyReason}
+		if pending {
+			reasons = []string{api.GenericPendingReason}
+		}
 
 		if len(res) > 0 {
 			bindings := res[0].Bindings
@@ -87,10 +163,26 @@ func (p *Policer) Police(ctx context.Context, preq api.Request) (*mcp.Message, e
 			}
 		}
 
+		// The call to approve is the one set by the mcp rule, if any.
+		if pending {
+			mcall, err := rewrite(ctx, q, preq)
+			if err != nil {
+				return nil, err
+			}
+			return mcall, fmt.Errorf("%w: %s", api.ErrPending, strings.Join(reasons, ", "))
+		}
+
 		return nil, fmt.Errorf("%w: %s", api.ErrBlocked, strings.Join(reasons, ", "))
 	}
 
-	res, err = p.queryMCP.Eval(ctx, rego.EvalInput(preq), rego.EvalPrintHook(printer{}))
+	return rewrite(ctx, q, preq)
+}
+
+// rewrite returns the MCP call set by the mcp rule,
+// or nil if the rule is not defined for the request.
+func rewrite(ctx context.Context, q *queries, preq api.Request) (*mcp.Message, error) {
+
+	res, err := q.mcp.Eval(ctx, rego.EvalInput(preq), rego.EvalPrintHook(printer{}))
 	if err != nil {
 		return nil, fmt.Errorf("unable to eval mcp query: %w", err)
 	}
@@ -116,6 +208,123 @@ func (p *Policer) Police(ctx context.Context, preq api.Request) (*mcp.Message, e
 	return mcall, nil
 }
 
+// load compiles the policies found at the given path.
+func load(path string) (*queries, error) {
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to stat rego policy path: %w", err)
+	}
+
+	// Only directories and tarballs are bundles. Any other file
+	// is a single policy, whatever its extension, like a policy
+	// mounted from a ConfigMap key.
+	if !info.IsDir() && !isTarball(path) {
+
+		data, err := os.ReadFile(path) // #nosec: G304
+		if err != nil {
+			return nil, fmt.Errorf("unable to read rego policy file: %w", err)
+		}
+
+		comp, err := precompile(string(data), "default")
+		if err != nil {
+			return nil, fmt.Errorf("unable to compile rego policy: %w", err)
+		}
+
+		return prepare(comp, nil)
+	}
+
+	b, err := loader.NewFileLoader().AsBundle(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load rego bundle: %w", err)
+	}
+
+	comp, err := compileBundle(b)
+	if err != nil {
+		return nil, fmt.Errorf("unable to compile rego bundle: %w", err)
+	}
+
+	return prepare(comp, inmem.NewFromObject(b.Data))
+}
+
+// isTarball returns true if the given path
+// is a gzipped tarball, like an OPA bundle.
+func isTarball(path string) bool {
+	return strings.HasSuffix(path, ".tar.gz") || strings.HasSuffix(path, ".tgz")
+}
+
+// prepare prepares the queries of the policer using the given
+// compiler. If store is not nil, it is used as the policies data.
+func prepare(comp *ast.Compiler, store storage.Store) (*queries, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rTerm := makeRegoRuntimeTerm()
+
+	opts := func(query string) []func(*rego.Rego) {
+		o := []func(*rego.Rego){rego.Compiler(comp), rego.Query(query), rego.Runtime(rTerm)}
+		if store != nil {
+			o = append(o, rego.Store(store))
+		}
+		return o
+	}
+
+	queryAllow, err := rego.New(opts("data.main.allow")...).PrepareForEval(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to prepare rego deny query: %w", err)
+	}
+
+	queryReasons, err := rego.New(opts("reasons := data.main.reasons")...).PrepareForEval(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to prepare rego deny query: %w", err)
+	}
+
+	queryMCP, err := rego.New(opts("mcp := data.main.mcp")...).PrepareForEval(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to prepare rego mcp query: %w", err)
+	}
+
+	q := &queries{
+		allow:   queryAllow,
+		reasons: queryReasons,
+		mcp:     queryMCP,
+	}
+
+	// The no_cache rule is optional, so it is
+	// only evaluated when the policies define it.
+	if len(comp.GetRulesExact(ast.MustParseRef("data.main.no_cache"))) > 0 {
+		queryNoCache, err := rego.New(opts("data.main.no_cache")...).PrepareForEval(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to prepare rego no_cache query: %w", err)
+		}
+		q.noCache = &queryNoCache
+	}
+
+	// The state rule is optional as well. It returns
+	// the values to set in the state of the session.
+	if len(comp.GetRulesExact(ast.MustParseRef("data.main.state"))) > 0 {
+		queryState, err := rego.New(opts("state := data.main.state")...).PrepareForEval(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to prepare rego state query: %w", err)
+		}
+		q.state = &queryState
+	}
+
+	// The pending rule is optional too. When the request
+	// is not allowed, it tells if it must be approved by
+	// a human instead of being denied.
+	if len(comp.GetRulesExact(ast.MustParseRef("data.main.pending"))) > 0 {
+		queryPending, err := rego.New(opts("data.main.pending")...).PrepareForEval(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to prepare rego pending query: %w", err)
+		}
+		q.pending = &queryPending
+	}
+
+	return q, nil
+}
+
 // makeRegoRuntimeTerm create a rego ast Term
 // to expose prefixed env var to the rego runtime.
 func makeRegoRuntimeTerm() *ast.Term {
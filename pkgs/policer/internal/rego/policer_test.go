@@ -0,0 +1,269 @@
+package rego
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.acuvity.ai/minibridge/pkgs/mcp"
+	"go.acuvity.ai/minibridge/pkgs/policer/api"
+)
+
+const (
+	testMainPolicy = `package main
+import data.tools
+
+default allow := false
+allow if input.mcp.params.name in tools.allowed
+reasons := ["tool not allowed"] if not allow
+`
+	testDenyPolicy = `package main
+default allow := false
+reasons := ["everything denied"]
+`
+)
+
+func callTool(p *Policer, name string) error {
+	_, err := p.Police(context.Background(), api.Request{
+		Type: api.CallTypeRequest,
+		MCP:  mcp.Message{Method: "tools/call", Params: map[string]any{"name": name}},
+	})
+	return err
+}
+
+func TestNewFromPath(t *testing.T) {
+
+	Convey("Given I have a directory with modules and data", t, func() {
+
+		dir := t.TempDir()
+		So(os.MkdirAll(filepath.Join(dir, "tools"), 0o750), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(dir, "main.rego"), []byte(testMainPolicy), 0o600), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(dir, "tools", "data.json"), []byte(`{"allowed":["echo"]}`), 0o600), ShouldBeNil)
+
+		p, err := NewFromPath(dir)
+		So(err, ShouldBeNil)
+
+		Convey("Then the policies should use the data", func() {
+			So(callTool(p, "echo"), ShouldBeNil)
+			So(callTool(p, "rm"), ShouldNotBeNil)
+			So(callTool(p, "rm").Error(), ShouldEqual, "request blocked: tool not allowed")
+		})
+
+		Convey("When I reload after changing the data", func() {
+
+			So(os.WriteFile(filepath.Join(dir, "tools", "data.json"), []byte(`{"allowed":["rm"]}`), 0o600), ShouldBeNil)
+			So(p.Reload(), ShouldBeNil)
+
+			Convey("Then the new data should be used", func() {
+				So(callTool(p, "echo"), ShouldNotBeNil)
+				So(callTool(p, "rm"), ShouldBeNil)
+			})
+		})
+
+		Convey("When I reload after breaking a module", func() {
+
+			So(os.WriteFile(filepath.Join(dir, "main.rego"), []byte("package main\nallow if {"), 0o600), ShouldBeNil)
+			So(p.Reload(), ShouldNotBeNil)
+
+			Convey("Then the previous policies should be kept", func() {
+				So(callTool(p, "echo"), ShouldBeNil)
+				So(callTool(p, "rm"), ShouldNotBeNil)
+			})
+		})
+
+		Convey("When I watch the directory and change a module", func() {
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			So(p.Watch(ctx), ShouldBeNil)
+			So(os.WriteFile(filepath.Join(dir, "main.rego"), []byte(testDenyPolicy), 0o600), ShouldBeNil)
+
+			Convey("Then the policies should be reloaded", func() {
+				deadline := time.Now().Add(5 * time.Second)
+				for callTool(p, "echo") == nil && time.Now().Before(deadline) {
+					time.Sleep(50 * time.Millisecond)
+				}
+				So(callTool(p, "echo"), ShouldNotBeNil)
+				So(callTool(p, "echo").Error(), ShouldEqual, "request blocked: everything denied")
+			})
+		})
+	})
+
+	Convey("Given I have a single rego file", t, func() {
+
+		path := filepath.Join(t.TempDir(), "policy.rego")
+		So(os.WriteFile(path, []byte(testDenyPolicy), 0o600), ShouldBeNil)
+
+		p, err := NewFromPath(path)
+		So(err, ShouldBeNil)
+
+		Convey("Then the policy should be used", func() {
+			So(callTool(p, "echo").Error(), ShouldEqual, "request blocked: everything denied")
+		})
+	})
+
+	Convey("Given I have a single rego file without extension", t, func() {
+
+		path := filepath.Join(t.TempDir(), "policy")
+		So(os.WriteFile(path, []byte(testDenyPolicy), 0o600), ShouldBeNil)
+
+		p, err := NewFromPath(path)
+		So(err, ShouldBeNil)
+		So(callTool(p, "echo").Error(), ShouldEqual, "request blocked: everything denied")
+	})
+
+	Convey("Given I have a bundle tarball", t, func() {
+
+		buf := &bytes.Buffer{}
+		gw := gzip.NewWriter(buf)
+		tw := tar.NewWriter(gw)
+		for name, content := range map[string]string{
+			"/main.rego":       testMainPolicy,
+			"/tools/data.json": `{"allowed":["echo"]}`,
+		} {
+			So(tw.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: int64(len(content))}), ShouldBeNil)
+			_, err := tw.Write([]byte(content))
+			So(err, ShouldBeNil)
+		}
+		So(tw.Close(), ShouldBeNil)
+		So(gw.Close(), ShouldBeNil)
+
+		path := filepath.Join(t.TempDir(), "bundle.tar.gz")
+		So(os.WriteFile(path, buf.Bytes(), 0o600), ShouldBeNil)
+
+		p, err := NewFromPath(path)
+		So(err, ShouldBeNil)
+		So(callTool(p, "echo"), ShouldBeNil)
+		So(callTool(p, "rm").Error(), ShouldEqual, "request blocked: tool not allowed")
+	})
+
+	Convey("Given I have a single rego file mounted from a ConfigMap", t, func() {
+
+		dir := t.TempDir()
+		So(os.MkdirAll(filepath.Join(dir, "..v1"), 0o750), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(dir, "..v1", "policy.rego"), []byte(testDenyPolicy), 0o600), ShouldBeNil)
+		So(os.Symlink("..v1", filepath.Join(dir, "..data")), ShouldBeNil)
+		So(os.Symlink(filepath.Join("..data", "policy.rego"), filepath.Join(dir, "policy.rego")), ShouldBeNil)
+
+		p, err := NewFromPath(filepath.Join(dir, "policy.rego"))
+		So(err, ShouldBeNil)
+		So(callTool(p, "echo"), ShouldNotBeNil)
+
+		Convey("When I watch it and the ConfigMap is updated", func() {
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			So(p.Watch(ctx), ShouldBeNil)
+
+			So(os.MkdirAll(filepath.Join(dir, "..v2"), 0o750), ShouldBeNil)
+			So(os.WriteFile(filepath.Join(dir, "..v2", "policy.rego"), []byte("package main\ndefault allow := true\n"), 0o600), ShouldBeNil)
+			So(os.Symlink("..v2", filepath.Join(dir, "..data_tmp")), ShouldBeNil)
+			So(os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")), ShouldBeNil)
+
+			Convey("Then the policy should be reloaded", func() {
+				deadline := time.Now().Add(5 * time.Second)
+				for callTool(p, "echo") != nil && time.Now().Before(deadline) {
+					time.Sleep(50 * time.Millisecond)
+				}
+				So(callTool(p, "echo"), ShouldBeNil)
+			})
+		})
+	})
+
+	Convey("Given I have a policy with a no_cache rule", t, func() {
+
+		p, err := New(testDenyPolicy + "no_cache if input.mcp.params.name == \"clock\"\n")
+		So(err, ShouldBeNil)
+
+		police := func(name string) bool {
+			ctx, cacheable := api.WithCacheControl(context.Background())
+			_, _ = p.Police(ctx, api.Request{
+				Type: api.CallTypeRequest,
+				MCP:  mcp.Message{Method: "tools/call", Params: map[string]any{"name": name}},
+			})
+			return cacheable()
+		}
+
+		So(police("echo"), ShouldBeTrue)
+		So(police("clock"), ShouldBeFalse)
+	})
+
+	Convey("Given I have a policy with a state rule", t, func() {
+
+		p, err := New(`package main
+default allow := true
+state := {"calls": object.get(input.session.state, "calls", 0) + 1}
+`)
+		So(err, ShouldBeNil)
+
+		ctx, updates := api.WithStateUpdates(context.Background())
+		ctx, cacheable := api.WithCacheControl(ctx)
+
+		_, err = p.Police(ctx, api.Request{
+			Type:    api.CallTypeRequest,
+			MCP:     mcp.Message{Method: "tools/call", Params: map[string]any{"name": "echo"}},
+			Session: api.Session{ID: "s", State: map[string]any{"calls": 2}},
+		})
+		So(err, ShouldBeNil)
+		So(updates(), ShouldResemble, map[string]any{"calls": json.Number("3")})
+		So(cacheable(), ShouldBeFalse)
+	})
+
+	Convey("Given I have a policy with a partial state rule", t, func() {
+
+		p, err := New(testDenyPolicy + "state[input.mcp.params.name] := true\n")
+		So(err, ShouldBeNil)
+
+		ctx, updates := api.WithStateUpdates(context.Background())
+
+		_, err = p.Police(ctx, api.Request{
+			Type: api.CallTypeRequest,
+			MCP:  mcp.Message{Method: "tools/call", Params: map[string]any{"name": "echo"}},
+		})
+		So(err, ShouldNotBeNil)
+		So(updates(), ShouldResemble, map[string]any{"echo": true})
+	})
+
+	Convey("Given I have a policy with a pending rule", t, func() {
+
+		p, err := New(`package main
+default allow := false
+allow if input.mcp.params.name == "echo"
+pending if startswith(input.mcp.params.name, "delete_")
+reasons := ["deletion must be approved"] if pending
+`)
+		So(err, ShouldBeNil)
+
+		So(callTool(p, "echo"), ShouldBeNil)
+
+		err = callTool(p, "delete_file")
+		So(errors.Is(err, api.ErrPending), ShouldBeTrue)
+		So(err.Error(), ShouldEqual, "request pending approval: deletion must be approved")
+
+		err = callTool(p, "deploy")
+		So(errors.Is(err, api.ErrBlocked), ShouldBeTrue)
+		So(err.Error(), ShouldEqual, "request blocked: "+api.GenericDenyReason)
+	})
+
+	Convey("Given I have a path that does not exist", t, func() {
+		_, err := NewFromPath(filepath.Join(t.TempDir(), "nope"))
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Given I have a policer not loaded from a path", t, func() {
+		p, err := New(testDenyPolicy)
+		So(err, ShouldBeNil)
+		So(p.Reload(), ShouldNotBeNil)
+		So(p.Watch(context.Background()), ShouldNotBeNil)
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"log/slog"
 
 	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/open-policy-agent/opa/v1/bundle"
 	"github.com/open-policy-agent/opa/v1/topdown/print"
 )
 
@@ -41,6 +42,24 @@ func precompile(policy string, name string, modules ...*ast.Module) (*ast.Compil
 	return compiler, nil
 }
 
+func compileBundle(b *bundle.Bundle) (*ast.Compiler, error) {
+
+	compiler := ast.NewCompiler().WithEnablePrintStatements(true)
+
+	modules := make(map[string]*ast.Module, len(b.Modules))
+	for _, m := range b.Modules {
+		modules[m.Path] = m.Parsed
+	}
+
+	compiler.Compile(modules)
+
+	if compiler.Failed() {
+		return nil, fmt.Errorf("unable compile rego modules: %w", compiler.Errors)
+	}
+
+	return compiler, nil
+}
+
 func prepareModule(name string, policy string) (*ast.Module, error) {
 
 	caps := ast.CapabilitiesForThisVersion()
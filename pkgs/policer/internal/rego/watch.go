@@ -0,0 +1,133 @@
+package rego
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is the time to wait after the last change
+// before reloading, as editors often write files in several steps.
+const watchDebounce = 250 * time.Millisecond
+
+// Watch watches the path given to NewFromPath and reloads the policies
+// when it changes, until the given context is done. If the new policies
+// cannot be loaded, the error is logged and the current ones are kept.
+func (p *Policer) Watch(ctx context.Context) error {
+
+	if p.path == "" {
+		return fmt.Errorf("policer was not loaded from a path")
+	}
+
+	path, err := filepath.Abs(p.path)
+	if err != nil {
+		return fmt.Errorf("unable to resolve rego policy path: %w", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("unable to stat rego policy path: %w", err)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("unable to create rego policy watcher: %w", err)
+	}
+
+	// A single file is watched through its directory, so
+	// it is still watched after being replaced by a rename.
+	// Its resolved target is tracked too, as a file mounted
+	// from a Kubernetes ConfigMap is a symlink that is updated
+	// by swapping a symlinked directory next to it.
+	target, _ := filepath.EvalSymlinks(path)
+
+	if info.IsDir() {
+		err = watchDir(watcher, path)
+	} else {
+		err = watcher.Add(filepath.Dir(path))
+	}
+	if err != nil {
+		_ = watcher.Close()
+		return fmt.Errorf("unable to watch rego policy path: %w", err)
+	}
+
+	go func() {
+
+		defer func() { _ = watcher.Close() }()
+
+		timer := time.NewTimer(watchDebounce)
+		timer.Stop()
+
+		for {
+			select {
+
+			case ev, ok := <-watcher.Events:
+
+				if !ok {
+					return
+				}
+
+				if !info.IsDir() {
+					t, _ := filepath.EvalSymlinks(path)
+					if ev.Name != path && t == target {
+						continue
+					}
+					target = t
+				}
+
+				if info.IsDir() && ev.Has(fsnotify.Create) {
+					if i, err := os.Stat(ev.Name); err == nil && i.IsDir() {
+						if err := watchDir(watcher, ev.Name); err != nil {
+							slog.Error("Unable to watch rego policy directory", "dir", ev.Name, "err", err)
+						}
+					}
+				}
+
+				timer.Reset(watchDebounce)
+
+			case err, ok := <-watcher.Errors:
+
+				if !ok {
+					return
+				}
+
+				slog.Error("Rego policy watcher error", "err", err)
+
+			case <-timer.C:
+
+				if err := p.Reload(); err != nil {
+					slog.Error("Unable to reload rego policies. Keeping the current ones", "path", p.path, "err", err)
+					continue
+				}
+
+				slog.Info("Rego policies reloaded", "path", p.path)
+
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// watchDir adds the given directory and
+// all its sub directories to the watcher.
+func watchDir(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		return watcher.Add(path)
+	})
+}
@@ -0,0 +1,154 @@
+package rules
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// path is a parsed JSON path. Its elements are
+// either a string for an object key or an int for
+// an array index.
+type path []any
+
+// parsePath parses a simple JSON path like $.a.b[0].c.
+// The leading $ is optional.
+func parsePath(s string) (path, error) {
+
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "$"), ".")
+	if s == "" {
+		return nil, fmt.Errorf("empty path")
+	}
+
+	var p path
+	for part := range strings.SplitSeq(s, ".") {
+
+		if part == "" {
+			return nil, fmt.Errorf("empty element in '%s'", s)
+		}
+
+		key, rest, bracket := strings.Cut(part, "[")
+		if key != "" {
+			p = append(p, key)
+		}
+
+		if bracket && rest == "" {
+			return nil, fmt.Errorf("unclosed bracket in '%s'", s)
+		}
+
+		for rest != "" {
+
+			idx, after, ok := strings.Cut(rest, "]")
+			if !ok {
+				return nil, fmt.Errorf("unclosed bracket in '%s'", s)
+			}
+
+			i, err := strconv.Atoi(idx)
+			if err != nil || i < 0 {
+				return nil, fmt.Errorf("invalid index '%s' in '%s'", idx, s)
+			}
+
+			p = append(p, i)
+
+			if after != "" && !strings.HasPrefix(after, "[") {
+				return nil, fmt.Errorf("invalid path '%s'", s)
+			}
+			rest = strings.TrimPrefix(after, "[")
+		}
+	}
+
+	return p, nil
+}
+
+// get returns the value at the path in v.
+func (p path) get(v any) (any, bool) {
+
+	for _, e := range p {
+		switch e := e.(type) {
+
+		case string:
+			m, ok := v.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			if v, ok = m[e]; !ok {
+				return nil, false
+			}
+
+		case int:
+			a, ok := v.([]any)
+			if !ok || e >= len(a) {
+				return nil, false
+			}
+			v = a[e]
+		}
+	}
+
+	return v, true
+}
+
+// set sets the value at the path in m. The missing
+// objects are created, but arrays must already exist.
+func (p path) set(m map[string]any, value any) error {
+
+	var v any = m
+
+	for i, e := range p {
+
+		last := i == len(p)-1
+
+		switch e := e.(type) {
+
+		case string:
+			obj, ok := v.(map[string]any)
+			if !ok {
+				return fmt.Errorf("'%s' is not in an object", e)
+			}
+			if last {
+				obj[e] = value
+				return nil
+			}
+			if _, ok := obj[e]; !ok {
+				obj[e] = map[string]any{}
+			}
+			v = obj[e]
+
+		case int:
+			a, ok := v.([]any)
+			if !ok || e >= len(a) {
+				return fmt.Errorf("index %d is out of range", e)
+			}
+			if last {
+				a[e] = value
+				return nil
+			}
+			v = a[e]
+		}
+	}
+
+	return nil
+}
+
+// deepCopy returns a copy of the given decoded JSON value.
+func deepCopy(v any) any {
+
+	switch v := v.(type) {
+
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			out[k] = deepCopy(e)
+		}
+		return out
+
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = deepCopy(e)
+		}
+		return out
+
+	default:
+		return v
+	}
+}
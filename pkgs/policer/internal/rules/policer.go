@@ -0,0 +1,381 @@
+package rules
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"go.acuvity.ai/minibridge/pkgs/mcp"
+	"go.acuvity.ai/minibridge/pkgs/policer/api"
+	"gopkg.in/yaml.v3"
+)
+
+// Action is the action of a rule.
+type Action string
+
+// Various values of Action.
+const (
+	ActionAllow   Action = "allow"
+	ActionDeny    Action = "deny"
+	ActionPending Action = "pending"
+	ActionRewrite Action = "rewrite"
+)
+
+// Config is the content of a rules file.
+type Config struct {
+
+	// Default is the action applied when no allow or
+	// deny rule matches. It is allow if not set. It only
+	// applies to requests and responses, and never to the
+	// initialize handshake, the pings and the notifications,
+	// which all the sessions need.
+	Default Action `yaml:"default"`
+
+	// Rules are the rules, evaluated in order.
+	Rules []Rule `yaml:"rules"`
+}
+
+// A Rule applies an action to the calls it matches.
+type Rule struct {
+
+	// Name identifies the rule in the errors.
+	Name string `yaml:"name"`
+
+	// Match defines the calls the rule applies to.
+	// An empty Match matches all the calls.
+	Match Match `yaml:"match"`
+
+	// Action is the action to apply. Allow, deny and pending stop
+	// the evaluation, while rewrite modifies the tool call arguments
+	// and continues with the next rules. Pending holds the call
+	// until a human approves or denies it.
+	Action Action `yaml:"action"`
+
+	// Reason is the reason given when the call is
+	// denied, or when it must be approved.
+	Reason string `yaml:"reason"`
+
+	// Rewrite contains the arguments to set on the tool
+	// call, keyed by their JSON path. It only applies to
+	// tools/call requests.
+	Rewrite map[string]any `yaml:"rewrite"`
+}
+
+// Match defines the calls matched by a rule. All the
+// fields that are set must match. Lists match if
+// any of their values matches.
+type Match struct {
+
+	// Types are the call types, like request or response.
+	Types []api.CallType `yaml:"types"`
+
+	// Methods are the MCP methods. For a response,
+	// this is the method of the request it responds to.
+	Methods []string `yaml:"methods"`
+
+	// Tools are the tool names of tools/call. For a response,
+	// this is the tool called by the request it responds to.
+	Tools []string `yaml:"tools"`
+
+	// Users are the agent users.
+	Users []string `yaml:"users"`
+
+	// Arguments are regular expressions the tool call
+	// arguments at the given JSON paths must match.
+	// Values that are not strings are matched using their
+	// JSON encoding.
+	Arguments map[string]string `yaml:"arguments"`
+}
+
+// rule is a Rule ready to be evaluated.
+type rule struct {
+	Rule
+	arguments map[string]argumentMatcher
+	rewrite   map[string]rewriter
+}
+
+type argumentMatcher struct {
+	path path
+	re   *regexp.Regexp
+}
+
+type rewriter struct {
+	path  path
+	value any
+}
+
+type Policer struct {
+	def   Action
+	rules []rule
+}
+
+// New returns a new Policer evaluating the rules of the given
+// YAML or JSON document.
+func New(data string) (*Policer, error) {
+
+	cfg := Config{}
+	if err := yaml.Unmarshal([]byte(data), &cfg); err != nil {
+		return nil, fmt.Errorf("unable to decode rules: %w", err)
+	}
+
+	p := &Policer{
+		def:   cfg.Default,
+		rules: make([]rule, len(cfg.Rules)),
+	}
+
+	switch p.def {
+	case "":
+		p.def = ActionAllow
+	case ActionAllow, ActionDeny:
+	default:
+		return nil, fmt.Errorf("invalid default action '%s': must be allow or deny", p.def)
+	}
+
+	for i, r := range cfg.Rules {
+
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule %d", i)
+		}
+
+		compiled, err := compile(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule '%s': %w", r.Name, err)
+		}
+
+		p.rules[i] = compiled
+	}
+
+	return p, nil
+}
+
+func (p *Policer) Type() string { return "rules" }
+
+func (p *Policer) Police(_ context.Context, preq api.Request) (*mcp.Message, error) {
+
+	var modified *mcp.Message
+
+	for _, r := range p.rules {
+
+		msg := preq.MCP
+		if modified != nil {
+			msg = *modified
+		}
+
+		if !r.matches(preq, msg) {
+			continue
+		}
+
+		switch r.Action {
+
+		case ActionAllow:
+			return modified, nil
+
+		case ActionDeny:
+			return nil, deny(r.Reason)
+
+		case ActionPending:
+			return modified, pending(r.Reason)
+
+		case ActionRewrite:
+			if rewritten, ok := r.apply(preq, msg); ok {
+				modified = rewritten
+			}
+		}
+	}
+
+	if p.def == ActionDeny && defaulted(preq) {
+		return nil, deny("")
+	}
+
+	return modified, nil
+}
+
+// defaulted returns true if the default
+// action applies to the given call.
+func defaulted(preq api.Request) bool {
+
+	method := preq.MCP.Method
+
+	switch preq.Type {
+	case api.CallTypeRequest:
+	case api.CallTypeResponse:
+		method = preq.OriginMCP.Method
+	default:
+		return false
+	}
+
+	return method != "initialize" && method != "ping" && !strings.HasPrefix(method, "notifications/")
+}
+
+func deny(reason string) error {
+
+	if reason == "" {
+		reason = api.GenericDenyReason
+	}
+
+	return fmt.Errorf("%w: %s", api.ErrBlocked, reason)
+}
+
+func pending(reason string) error {
+
+	if reason == "" {
+		reason = api.GenericPendingReason
+	}
+
+	return fmt.Errorf("%w: %s", api.ErrPending, reason)
+}
+
+func compile(r Rule) (rule, error) {
+
+	c := rule{Rule: r}
+
+	switch r.Action {
+	case ActionAllow, ActionDeny, ActionPending:
+		if len(r.Rewrite) > 0 {
+			return c, fmt.Errorf("rewrite can only be set with the rewrite action")
+		}
+	case ActionRewrite:
+		if len(r.Rewrite) == 0 {
+			return c, fmt.Errorf("rewrite must be set with the rewrite action")
+		}
+	default:
+		return c, fmt.Errorf("invalid action '%s': must be allow, deny, pending or rewrite", r.Action)
+	}
+
+	c.arguments = make(map[string]argumentMatcher, len(r.Match.Arguments))
+	for k, v := range r.Match.Arguments {
+
+		p, err := parsePath(k)
+		if err != nil {
+			return c, fmt.Errorf("invalid argument path: %w", err)
+		}
+
+		re, err := regexp.Compile(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid argument regexp for '%s': %w", k, err)
+		}
+
+		c.arguments[k] = argumentMatcher{path: p, re: re}
+	}
+
+	c.rewrite = make(map[string]rewriter, len(r.Rewrite))
+	for k, v := range r.Rewrite {
+
+		p, err := parsePath(k)
+		if err != nil {
+			return c, fmt.Errorf("invalid rewrite path: %w", err)
+		}
+
+		c.rewrite[k] = rewriter{path: p, value: v}
+	}
+
+	return c, nil
+}
+
+// toolCall returns the tools/call request the given
+// message is or responds to, if any.
+func toolCall(preq api.Request, msg mcp.Message) (mcp.Message, bool) {
+
+	if preq.Type == api.CallTypeResponse {
+		msg = preq.OriginMCP
+	}
+
+	return msg, msg.Method == "tools/call"
+}
+
+func (r rule) matches(preq api.Request, msg mcp.Message) bool {
+
+	m := r.Match
+
+	if len(m.Types) > 0 && !slices.Contains(m.Types, preq.Type) {
+		return false
+	}
+
+	if len(m.Users) > 0 && !slices.Contains(m.Users, preq.Agent.User) {
+		return false
+	}
+
+	if len(m.Methods) > 0 {
+		method := msg.Method
+		if preq.Type == api.CallTypeResponse {
+			method = preq.OriginMCP.Method
+		}
+		if !slices.Contains(m.Methods, method) {
+			return false
+		}
+	}
+
+	if len(m.Tools) == 0 && len(r.arguments) == 0 {
+		return true
+	}
+
+	call, ok := toolCall(preq, msg)
+	if !ok {
+		return false
+	}
+
+	if len(m.Tools) > 0 {
+		name, _ := call.Params["name"].(string)
+		if !slices.Contains(m.Tools, name) {
+			return false
+		}
+	}
+
+	args, _ := call.Params["arguments"].(map[string]any)
+	for _, a := range r.arguments {
+
+		v, ok := a.path.get(args)
+		if !ok {
+			return false
+		}
+
+		s, ok := v.(string)
+		if !ok {
+			data, err := json.Marshal(v)
+			if err != nil {
+				return false
+			}
+			s = string(data)
+		}
+
+		if !a.re.MatchString(s) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// apply returns a copy of the given message with the tool
+// call arguments rewritten. It returns false if the message
+// is not a tools/call request, or if a path cannot be set.
+func (r rule) apply(preq api.Request, msg mcp.Message) (*mcp.Message, bool) {
+
+	if preq.Type != api.CallTypeRequest || msg.Method != "tools/call" {
+		return nil, false
+	}
+
+	params, _ := deepCopy(msg.Params).(map[string]any)
+	if params == nil {
+		params = map[string]any{}
+	}
+
+	args, _ := params["arguments"].(map[string]any)
+	if args == nil {
+		args = map[string]any{}
+		params["arguments"] = args
+	}
+
+	for _, rw := range r.rewrite {
+		if err := rw.path.set(args, deepCopy(rw.value)); err != nil {
+			return nil, false
+		}
+	}
+
+	msg.Params = params
+
+	return &msg, true
+}
@@ -0,0 +1,224 @@
+package rules
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"go.acuvity.ai/minibridge/pkgs/mcp"
+	"go.acuvity.ai/minibridge/pkgs/policer/api"
+)
+
+func toolRequest(user string, name string, args map[string]any) api.Request {
+	return api.Request{
+		Type:  api.CallTypeRequest,
+		Agent: api.Agent{User: user},
+		MCP: mcp.Message{
+			ID:     1,
+			Method: "tools/call",
+			Params: map[string]any{"name": name, "arguments": args},
+		},
+	}
+}
+
+func TestNew(t *testing.T) {
+
+	Convey("Given I have invalid rules", t, func() {
+
+		for data, expected := range map[string]string{
+			`default: maybe`:                                                      "invalid default action 'maybe': must be allow or deny",
+			`rules: [{action: nope}]`:                                             "invalid rule 'rule 0': invalid action 'nope': must be allow, deny, pending or rewrite",
+			`rules: [{name: r, action: rewrite}]`:                                 "invalid rule 'r': rewrite must be set with the rewrite action",
+			`rules: [{name: r, action: allow, rewrite: {a: 1}}]`:                  "invalid rule 'r': rewrite can only be set with the rewrite action",
+			`rules: [{name: r, action: deny, match: {arguments: {"a": "("}}}]`:    "invalid rule 'r': invalid argument regexp for 'a': error parsing regexp: missing closing ): `(`",
+			`rules: [{name: r, action: deny, match: {arguments: {"a..b": "x"}}}]`: "invalid rule 'r': invalid argument path: empty element in 'a..b'",
+		} {
+			_, err := New(data)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, expected)
+		}
+	})
+
+	Convey("Given I have the example rules", t, func() {
+		data, err := os.ReadFile("../../../../examples/policer-rules/rules.yaml")
+		So(err, ShouldBeNil)
+		p, err := New(string(data))
+		So(err, ShouldBeNil)
+
+		Convey("Then only the files in /tmp should be readable", func() {
+			for path, allowed := range map[string]bool{
+				"/tmp/a":             true,
+				"/tmp/a/..b/.c":      true,
+				"/tmp/":              false,
+				"/tmp/../etc/passwd": false,
+				"/tmp/a/../../etc":   false,
+				"/tmp/./a":           false,
+				"/etc/passwd":        false,
+				"/tmpfoo/a":          false,
+				"/tmp/a\n/../../etc": false,
+			} {
+				_, err := p.Police(context.Background(), toolRequest("", "read_file", map[string]any{"path": path}))
+				So(err == nil, ShouldEqual, allowed)
+			}
+		})
+	})
+}
+
+func TestPolicer(t *testing.T) {
+
+	Convey("Given I have a rules policer", t, func() {
+
+		p, err := New(`
+default: deny
+rules:
+  - name: fetch-no-redirect
+    match:
+      types: [request]
+      tools: [fetch]
+    action: rewrite
+    rewrite:
+      $.options.follow_redirects: false
+  - match:
+      tools: [run_shell]
+      users: [admin]
+    action: allow
+  - match:
+      tools: [run_shell]
+    action: deny
+    reason: admins only
+  - match:
+      types: [request]
+      tools: [delete_repo]
+    action: rewrite
+    rewrite:
+      $.force: false
+  - match:
+      tools: [delete_repo]
+    action: pending
+    reason: deletions must be approved
+  - match:
+      tools: [read_file]
+      arguments:
+        $.path: ^/tmp/
+        $.lines[0]: "^1$"
+    action: allow
+  - match:
+      types: [response]
+      tools: [secret]
+    action: deny
+    reason: no secret output
+  - match:
+      methods: [tools/call]
+    action: allow
+`)
+		So(err, ShouldBeNil)
+		So(p.Type(), ShouldEqual, "rules")
+
+		Convey("Then the users should be matched", func() {
+			_, err := p.Police(context.Background(), toolRequest("admin", "run_shell", nil))
+			So(err, ShouldBeNil)
+
+			_, err = p.Police(context.Background(), toolRequest("bob", "run_shell", nil))
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "request blocked: admins only")
+		})
+
+		Convey("Then the calls to approve should be pending", func() {
+			msg, err := p.Police(context.Background(), toolRequest("bob", "delete_repo", nil))
+			So(errors.Is(err, api.ErrPending), ShouldBeTrue)
+			So(err.Error(), ShouldEqual, "request pending approval: deletions must be approved")
+			So(msg.Params["arguments"], ShouldResemble, map[string]any{"force": false})
+		})
+
+		Convey("Then the arguments should be matched", func() {
+			_, err := p.Police(context.Background(), toolRequest("bob", "read_file", map[string]any{"path": "/tmp/a", "lines": []any{1.0}}))
+			So(err, ShouldBeNil)
+		})
+
+		Convey("Then the tool call arguments should be rewritten", func() {
+			req := toolRequest("bob", "fetch", map[string]any{"url": "https://example.com"})
+
+			msg, err := p.Police(context.Background(), req)
+			So(err, ShouldBeNil)
+			So(msg.ID, ShouldEqual, 1)
+			So(msg.Params["arguments"], ShouldResemble, map[string]any{
+				"url":     "https://example.com",
+				"options": map[string]any{"follow_redirects": false},
+			})
+			So(req.MCP.Params["arguments"], ShouldResemble, map[string]any{"url": "https://example.com"})
+		})
+
+		Convey("Then the responses should be matched using their request", func() {
+			_, err := p.Police(context.Background(), api.Request{
+				Type:      api.CallTypeResponse,
+				MCP:       mcp.Message{ID: 1, Result: map[string]any{}},
+				OriginMCP: toolRequest("", "secret", nil).MCP,
+			})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "request blocked: no secret output")
+		})
+
+		Convey("Then the default action should apply when no rule matches", func() {
+			_, err := p.Police(context.Background(), api.Request{
+				Type: api.CallTypeRequest,
+				MCP:  mcp.Message{ID: 1, Method: "resources/read"},
+			})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "request blocked: "+api.GenericDenyReason)
+		})
+
+		Convey("Then the default action should not apply to the session calls and the handshake", func() {
+			for _, req := range []api.Request{
+				{Type: api.CallTypeSessionStart},
+				{Type: api.CallTypeSessionEnd},
+				{Type: api.CallTypeRequest, MCP: mcp.Message{ID: 1, Method: "initialize"}},
+				{Type: api.CallTypeResponse, MCP: mcp.Message{ID: 1, Result: map[string]any{}}, OriginMCP: mcp.Message{ID: 1, Method: "initialize"}},
+				{Type: api.CallTypeRequest, MCP: mcp.Message{Method: "notifications/initialized"}},
+				{Type: api.CallTypeRequest, MCP: mcp.Message{ID: 2, Method: "ping"}},
+			} {
+				_, err := p.Police(context.Background(), req)
+				So(err, ShouldBeNil)
+			}
+		})
+	})
+}
+
+func TestPath(t *testing.T) {
+
+	Convey("Given I have a document", t, func() {
+
+		doc := map[string]any{"a": map[string]any{"b": []any{"x", map[string]any{"c": "y"}}}}
+
+		Convey("Then I can get values", func() {
+			for p, expected := range map[string]any{"$.a.b[0]": "x", "a.b[1].c": "y"} {
+				pp, err := parsePath(p)
+				So(err, ShouldBeNil)
+				v, ok := pp.get(doc)
+				So(ok, ShouldBeTrue)
+				So(v, ShouldEqual, expected)
+			}
+
+			pp, _ := parsePath("a.b[2]")
+			_, ok := pp.get(doc)
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("Then I can set values", func() {
+			pp, _ := parsePath("a.b[1].d.e")
+			So(pp.set(doc, 1), ShouldBeNil)
+			So(doc["a"].(map[string]any)["b"].([]any)[1], ShouldResemble, map[string]any{"c": "y", "d": map[string]any{"e": 1}})
+
+			pp, _ = parsePath("a.b[3]")
+			So(pp.set(doc, 1), ShouldNotBeNil)
+		})
+	})
+
+	Convey("Given I have invalid paths", t, func() {
+		for _, p := range []string{"$", "a[", "a[x]", "a[0]b", "a..b"} {
+			_, err := parsePath(p)
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
@@ -0,0 +1,132 @@
+package secrets
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"go.acuvity.ai/minibridge/pkgs/policer/internal/detect"
+)
+
+const (
+	// entropyMinLength is the minimum length of
+	// the strings checked by the high-entropy detector.
+	entropyMinLength = 32
+
+	// entropyThreshold is the Shannon entropy, in bits per
+	// character, above which a string is considered a secret.
+	entropyThreshold = 4.5
+)
+
+var entropyCandidate = regexp.MustCompile(fmt.Sprintf(`[A-Za-z0-9+/_=-]{%d,}`, entropyMinLength))
+
+// detectors are the available detectors, in the order they run.
+// The most specific ones run first, so they are reported under
+// their name rather than as high-entropy strings.
+var detectors = []detect.Detector{
+	detect.Regexp("private-key", `-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+	detect.Regexp("jwt", `\beyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{10,}`),
+	detect.Regexp("github-token", `\b(?:gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{22,255})\b`),
+	detect.Regexp("aws-access-key", `\b(?:AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16}\b`),
+	detect.Regexp("aws-secret-key", `(?i)aws.{0,20}?(?:secret|key).{0,20}?[\s'"=:]+([A-Za-z0-9/+]{40})\b`),
+	detect.Regexp("slack-token", `\bxox[abposr]-[A-Za-z0-9-]{10,}`),
+	{Name: "high-entropy", Find: findHighEntropy},
+}
+
+// Detectors returns the names of the available detectors.
+func Detectors() []string {
+
+	names := make([]string, len(detectors))
+	for i, d := range detectors {
+		names[i] = d.Name
+	}
+
+	return names
+}
+
+// New returns a new Policer detecting the secrets in the arguments of
+// the tool calls and in the tool results. If names is empty, all the
+// detectors are used.
+func New(names []string, opts ...detect.Option) (*detect.Policer, error) {
+
+	selected, err := selectDetectors(names)
+	if err != nil {
+		return nil, err
+	}
+
+	return detect.New("secrets", selected, opts...), nil
+}
+
+func selectDetectors(names []string) ([]detect.Detector, error) {
+
+	if len(names) == 0 {
+		return detectors, nil
+	}
+
+	enabled := make(map[string]bool, len(names))
+	for _, n := range names {
+		enabled[n] = true
+	}
+
+	selected := make([]detect.Detector, 0, len(names))
+	for _, d := range detectors {
+		if enabled[d.Name] {
+			selected = append(selected, d)
+			delete(enabled, d.Name)
+		}
+	}
+
+	for n := range enabled {
+		return nil, fmt.Errorf("unknown secrets detector '%s': must be one of %s", n, strings.Join(Detectors(), ", "))
+	}
+
+	return selected, nil
+}
+
+// findHighEntropy finds the long strings of letters
+// and digits that look randomly generated.
+func findHighEntropy(s string) [][]int {
+
+	var out [][]int
+	for _, m := range entropyCandidate.FindAllStringIndex(s, -1) {
+		if c := s[m[0]:m[1]]; hasLettersAndDigits(c) && entropy(c) >= entropyThreshold {
+			out = append(out, m)
+		}
+	}
+
+	return out
+}
+
+func hasLettersAndDigits(s string) bool {
+
+	var letter, digit bool
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			digit = true
+		case (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z'):
+			letter = true
+		}
+	}
+
+	return letter && digit
+}
+
+// entropy returns the Shannon entropy of s in bits per character.
+func entropy(s string) float64 {
+
+	freq := map[rune]int{}
+	for _, r := range s {
+		freq[r]++
+	}
+
+	var e float64
+	n := float64(len(s))
+	for _, c := range freq {
+		p := float64(c) / n
+		e -= p * math.Log2(p)
+	}
+
+	return e
+}
@@ -0,0 +1,237 @@
+package policer
+
+import (
+	"time"
+
+	"go.acuvity.ai/minibridge/pkgs/metrics"
+	"go.acuvity.ai/minibridge/pkgs/policer/internal/detect"
+	"go.acuvity.ai/minibridge/pkgs/policer/internal/http"
+	"go.acuvity.ai/minibridge/pkgs/policer/internal/injection"
+	"go.acuvity.ai/minibridge/pkgs/policer/internal/pii"
+)
+
+type chainCfg struct {
+	mode           ChainMode
+	metricsManager *metrics.Manager
+}
+
+func newChainCfg() chainCfg {
+	return chainCfg{
+		mode: ChainModeFirstDeny,
+	}
+}
+
+// A ChainOption can be given to NewChain.
+type ChainOption func(*chainCfg)
+
+// OptChainMode sets how the chain combines the
+// decisions of its stages. The default is ChainModeFirstDeny.
+func OptChainMode(mode ChainMode) ChainOption {
+	return func(c *chainCfg) {
+		c.mode = mode
+	}
+}
+
+// OptChainMetricsManager sets the metric manager
+// used to measure the decisions of each stage.
+func OptChainMetricsManager(m *metrics.Manager) ChainOption {
+	return func(c *chainCfg) {
+		c.metricsManager = m
+	}
+}
+
+type regoCfg struct {
+	watch bool
+}
+
+func newRegoCfg() regoCfg {
+	return regoCfg{}
+}
+
+// A RegoOption can be given to NewRegoFromPath.
+type RegoOption func(*regoCfg)
+
+// OptRegoWatch sets if the policies should be reloaded when
+// their path changes. If the new policies cannot be compiled,
+// the current ones are kept.
+func OptRegoWatch(watch bool) RegoOption {
+	return func(c *regoCfg) {
+		c.watch = watch
+	}
+}
+
+// HTTPFailMode defines what the HTTP policer does
+// when it is unable to get a decision.
+type HTTPFailMode = http.FailMode
+
+// Various values of HTTPFailMode.
+const (
+	HTTPFailModeClosed HTTPFailMode = http.FailModeClosed
+	HTTPFailModeOpen   HTTPFailMode = http.FailModeOpen
+)
+
+// An HTTPOption can be given to NewHTTP.
+type HTTPOption = http.Option
+
+// OptHTTPTimeout sets the maximum duration of a single call to the
+// policer. A zero duration disables the timeout. The default is 10s.
+func OptHTTPTimeout(timeout time.Duration) HTTPOption {
+	return http.OptTimeout(timeout)
+}
+
+// OptHTTPRetries sets how many times a call failing because the policer
+// is unreachable or in error is retried, waiting a jittered exponential
+// backoff starting at the given duration between attempts.
+// The default is 2 retries with a backoff of 100ms.
+func OptHTTPRetries(retries int, backoff time.Duration) HTTPOption {
+	return http.OptRetries(retries, backoff)
+}
+
+// OptHTTPCircuitBreaker sets the number of consecutive failed calls after
+// which the policer is not called anymore for the given cooldown.
+// A threshold of 0 disables the circuit breaker. The default is
+// 5 failures and a cooldown of 30s.
+func OptHTTPCircuitBreaker(threshold int, cooldown time.Duration) HTTPOption {
+	return http.OptCircuitBreaker(threshold, cooldown)
+}
+
+// OptHTTPFailMode sets if the requests should be allowed or denied
+// when the policer is unable to give a decision, after all retries
+// or while the circuit breaker is open. The default is HTTPFailModeClosed.
+func OptHTTPFailMode(mode HTTPFailMode) HTTPOption {
+	return http.OptFailMode(mode)
+}
+
+type cacheCfg struct {
+	ttl            time.Duration
+	maxSize        int64
+	metricsManager *metrics.Manager
+}
+
+func newCacheCfg() cacheCfg {
+	return cacheCfg{
+		ttl:     time.Minute,
+		maxSize: 1024,
+	}
+}
+
+// A CacheOption can be given to NewCache.
+type CacheOption func(*cacheCfg)
+
+// OptCacheTTL sets how long a decision is cached.
+// The default is 1m.
+func OptCacheTTL(ttl time.Duration) CacheOption {
+	return func(c *cacheCfg) {
+		c.ttl = ttl
+	}
+}
+
+// OptCacheMaxSize sets the maximum number of
+// decisions in the cache. The default is 1024.
+func OptCacheMaxSize(size int64) CacheOption {
+	return func(c *cacheCfg) {
+		c.maxSize = size
+	}
+}
+
+// OptCacheMetricsManager sets the metric manager
+// used to measure the cache hits and misses.
+func OptCacheMetricsManager(m *metrics.Manager) CacheOption {
+	return func(c *cacheCfg) {
+		c.metricsManager = m
+	}
+}
+
+// DetectionMode defines what the detection
+// policers do with the sensitive data they find.
+type DetectionMode = detect.Mode
+
+// Various values of DetectionMode.
+const (
+	DetectionModeRedact DetectionMode = detect.ModeRedact
+	DetectionModeBlock  DetectionMode = detect.ModeBlock
+)
+
+// A DetectionOption can be given to the detection policers.
+type DetectionOption = detect.Option
+
+// OptDetectionMode sets if the sensitive data is redacted, or if
+// the message is blocked. The default is DetectionModeRedact.
+func OptDetectionMode(mode DetectionMode) DetectionOption {
+	return detect.OptMode(mode)
+}
+
+// OptDetectionMetricsManager sets the metric manager
+// used to count the sensitive data found by each detector.
+func OptDetectionMetricsManager(m *metrics.Manager) DetectionOption {
+	return detect.OptMetricsManager(m)
+}
+
+// PIIMode defines how the PII policer processes an entity.
+type PIIMode = pii.Mode
+
+// Various values of PIIMode.
+const (
+	PIIModeMask     PIIMode = pii.ModeMask
+	PIIModeHash     PIIMode = pii.ModeHash
+	PIIModeTokenize PIIMode = pii.ModeTokenize
+)
+
+// A PIIOption can be given to NewPII.
+type PIIOption = pii.Option
+
+// OptPIIMode sets the mode used for the entities without
+// a mode set by OptPIIEntityModes. The default is PIIModeMask.
+func OptPIIMode(mode PIIMode) PIIOption {
+	return pii.OptMode(mode)
+}
+
+// OptPIIEntityModes sets the mode to use for given entities.
+func OptPIIEntityModes(modes map[string]PIIMode) PIIOption {
+	return pii.OptEntityModes(modes)
+}
+
+// OptPIIHashKey sets the key used to hash and tokenize the entities.
+// Setting the same key on several backends gives the same hashes.
+// By default, a random key is generated.
+func OptPIIHashKey(key []byte) PIIOption {
+	return pii.OptHashKey(key)
+}
+
+// OptPIITokens sets how long, and how many, tokens are kept
+// to be replaced by their entity. The defaults are 24h
+// and 100000 tokens.
+func OptPIITokens(ttl time.Duration, maxSize int64) PIIOption {
+	return pii.OptTokens(ttl, maxSize)
+}
+
+// OptPIIMetricsManager sets the metric manager
+// used to count the entities found.
+func OptPIIMetricsManager(m *metrics.Manager) PIIOption {
+	return pii.OptMetricsManager(m)
+}
+
+// InjectionMode defines what the injection
+// policer does with the injections it finds.
+type InjectionMode = injection.Mode
+
+// Various values of InjectionMode.
+const (
+	InjectionModeBlock InjectionMode = injection.ModeBlock
+	InjectionModeStrip InjectionMode = injection.ModeStrip
+)
+
+// An InjectionOption can be given to NewInjection.
+type InjectionOption = injection.Option
+
+// OptInjectionMode sets if the message is blocked, or if the
+// injections are stripped. The default is InjectionModeBlock.
+func OptInjectionMode(mode InjectionMode) InjectionOption {
+	return injection.OptMode(mode)
+}
+
+// OptInjectionMetricsManager sets the metric manager
+// used to count the injections of each kind.
+func OptInjectionMetricsManager(m *metrics.Manager) InjectionOption {
+	return injection.OptMetricsManager(m)
+}
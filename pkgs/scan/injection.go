@@ -0,0 +1,314 @@
+package scan
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"go.acuvity.ai/minibridge/pkgs/mcp"
+)
+
+// Various kinds of Injection.
+const (
+	InjectionHiddenInstruction = "hidden-instruction"
+	InjectionInvisibleUnicode  = "invisible-unicode"
+	InjectionMarkdownImage     = "markdown-image"
+	InjectionToolReference     = "tool-reference"
+)
+
+// maxExcerptLength is the maximum length of the excerpts.
+const maxExcerptLength = 80
+
+var (
+	hiddenBlock = regexp.MustCompile(`(?i)<(?:important|system|instructions?|hidden)>[\s\S]*?</(?:important|system|instructions?|hidden)>`)
+
+	hiddenInstruction = regexp.MustCompile(`(?i)` +
+		`<(?:important|system|instructions?|hidden)>|` +
+		`\b(?:ignore|disregard|forget|override)\s+(?:all\s+|any\s+)?(?:the\s+|your\s+)?(?:previous|prior|above|earlier|preceding)\s+(?:instructions?|prompts?|messages?|rules|directions)|` +
+		`\b(?:do\s+not|don't|never)\s+(?:tell|inform|notify|mention|reveal|show|alert)\s+(?:this\s+|it\s+|anything\s+)?(?:to\s+)?(?:the\s+)?user|` +
+		`\bwithout\s+(?:telling|informing|notifying|alerting)\s+(?:the\s+)?user`,
+	)
+
+	invisibleUnicode = regexp.MustCompile(`[\x{200B}-\x{200F}\x{202A}-\x{202E}\x{2060}-\x{2064}\x{2066}-\x{2069}\x{FEFF}\x{E0000}-\x{E007F}]+`)
+
+	// commonInvisible are the invisible characters used by legitimate
+	// texts: the zero width (non) joiners in emojis and some scripts,
+	// and the direction marks in right-to-left texts.
+	commonInvisible = "\u200C\u200D\u200E\u200F"
+
+	markdownImage = regexp.MustCompile(`!\[[^\]]*\]\(\s*<?(https?://[^\s)>]+)>?(?:\s+"[^"]*")?\s*\)`)
+)
+
+// An Injection is a prompt injection attempt
+// found in a text seen by the agent.
+type Injection struct {
+	Kind     string `json:"kind"`
+	Location string `json:"location"`
+	Excerpt  string `json:"excerpt"`
+}
+
+// Injections is a list of Injection.
+type Injections []Injection
+
+// String returns the locations and kinds of
+// the injections, without their excerpts.
+func (l Injections) String() string {
+
+	seen := map[string]struct{}{}
+	out := make([]string, 0, len(l))
+
+	for _, i := range l {
+		s := fmt.Sprintf("%s in %s", i.Kind, i.Location)
+		if _, ok := seen[s]; ok {
+			continue
+		}
+		seen[s] = struct{}{}
+		out = append(out, s)
+	}
+
+	return strings.Join(out, ", ")
+}
+
+// InspectText returns the injections found in the given text. The text must
+// not reference any of the given tools. The location is set on the injections.
+func InspectText(location string, text string, tools []string) Injections {
+
+	var out Injections
+
+	for _, m := range findInjections(text, tools) {
+		out = append(out, Injection{
+			Kind:     m.kind,
+			Location: location,
+			Excerpt:  excerpt(m.kind, text[m.start:m.end]),
+		})
+	}
+
+	return out
+}
+
+// StripText returns the given text without the injections found
+// by InspectText. The invisible characters and the images are
+// removed, and so are the sentences containing instructions or
+// references to the given tools.
+func StripText(text string, tools []string) string {
+
+	matches := findInjections(text, tools)
+	if len(matches) == 0 {
+		return text
+	}
+
+	for i, m := range matches {
+		if m.sentence {
+			matches[i].start, matches[i].end = sentence(text, m.start, m.end)
+		}
+	}
+
+	slices.SortFunc(matches, func(a, b injectionMatch) int { return a.start - b.start })
+
+	var sb strings.Builder
+	last := 0
+	for _, m := range matches {
+		if m.end <= last {
+			continue
+		}
+		sb.WriteString(text[last:max(last, m.start)])
+		last = m.end
+
+		// Do not leave two spaces where the match was.
+		if prev := sb.String(); prev == "" || strings.HasSuffix(prev, " ") || strings.HasSuffix(prev, "\n") {
+			for last < len(text) && (text[last] == ' ' || text[last] == '\t') {
+				last++
+			}
+		}
+	}
+	sb.WriteString(text[last:])
+
+	return strings.TrimSpace(sb.String())
+}
+
+// InspectTools returns the injections found in the descriptions
+// of the given tools and of their parameters. A description
+// referencing another tool is reported as an injection.
+func InspectTools(tools mcp.Tools) Injections {
+
+	names := make([]string, 0, len(tools))
+	for _, t := range tools {
+		names = append(names, t.Name)
+	}
+
+	out := Injections{}
+
+	for _, t := range tools {
+
+		others := OtherTools(names, t.Name)
+
+		out = append(out, InspectText(ToolLocation(t.Name, ""), t.Description, others)...)
+
+		props, _ := t.InputSchema["properties"].(map[string]any)
+		for _, pk := range slices.Sorted(maps.Keys(props)) {
+			if prop, ok := props[pk].(map[string]any); ok {
+				if pdesc, ok := prop["description"].(string); ok {
+					out = append(out, InspectText(ToolLocation(t.Name, pk), pdesc, others)...)
+				}
+			}
+		}
+	}
+
+	return out
+}
+
+// ToolLocation returns the location of the description of
+// the given tool, or of its given parameter if set.
+func ToolLocation(tool string, param string) string {
+
+	if param == "" {
+		return fmt.Sprintf("tool '%s'", tool)
+	}
+
+	return fmt.Sprintf("tool '%s' param '%s'", tool, param)
+}
+
+// OtherTools returns the names without the given name.
+func OtherTools(names []string, name string) []string {
+	return slices.DeleteFunc(slices.Clone(names), func(n string) bool { return n == name })
+}
+
+type injectionMatch struct {
+	kind  string
+	start int
+	end   int
+
+	// sentence is true if the whole sentence
+	// containing the match must be stripped.
+	sentence bool
+}
+
+func findInjections(text string, tools []string) []injectionMatch {
+
+	var out []injectionMatch
+
+	blocks := hiddenBlock.FindAllStringIndex(text, -1)
+	for _, m := range blocks {
+		out = append(out, injectionMatch{InjectionHiddenInstruction, m[0], m[1], false})
+	}
+
+	// The instructions inside a block are already reported with it.
+	for _, m := range hiddenInstruction.FindAllStringIndex(text, -1) {
+		if !slices.ContainsFunc(blocks, func(b []int) bool { return m[0] >= b[0] && m[1] <= b[1] }) {
+			out = append(out, injectionMatch{InjectionHiddenInstruction, m[0], m[1], true})
+		}
+	}
+
+	// A single common invisible character is not reported, only the
+	// runs that are longer or that contain other invisible characters.
+	for _, m := range invisibleUnicode.FindAllStringIndex(text, -1) {
+		if r, n := utf8.DecodeRuneInString(text[m[0]:m[1]]); n == m[1]-m[0] && strings.ContainsRune(commonInvisible, r) {
+			continue
+		}
+		out = append(out, injectionMatch{InjectionInvisibleUnicode, m[0], m[1], false})
+	}
+
+	// Only the images with parameters in their URL are reported, as
+	// they are used to send data to the server hosting the images.
+	for _, m := range markdownImage.FindAllStringSubmatchIndex(text, -1) {
+		if u := text[m[2]:m[3]]; strings.ContainsAny(u, "?{$") || strings.Contains(strings.ToLower(u), "%7b") {
+			out = append(out, injectionMatch{InjectionMarkdownImage, m[0], m[1], false})
+		}
+	}
+
+	for _, name := range tools {
+		if !distinctiveName(name) {
+			continue
+		}
+		for i := 0; i < len(text); {
+			j := strings.Index(text[i:], name)
+			if j < 0 {
+				break
+			}
+			start, end := i+j, i+j+len(name)
+			if !nameRune(text[:start], true) && !nameRune(text[end:], false) {
+				out = append(out, injectionMatch{InjectionToolReference, start, end, true})
+			}
+			i = end
+		}
+	}
+
+	return out
+}
+
+// distinctiveName returns true if the given tool name is
+// unlikely to be a plain word, like read_file or getUser.
+func distinctiveName(name string) bool {
+
+	for i, r := range name {
+		if r == '_' || r == '-' || r == '.' || unicode.IsDigit(r) || (i > 0 && unicode.IsUpper(r)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// nameRune returns true if the rune at the end, or at the start,
+// of s could be part of a tool name.
+func nameRune(s string, last bool) bool {
+
+	var r rune
+	if last {
+		r, _ = utf8.DecodeLastRuneInString(s)
+	} else {
+		r, _ = utf8.DecodeRuneInString(s)
+	}
+
+	return r != utf8.RuneError && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-')
+}
+
+// sentence returns the bounds of the sentence
+// containing the given bounds in text.
+func sentence(text string, start int, end int) (int, int) {
+
+	for start > 0 && !sentenceEnd(text, start-1) {
+		start--
+	}
+
+	for end < len(text) && !sentenceEnd(text, end-1) {
+		end++
+	}
+
+	return start, end
+}
+
+// sentenceEnd returns true if the byte at i
+// in text ends a sentence.
+func sentenceEnd(text string, i int) bool {
+
+	switch text[i] {
+	case '\n':
+		return true
+	case '.', '!', '?':
+		return i+1 == len(text) || text[i+1] == ' ' || text[i+1] == '\n' || text[i+1] == '\t'
+	default:
+		return false
+	}
+}
+
+func excerpt(kind string, s string) string {
+
+	if kind == InjectionInvisibleUnicode {
+		runes := make([]string, 0, len(s))
+		for _, r := range s {
+			runes = append(runes, fmt.Sprintf("%U", r))
+		}
+		s = strings.Join(runes, " ")
+	}
+
+	if len(s) > maxExcerptLength {
+		s = strings.ToValidUTF8(s[:maxExcerptLength], "") + "..."
+	}
+
+	return s
+}